@@ -34,6 +34,20 @@ type TestingT interface {
 type failureMessageArgs struct {
 	// Name is the name of the test. It's is `SpecTest.name``
 	Name string
+	// Curl is the curl command that reproduces the request of the test.
+	Curl string
+}
+
+// labeledContent returns the name and the curl command of the test.
+func (f failureMessageArgs) labeledContent() []labeledContent {
+	content := []labeledContent{}
+	if f.Name != "" {
+		content = append(content, labeledContent{"Name", f.Name})
+	}
+	if f.Curl != "" {
+		content = append(content, labeledContent{"cURL", f.Curl})
+	}
+	return content
 }
 
 // Verifier is the assertion interface allowing consumers to inject a custom assertion implementation.
@@ -194,35 +208,33 @@ func messageFromMsgAndArgs(msgAndArgs ...interface{}) []labeledContent {
 			return []labeledContent{{"Messages", msgAsStr}}
 		}
 		if failureMsg, ok := msg.(failureMessageArgs); ok {
-			if failureMsg.Name == "" {
+			if failureMsg.Name == "" && failureMsg.Curl == "" {
 				return nil
 			}
-			return []labeledContent{{"Name", failureMsg.Name}}
+			return failureMsg.labeledContent()
 		}
 		return []labeledContent{{"Messages", fmt.Sprintf("%+v", msg)}}
 	}
 
 	if len(msgAndArgs) > 1 {
 		var strMsgs []string
-		var structuredMsg *labeledContent
+		var structuredMsg []labeledContent
 		for _, msg := range msgAndArgs {
 			if msgAsStr, ok := msg.(string); ok {
 				strMsgs = append(strMsgs, msgAsStr)
 			}
 			if failureMsg, ok := msg.(failureMessageArgs); ok {
-				if failureMsg.Name == "" {
+				if failureMsg.Name == "" && failureMsg.Curl == "" {
 					return nil
 				}
-				structuredMsg = &labeledContent{"Name", failureMsg.Name}
+				structuredMsg = failureMsg.labeledContent()
 			}
 		}
 		combinedContent := []labeledContent{}
 		if len(strMsgs) > 0 {
 			combinedContent = append(combinedContent, labeledContent{"Messages", strings.Join(strMsgs, ", ")})
 		}
-		if structuredMsg != nil {
-			combinedContent = append(combinedContent, *structuredMsg)
-		}
+		combinedContent = append(combinedContent, structuredMsg...)
 		return combinedContent
 	}
 	return nil
@@ -1,6 +1,7 @@
 package spectest
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -13,6 +14,8 @@ type capture struct {
 	finalResponse *http.Response
 	// mockInteractions is the list of mock interactions
 	mockInteractions []*mockInteraction
+	// err is the error raised while the interactions are captured
+	err error
 }
 
 // newCapture creates a new capture
@@ -28,7 +31,7 @@ func (c *capture) appendObserver(observers []Observe) []Observe {
 		c.finalResponse = copyHTTPResponse(finalRes)
 		defer func() {
 			if err := c.finalResponse.Body.Close(); err != nil {
-				panic(err) // FIXME: handle error
+				c.err = fmt.Errorf("failed to close the final response body: %w", err)
 			}
 		}()
 		c.inboundRequest = copyHTTPRequest(inboundReq)
@@ -45,3 +48,23 @@ func (c *capture) appendMockObservers(mocksObservers []Observe) []Observe {
 		})
 	})
 }
+
+// resetMockInteractions drops the mock interactions of the previous attempt,
+// so the report shows only the interactions of the last attempt.
+func (c *capture) resetMockInteractions() {
+	c.mockInteractions = []*mockInteraction{}
+}
+
+// redact replaces the captured requests and responses with the redacted copies,
+// so the secrets do not appear in the recorder.
+func (c *capture) redact(r *Redaction) {
+	if r.isEmpty() {
+		return
+	}
+	c.inboundRequest = r.request(c.inboundRequest)
+	c.finalResponse = r.response(c.finalResponse)
+	for _, interaction := range c.mockInteractions {
+		interaction.request = r.request(interaction.request)
+		interaction.response = r.response(interaction.response)
+	}
+}
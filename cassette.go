@@ -0,0 +1,335 @@
+package spectest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nao1215/gorky/file"
+)
+
+// updateCassettes is the flag to re-record the cassettes. Example: go test -update-cassettes
+var updateCassettes = flag.Bool("update-cassettes", false, "re-record the cassettes of spectest")
+
+// CassetteMode is the mode of the Cassette.
+type CassetteMode uint
+
+const (
+	// CassetteModeAuto replays the cassette file if it exists, otherwise records a new cassette file.
+	// If the test is run with the -update-cassettes flag, the cassette file is re-recorded. This is the default.
+	CassetteModeAuto CassetteMode = 0
+	// CassetteModeRecord always records a new cassette file.
+	CassetteModeRecord CassetteMode = 1
+	// CassetteModeReplay always replays the cassette file. The test fails if the cassette file does not exist.
+	CassetteModeReplay CassetteMode = 2
+)
+
+// CassetteMatch is the part of the request that is compared when the cassette is replayed.
+// The values can be combined. e.g. CassetteMatchMethod | CassetteMatchURL
+type CassetteMatch uint
+
+const (
+	// CassetteMatchMethod compares the http method.
+	CassetteMatchMethod CassetteMatch = 1 << iota
+	// CassetteMatchURL compares the scheme, host, path and query parameters.
+	CassetteMatchURL
+	// CassetteMatchBody compares the request body. JSON bodies are compared semantically.
+	CassetteMatchBody
+)
+
+// redactedValue replaces the value of the redacted headers.
+const redactedValue = "[REDACTED]"
+
+// Cassette records the outbound http interactions of the system under test to a file,
+// and replays them as mocks. In record mode, requests that do not match any mock are sent
+// to the real server, and each interaction is saved to the cassette file. In replay mode,
+// the saved interactions are loaded as mocks.
+type Cassette struct {
+	// path is the path of the cassette file
+	path string
+	// mode is the mode of the cassette
+	mode CassetteMode
+	// match is the part of the request that is compared when the cassette is replayed
+	match CassetteMatch
+	// redactHeaders is the list of headers whose value is not saved
+	redactHeaders []string
+
+	// once decides whether the cassette records or replays on the first use
+	once sync.Once
+	// recording is true if the cassette records the interactions
+	recording bool
+	// loadErr is the error occurred when the cassette file was loaded
+	loadErr error
+	// mu protects interactions
+	mu sync.Mutex
+	// interactions is the list of recorded or loaded interactions
+	interactions []cassetteInteraction
+}
+
+// cassetteFile is the content of the cassette file.
+type cassetteFile struct {
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+// cassetteInteraction is a pair of a request and a response saved in the cassette file.
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+// cassetteRequest is the request saved in the cassette file.
+type cassetteRequest struct {
+	Method       string              `json:"method"`
+	URL          string              `json:"url"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+	Body         string              `json:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty"`
+}
+
+// cassetteResponse is the response saved in the cassette file.
+type cassetteResponse struct {
+	StatusCode   int                 `json:"status_code"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+	Body         string              `json:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty"`
+}
+
+// NewCassette creates a new Cassette that is saved to the given path.
+// By default, the request is matched by the method and the URL, and the Authorization,
+// Proxy-Authorization and Cookie headers are redacted.
+func NewCassette(path string) *Cassette {
+	return &Cassette{
+		path:          path,
+		mode:          CassetteModeAuto,
+		match:         CassetteMatchMethod | CassetteMatchURL,
+		redactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie"},
+	}
+}
+
+// Mode sets the mode of the cassette.
+func (c *Cassette) Mode(mode CassetteMode) *Cassette {
+	c.mode = mode
+	return c
+}
+
+// MatchOn sets the part of the request that is compared when the cassette is replayed.
+func (c *Cassette) MatchOn(match CassetteMatch) *Cassette {
+	c.match = match
+	return c
+}
+
+// RedactHeaders adds headers whose value is replaced with "[REDACTED]" before saving.
+// The headers are redacted in both the request and the response. The redaction policy
+// of the test (see SpecTest.Redact) is applied to the cassette too, so the query parameters,
+// the cookies and the bodies can be redacted as well. The redacted query parameters match any value
+// on replay, but the redacted bodies do not match the requests if CassetteMatchBody is used.
+func (c *Cassette) RedactHeaders(names ...string) *Cassette {
+	c.redactHeaders = append(c.redactHeaders, names...)
+	return c
+}
+
+// begin decides whether the cassette records or replays. It returns the mocks built from
+// the cassette file in replay mode. Each call returns new mocks, so the cassette can be used by many specs.
+func (c *Cassette) begin() (Mocks, error) {
+	c.once.Do(func() {
+		switch c.mode {
+		case CassetteModeRecord:
+			c.recording = true
+		case CassetteModeReplay:
+			c.recording = false
+		default:
+			c.recording = *updateCassettes || !file.IsFile(c.path)
+		}
+		if !c.recording {
+			c.loadErr = c.load()
+		}
+	})
+	if c.loadErr != nil {
+		return nil, c.loadErr
+	}
+	if c.recording {
+		return nil, nil
+	}
+	return c.mocks()
+}
+
+// isRecording returns true if the cassette records the interactions.
+func (c *Cassette) isRecording() bool {
+	return c.recording
+}
+
+// load reads the interactions from the cassette file.
+func (c *Cassette) load() error {
+	data, err := os.ReadFile(filepath.Clean(c.path))
+	if err != nil {
+		return fmt.Errorf("failed to read cassette: %w", err)
+	}
+	f := cassetteFile{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("failed to parse cassette %s: %w", c.path, err)
+	}
+	c.interactions = f.Interactions
+	return nil
+}
+
+// save writes the recorded interactions to the cassette file. It does nothing in replay mode.
+func (c *Cassette) save() error {
+	if !c.recording {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o600)
+}
+
+// record adds the interaction to the cassette. The headers of the cassette and the redaction policy
+// of the test are applied to the interaction before it is saved.
+func (c *Cassette) record(req *http.Request, reqBody []byte, res *http.Response, resBody []byte, redaction *Redaction) {
+	policy := NewRedaction().Headers(c.redactHeaders...).with(redaction)
+	interaction := cassetteInteraction{
+		Request: cassetteRequest{
+			Method:  req.Method,
+			URL:     policy.url(req.URL).String(),
+			Headers: c.redact(policy, req.Header),
+		},
+		Response: cassetteResponse{
+			StatusCode: res.StatusCode,
+			Headers:    c.redact(policy, res.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeCassetteBody(policy.body(req.Header.Get("Content-Type"), reqBody))
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeCassetteBody(policy.body(res.Header.Get("Content-Type"), resBody))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+}
+
+// redact returns a copy of the headers whose redacted values are replaced.
+func (c *Cassette) redact(policy *Redaction, headers http.Header) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	return copyValues(policy.header(headers))
+}
+
+// mocks builds the mocks from the interactions of the cassette file.
+func (c *Cassette) mocks() (Mocks, error) {
+	mocks := make(Mocks, 0, len(c.interactions))
+	for _, interaction := range c.interactions {
+		m, err := c.mock(interaction)
+		if err != nil {
+			return nil, err
+		}
+		mocks = append(mocks, m)
+	}
+	return mocks, nil
+}
+
+// mock builds the mock from the interaction.
+func (c *Cassette) mock(interaction cassetteInteraction) (*Mock, error) {
+	recorded, err := url.Parse(interaction.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url in cassette %s: %w", c.path, err)
+	}
+
+	m := NewMock()
+	m.request.url = &url.URL{}
+	if c.match&CassetteMatchMethod != 0 {
+		m.request.method = interaction.Request.Method
+	}
+	if c.match&CassetteMatchURL != 0 {
+		m.request.exactURL(recorded)
+	}
+	if c.match&CassetteMatchBody != 0 {
+		body, err := decodeCassetteBody(interaction.Request.Body, interaction.Request.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		m.request.body = string(body)
+	}
+
+	body, err := decodeCassetteBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	m.response.statusCode = interaction.Response.StatusCode
+	m.response.headers = copyValues(interaction.Response.Headers)
+	m.response.body = string(body)
+	return m, nil
+}
+
+// encodeCassetteBody returns the body as a string. Bodies that are not valid UTF-8 are encoded in base64.
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeCassetteBody decodes the body saved by encodeCassetteBody.
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("unknown body encoding in cassette: %s", encoding)
+	}
+}
+
+// passThrough sends the request to the real server with the native transport
+// and records the interaction to the cassette.
+func (r *Transport) passThrough(req *http.Request) (*http.Response, error) {
+	native := r.nativeTransport
+	if native == nil {
+		native = http.DefaultTransport
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	res, err := native.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.Body.Close(); err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	var redaction *Redaction
+	if r.specTest != nil {
+		redaction = r.specTest.redactionPolicy()
+	}
+	r.cassette.record(req, reqBody, res, resBody, redaction)
+	return res, nil
+}
@@ -0,0 +1,259 @@
+package spectest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nao1215/spectest"
+)
+
+// proxyHandler sends the body of the inbound request to the url, and writes the response of the upstream server.
+func proxyHandler(method, url string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), method, url, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		res, err := spectest.ClientFromContext(r.Context()).Do(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close() //nolint
+		body, _ := io.ReadAll(res.Body)
+		w.WriteHeader(res.StatusCode)
+		_, _ = w.Write(body)
+	}
+}
+
+func TestCassette(t *testing.T) {
+	t.Run("record the interactions and replay them", func(t *testing.T) {
+		var calls int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 1, "name": "Tom"}`))
+		}))
+		path := filepath.Join(t.TempDir(), "cassettes", "get_user.json")
+
+		spectest.New("record").
+			IsolateMocks().
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/users/1?fields=name")).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusOK).
+			Body(`{"id": 1, "name": "Tom"}`).
+			End()
+		spectest.DefaultVerifier{}.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cassette := map[string]interface{}{}
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			t.Fatal(err)
+		}
+		interactions := cassette["interactions"].([]interface{})
+		spectest.DefaultVerifier{}.Equal(t, 1, len(interactions))
+		request := interactions[0].(map[string]interface{})["request"].(map[string]interface{})
+		spectest.DefaultVerifier{}.Equal(t, []interface{}{"[REDACTED]"}, request["headers"].(map[string]interface{})["Authorization"])
+
+		// The upstream server is not needed to replay the cassette.
+		upstream.Close()
+		spectest.New("replay").
+			IsolateMocks().
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/users/1?fields=name")).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusOK).
+			Body(`{"id": 1, "name": "Tom"}`).
+			End()
+		spectest.DefaultVerifier{}.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		// A request that is not in the cassette does not match.
+		spectest.New("replay other url").
+			IsolateMocks().
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/users/1?fields=id")).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusBadGateway).
+			End()
+	})
+
+	t.Run("match on the request body", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		}))
+		defer upstream.Close()
+		path := filepath.Join(t.TempDir(), "create_user.json")
+		newCassette := func(mode spectest.CassetteMode) *spectest.Cassette {
+			return spectest.NewCassette(path).
+				Mode(mode).
+				MatchOn(spectest.CassetteMatchMethod | spectest.CassetteMatchURL | spectest.CassetteMatchBody)
+		}
+
+		spectest.New().
+			IsolateMocks().
+			Cassette(newCassette(spectest.CassetteModeRecord)).
+			Handler(proxyHandler(http.MethodPost, upstream.URL+"/users")).
+			Post("/user").
+			JSON(`{"name": "Jerry"}`).
+			Expect(t).
+			Status(http.StatusCreated).
+			Body(`{"name": "Jerry"}`).
+			End()
+
+		spectest.New().
+			IsolateMocks().
+			Cassette(newCassette(spectest.CassetteModeReplay)).
+			Handler(proxyHandler(http.MethodPost, upstream.URL+"/users")).
+			Post("/user").
+			JSON(`{ "name" : "Jerry" }`).
+			Expect(t).
+			Status(http.StatusCreated).
+			Body(`{"name": "Jerry"}`).
+			End()
+
+		spectest.New().
+			IsolateMocks().
+			Cassette(newCassette(spectest.CassetteModeReplay)).
+			Handler(proxyHandler(http.MethodPost, upstream.URL+"/users")).
+			Post("/user").
+			JSON(`{"name": "Tom"}`).
+			Expect(t).
+			Status(http.StatusBadGateway).
+			End()
+	})
+
+	t.Run("redaction policy", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 1, "token": "s3cr3t-token"}`))
+		}))
+		path := filepath.Join(t.TempDir(), "get_token.json")
+		redaction := spectest.NewRedaction().QueryParams("api_key").JSONPaths("$.token")
+
+		spectest.New("record").
+			IsolateMocks().
+			Redact(redaction).
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/token?api_key=s3cr3t-key&scope=read")).
+			Get("/token").
+			Expect(t).
+			Status(http.StatusOK).
+			Body(`{"id": 1, "token": "s3cr3t-token"}`).
+			End()
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "s3cr3t") {
+			t.Errorf("the cassette has the redacted values:\n%s", data)
+		}
+
+		// The redacted query parameter matches any value, and the other query parameters are still compared.
+		upstream.Close()
+		spectest.New("replay").
+			IsolateMocks().
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/token?api_key=other&scope=read")).
+			Get("/token").
+			Expect(t).
+			Status(http.StatusOK).
+			Body(`{"id": 1, "token": "[REDACTED]"}`).
+			End()
+		spectest.New("replay other scope").
+			IsolateMocks().
+			Cassette(spectest.NewCassette(path)).
+			Handler(proxyHandler(http.MethodGet, upstream.URL+"/token?api_key=other&scope=write")).
+			Get("/token").
+			Expect(t).
+			Status(http.StatusBadGateway).
+			End()
+	})
+
+	t.Run("standalone mocks", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Set-Cookie", "session=secret")
+			_, _ = w.Write([]byte("pong"))
+		}))
+		path := filepath.Join(t.TempDir(), "ping.json")
+		cli := &http.Client{Transport: http.DefaultTransport}
+
+		get := func() string {
+			res, err := cli.Get(upstream.URL + "/ping")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close() //nolint
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(body)
+		}
+
+		reset := spectest.NewStandaloneMocks().
+			HTTPClient(cli).
+			Cassette(t, spectest.NewCassette(path).RedactHeaders("Set-Cookie")).
+			End()
+		spectest.DefaultVerifier{}.Equal(t, "pong", get())
+		reset()
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "session=secret") {
+			t.Errorf("the cassette has the redacted header:\n%s", data)
+		}
+
+		upstream.Close()
+		reset = spectest.NewStandaloneMocks().
+			HTTPClient(cli).
+			Cassette(t, spectest.NewCassette(path)).
+			End()
+		defer reset()
+		spectest.DefaultVerifier{}.Equal(t, "pong", get())
+	})
+
+	t.Run("standalone mocks report the cassette errors", func(t *testing.T) {
+		captor := &failureCaptorT{}
+		reset := spectest.NewStandaloneMocks().
+			Cassette(captor, spectest.NewCassette(filepath.Join(t.TempDir(), "missing.json")).Mode(spectest.CassetteModeReplay)).
+			End()
+		reset()
+		if len(captor.messages) != 1 {
+			t.Fatalf("expected the load error of the cassette, got %v", captor.messages)
+		}
+
+		captor = &failureCaptorT{}
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		reset = spectest.NewStandaloneMocks().
+			Cassette(captor, spectest.NewCassette(filepath.Join(dir, "file", "cassette.json")).Mode(spectest.CassetteModeRecord)).
+			End()
+		reset()
+		if len(captor.messages) != 1 || !strings.HasPrefix(captor.messages[0], "failed to save the cassette") {
+			t.Errorf("expected the save error of the cassette, got %v", captor.messages)
+		}
+	})
+}
@@ -45,7 +45,7 @@ func Test_main(t *testing.T) {
 		{
 			name:     "unknown",
 			args:     []string{"unknown"},
-			exitCode: 0, // TODO: should be 1
+			exitCode: 1,
 		},
 	}
 	for _, tt := range tests {
@@ -0,0 +1,119 @@
+package sub
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/openapi"
+	"github.com/spf13/cobra"
+)
+
+// newCoverageCmd return coverage command.
+func newCoverageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "coverage",
+		Short: "Report which OpenAPI operations and status codes are exercised by the tests",
+		Long: `Report which OpenAPI operations and status codes are exercised by the tests.
+
+The coverage command reads the meta data of the reports in the target directory,
+and compares them with the OpenAPI document. The meta data are read from the
+"*.meta.json" files saved with ReportFormatterConfig.MetaJSON and from the HTML reports.
+It generates coverage.md and coverage.json in the output directory.`,
+		RunE:    coverage,
+		Example: "   spectest coverage TARGET_DIR --spec openapi.yaml",
+	}
+
+	cmd.Flags().StringP("spec", "s", "", "OpenAPI document in JSON or YAML format (required)")
+	cmd.Flags().StringP("output", "o", "", "output directory of the coverage report (default: TARGET_DIR)")
+	return cmd
+}
+
+// coverageReporter is a struct for coverage command.
+type coverageReporter struct {
+	// target is a directory that has the reports.
+	target string
+	// spec is a path of the OpenAPI document.
+	spec string
+	// output is a output directory of the coverage report.
+	output string
+}
+
+// newCoverageReporter return coverageReporter.
+func newCoverageReporter(cmd *cobra.Command, args []string) (*coverageReporter, error) {
+	spec, err := cmd.Flags().GetString("spec")
+	if err != nil {
+		return nil, err
+	}
+	if spec == "" {
+		return nil, errors.New("--spec is required")
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return nil, err
+	}
+
+	target := "."
+	if len(args) > 0 {
+		target = args[0]
+	}
+	if output == "" {
+		output = target
+	}
+
+	return &coverageReporter{
+		target: target,
+		spec:   spec,
+		output: output,
+	}, nil
+}
+
+// run generates the coverage report.
+func (c *coverageReporter) run() error {
+	doc, err := openapi.Load(c.spec)
+	if err != nil {
+		return err
+	}
+	metas, err := spectest.ReadMetas(c.target)
+	if err != nil {
+		return err
+	}
+	result := doc.Coverage(metas)
+
+	markdown, err := result.Markdown()
+	if err != nil {
+		return err
+	}
+	jsonData, err := result.JSON()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.output, 0o750); err != nil {
+		return err
+	}
+	markdownPath := filepath.Join(c.output, "coverage.md")
+	if err := os.WriteFile(markdownPath, []byte(markdown), 0o600); err != nil {
+		return err
+	}
+	jsonPath := filepath.Join(c.output, "coverage.json")
+	if err := os.WriteFile(jsonPath, jsonData, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d of %d operation and status code pairs are covered (%.1f%%)\n", result.CoveredCount, result.Total, result.Percentage)
+	fmt.Printf("generated coverage report at %s and %s\n", markdownPath, jsonPath)
+	return nil
+}
+
+// coverage generates the OpenAPI coverage report.
+func coverage(cmd *cobra.Command, args []string) error {
+	c, err := newCoverageReporter(cmd, args)
+	if err != nil {
+		return fmt.Errorf("failed to initialize coverage command: %w", err)
+	}
+	return c.run()
+}
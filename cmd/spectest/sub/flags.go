@@ -0,0 +1,118 @@
+package sub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wrapperFlags are the flags of the spectest wrapper. They are removed from the arguments
+// before the arguments are passed to 'go test'.
+type wrapperFlags struct {
+	// junit is the path of the JUnit XML report.
+	junit string
+	// tap is the path of the TAP report.
+	tap string
+	// ci disables the color and the progress, and prints the result of each package.
+	ci bool
+	// failOnSkip fails the run if any test is skipped.
+	failOnSkip bool
+	// failOnNoTests fails the run if no test is run.
+	failOnNoTests bool
+	// rerunFails is the maximum number of times the failed tests are re-run.
+	rerunFails int
+	// rerunFailsReport is the path of the JSON report of the re-run tests.
+	rerunFailsReport string
+	// slowest is the number of the slowest tests shown in the summary.
+	slowest int
+	// slowTestThreshold fails the run if any test takes longer. It is disabled if it is zero.
+	slowTestThreshold time.Duration
+}
+
+// defaultSlowest is the default number of the slowest tests shown in the summary.
+const defaultSlowest = 5
+
+// parseWrapperFlags extracts the wrapper flags from the arguments, and returns the arguments for 'go test'.
+// Only the "--name value" and "--name=value" forms are recognized, so the go test flags are never misread.
+// The boolean flags take no value, or "--name=true" and "--name=false".
+// The arguments after "-args" are passed to the test binary as they are.
+func parseWrapperFlags(args []string) (*wrapperFlags, []string, error) {
+	flags := &wrapperFlags{slowest: defaultSlowest}
+	stringFlags := map[string]*string{
+		"junit":              &flags.junit,
+		"tap":                &flags.tap,
+		"rerun-fails-report": &flags.rerunFailsReport,
+	}
+	intFlags := map[string]*int{
+		"rerun-fails": &flags.rerunFails,
+		"slowest":     &flags.slowest,
+	}
+	durationFlags := map[string]*time.Duration{
+		"slow-test-threshold": &flags.slowTestThreshold,
+	}
+	boolFlags := map[string]*bool{
+		"ci":               &flags.ci,
+		"fail-on-skip":     &flags.failOnSkip,
+		"fail-on-no-tests": &flags.failOnNoTests,
+	}
+
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-args" || arg == "--args" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if target, ok := boolFlags[name]; ok {
+			b := true
+			if hasValue {
+				parsed, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid boolean value %q for --%s", value, name)
+				}
+				b = parsed
+			}
+			*target = b
+			continue
+		}
+		stringTarget, isString := stringFlags[name]
+		intTarget, isInt := intFlags[name]
+		durationTarget, isDuration := durationFlags[name]
+		if !isString && !isInt && !isDuration {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag needs an argument: --%s", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch {
+		case isString:
+			*stringTarget = value
+		case isInt:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, nil, fmt.Errorf("invalid value %q for --%s: must be a non-negative integer", value, name)
+			}
+			*intTarget = n
+		case isDuration:
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, nil, fmt.Errorf("invalid value %q for --%s: must be a non-negative duration. e.g. 2s", value, name)
+			}
+			*durationTarget = d
+		}
+	}
+	return flags, rest, nil
+}
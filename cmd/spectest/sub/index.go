@@ -1,24 +1,46 @@
 package sub
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/nao1215/markdown"
+	md "github.com/nao1215/markdown"
+	"github.com/nao1215/spectest"
 	"github.com/spf13/cobra"
 )
 
 // newIndexCmd return index command.
 func newIndexCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "index",
-		Short:   "Generate an index for a directory full of markdown files",
+		Use:   "index",
+		Short: "Generate an index for a directory full of reports",
+		Long: `Generate an index for a directory full of reports.
+
+The index command reads the meta data of the reports (HTML, Markdown, HAR and JSON) in the
+target directory, and generates an index with a summary table of the endpoints and a
+table of the reports of each endpoint. The endpoints are sorted by the path and the
+method, and the number of the success (1xx-3xx), the error (4xx-5xx) and the no response
+cases and the failed tests are counted for each endpoint. The reports without the meta data
+are listed at the end with the first heading of the markdown report as the title.
+Set ReportFormatterConfig.MetaJSON to save the meta data of the markdown reports.`,
 		RunE:    index,
-		Example: "   spectest index TARGET_DIR",
+		Example: "   spectest index TARGET_DIR --format html",
 	}
 
-	cmd.Flags().StringP("title", "t", "", "title of index markdown file")
-	cmd.Flags().StringSliceP("desc", "d", []string{}, "description of index markdown file")
+	cmd.Flags().StringP("title", "t", "", "title of index file (default: Index)")
+	cmd.Flags().StringSliceP("desc", "d", []string{}, "description of index file")
+	cmd.Flags().StringP("format", "f", "markdown", "format of index file: markdown or html")
 	return cmd
 }
 
@@ -31,6 +53,8 @@ type indexer struct {
 	title string
 	// description is a description of index.
 	description []string
+	// format is the format of index. markdown or html.
+	format string
 }
 
 // newIndexer return indexer.
@@ -39,12 +63,23 @@ func newIndexer(cmd *cobra.Command, args []string) (*indexer, error) {
 	if err != nil {
 		return nil, err
 	}
+	if title == "" {
+		title = "Index"
+	}
 
 	description, err := cmd.Flags().GetStringSlice("desc")
 	if err != nil {
 		return nil, err
 	}
 
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return nil, err
+	}
+	if format != "markdown" && format != "html" {
+		return nil, fmt.Errorf("unsupported format %q: must be markdown or html", format)
+	}
+
 	target := "."
 	if len(args) > 0 {
 		target = args[0]
@@ -54,19 +89,370 @@ func newIndexer(cmd *cobra.Command, args []string) (*indexer, error) {
 		target:      target,
 		title:       title,
 		description: description,
+		format:      format,
 	}, nil
 }
 
-// run generate an index for a directory full of markdown files.
+// indexFileNames are the file names of the generated index. They are not listed in the index.
+var indexFileNames = map[string]bool{
+	"index.md":   true,
+	"index.html": true,
+}
+
+// run generate an index for a directory full of reports.
 func (i *indexer) run() error {
-	if err := markdown.GenerateIndex(i.target, markdown.WithTitle(i.title), markdown.WithDescription(i.description)); err != nil {
+	reports, err := spectest.ReadReportFiles(i.target)
+	if err != nil {
+		return err
+	}
+	idx, err := newReportIndex(i.target, reports)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	name := "index.md"
+	if i.format == "html" {
+		name = "index.html"
+		data, err = idx.html(i.title, i.description)
+	} else {
+		data, err = idx.markdown(i.title, i.description)
+	}
+	if err != nil {
 		return err
 	}
-	fmt.Printf("generated index markdown at %s\n", filepath.Join(i.target, "index.md"))
+
+	path := filepath.Join(i.target, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("generated index %s at %s\n", i.format, path)
 	return nil
 }
 
-// index generate an index for a directory full of markdown files.
+// reportIndex is the index of the reports.
+type reportIndex struct {
+	// Endpoints are the endpoints sorted by the path and the method.
+	Endpoints []*indexEndpoint
+	// Others are the reports without the meta data.
+	Others []indexReport
+	// Success is the number of the reports with the success status code.
+	Success int
+	// Error is the number of the reports with the error status code.
+	Error int
+	// NoResponse is the number of the reports without the response (status code 0).
+	NoResponse int
+	// Failed is the number of the reports whose assertions failed.
+	Failed int
+}
+
+// indexEndpoint is the reports of an endpoint.
+type indexEndpoint struct {
+	// Method is the http method of the endpoint.
+	Method string
+	// Path is the http request path of the endpoint.
+	Path string
+	// Reports are the reports of the endpoint sorted by the status code and the name.
+	Reports []indexReport
+	// Success is the number of the reports with the success status code (1xx-3xx).
+	Success int
+	// Error is the number of the reports with the error status code (4xx-5xx).
+	Error int
+	// NoResponse is the number of the reports without the response (status code 0).
+	NoResponse int
+	// Failed is the number of the reports whose assertions failed.
+	Failed int
+}
+
+// indexReport is a report in the index.
+type indexReport struct {
+	// File is the path of the report relative to the target directory, with slashes.
+	File string
+	// Name is the test name of the report. It is the title of the markdown report without the meta data.
+	Name string
+	// StatusCode is the final status code of the report.
+	StatusCode int
+	// Duration is the duration of the report.
+	Duration time.Duration
+	// Result is passed, failed or unknown.
+	Result string
+}
+
+// newReportIndex groups the reports by the endpoint.
+func newReportIndex(target string, reports []spectest.ReportFile) (*reportIndex, error) {
+	idx := &reportIndex{Endpoints: []*indexEndpoint{}, Others: []indexReport{}}
+	endpoints := map[string]*indexEndpoint{}
+	for _, r := range reports {
+		rel, err := filepath.Rel(target, r.Path)
+		if err != nil {
+			return nil, err
+		}
+		if indexFileNames[rel] {
+			continue
+		}
+
+		report := indexReport{
+			File:       filepath.ToSlash(rel),
+			Name:       r.Meta.Name,
+			StatusCode: r.Meta.StatusCode,
+			Duration:   time.Duration(r.Meta.Duration).Round(time.Millisecond),
+			Result:     reportResult(r.Meta),
+		}
+		if r.Meta.Method == "" && r.Meta.Path == "" {
+			if report.Name == "" && r.Kind == spectest.ReportKindMarkdown {
+				if report.Name, err = markdownTitle(r.Path); err != nil {
+					return nil, err
+				}
+			}
+			idx.Others = append(idx.Others, report)
+			continue
+		}
+
+		key := r.Meta.Method + " " + r.Meta.Path
+		endpoint, ok := endpoints[key]
+		if !ok {
+			endpoint = &indexEndpoint{Method: r.Meta.Method, Path: r.Meta.Path}
+			endpoints[key] = endpoint
+			idx.Endpoints = append(idx.Endpoints, endpoint)
+		}
+		endpoint.Reports = append(endpoint.Reports, report)
+		switch {
+		case report.StatusCode == 0:
+			endpoint.NoResponse++
+			idx.NoResponse++
+		case report.StatusCode >= http.StatusBadRequest:
+			endpoint.Error++
+			idx.Error++
+		default:
+			endpoint.Success++
+			idx.Success++
+		}
+		if report.Result == "failed" {
+			endpoint.Failed++
+			idx.Failed++
+		}
+	}
+
+	sort.Slice(idx.Endpoints, func(i, j int) bool {
+		a, b := idx.Endpoints[i], idx.Endpoints[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+	for _, endpoint := range idx.Endpoints {
+		sort.SliceStable(endpoint.Reports, func(i, j int) bool {
+			a, b := endpoint.Reports[i], endpoint.Reports[j]
+			if a.StatusCode != b.StatusCode {
+				return a.StatusCode < b.StatusCode
+			}
+			return a.Name < b.Name
+		})
+	}
+	return idx, nil
+}
+
+// markdownTitle returns the first H1 or H2 heading of the markdown file.
+// It returns an empty string if the file does not exist or has no heading.
+func markdownTitle(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close() //nolint
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "## ") {
+			return strings.TrimSpace(strings.TrimLeft(line, "#")), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// Total returns the number of the reports with the meta data.
+func (idx *reportIndex) Total() int {
+	return idx.Success + idx.Error + idx.NoResponse
+}
+
+// statusClass returns the class of the status code: success, warning (4xx), danger (5xx)
+// or none (no response).
+func statusClass(status int) string {
+	switch {
+	case status == 0:
+		return "none"
+	case status >= http.StatusInternalServerError:
+		return "danger"
+	case status >= http.StatusBadRequest:
+		return "warning"
+	default:
+		return "success"
+	}
+}
+
+// statusBadgeColors are the colors of the status code badge for each status class.
+var statusBadgeColors = map[string]string{
+	"success": "green",
+	"warning": "yellow",
+	"danger":  "red",
+	"none":    "lightgrey",
+}
+
+// statusLabel returns the label of the status code badge.
+func statusLabel(status int) string {
+	if status == 0 {
+		return "no response"
+	}
+	return strconv.Itoa(status)
+}
+
+// markdownStatusBadge returns the status code badge image in markdown.
+func markdownStatusBadge(status int) string {
+	label := statusLabel(status)
+	return md.Image(label, fmt.Sprintf("https://img.shields.io/badge/%s-%s", strings.ReplaceAll(label, " ", "%20"), statusBadgeColors[statusClass(status)]))
+}
+
+// indexTableOptions keeps the report file names on one line.
+var indexTableOptions = md.TableOptions{AutoWrapText: false, AutoFormatHeaders: false}
+
+// markdown returns the index in markdown.
+func (idx *reportIndex) markdown(title string, description []string) ([]byte, error) {
+	var buf bytes.Buffer
+	markdown := md.NewMarkdown(&buf).H1(title).LF()
+	for _, d := range description {
+		markdown = markdown.PlainText(d).LF()
+	}
+
+	markdown = markdown.H2("Summary").LF().
+		PlainTextf("%d reports of %d endpoints: %d success, %d error, %d no response, %d failed.",
+			idx.Total(), len(idx.Endpoints), idx.Success, idx.Error, idx.NoResponse, idx.Failed).LF()
+	if len(idx.Endpoints) > 0 {
+		summary := md.TableSet{Header: []string{"Method", "Path", "Success", "Error", "No response", "Failed", "Total"}}
+		for _, e := range idx.Endpoints {
+			summary.Rows = append(summary.Rows, []string{
+				e.Method, e.Path, strconv.Itoa(e.Success), strconv.Itoa(e.Error), strconv.Itoa(e.NoResponse),
+				strconv.Itoa(e.Failed), strconv.Itoa(len(e.Reports)),
+			})
+		}
+		markdown = markdown.CustomTable(summary, indexTableOptions).LF()
+	}
+
+	for _, e := range idx.Endpoints {
+		table := md.TableSet{Header: []string{"Status", "Result", "Name", "Duration", "Report"}}
+		for _, r := range e.Reports {
+			table.Rows = append(table.Rows, []string{
+				markdownStatusBadge(r.StatusCode), r.Result, r.Name, formatIndexDuration(r.Duration), md.Link(r.File, r.File),
+			})
+		}
+		markdown = markdown.H2f("%s %s", e.Method, e.Path).LF().CustomTable(table, indexTableOptions).LF()
+	}
+
+	if len(idx.Others) > 0 {
+		links := make([]string, 0, len(idx.Others))
+		for _, r := range idx.Others {
+			if r.Name == "" {
+				links = append(links, md.Link(r.File, r.File))
+				continue
+			}
+			links = append(links, md.Link(r.Name, r.File))
+		}
+		markdown = markdown.H2("Other reports").LF().BulletList(links...).LF()
+	}
+
+	if err := markdown.Build(); err != nil {
+		return nil, fmt.Errorf("failed to generate index markdown: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formatIndexDuration returns the duration shown in the index. It is empty if the report has no duration.
+func formatIndexDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// html returns the index in HTML.
+func (idx *reportIndex) html(title string, description []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlIndexTemplate.Execute(&buf, struct {
+		Title       string
+		Description []string
+		Index       *reportIndex
+	}{
+		Title:       title,
+		Description: description,
+		Index:       idx,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate index html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// htmlIndexTemplate is the template of the HTML index. The style is inlined, so the index works offline.
+var htmlIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"statusClass": statusClass,
+	"statusLabel": statusLabel,
+	"duration":    formatIndexDuration,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>` + serveStyle + `
+.badge { display: inline-block; padding: 0.1em 0.5em; border-radius: 0.3em; color: #fff; font-weight: bold; }
+.badge-success { background: #1a7f37; }
+.badge-warning { background: #bf8700; }
+.badge-danger { background: #cf222e; }
+.badge-none { background: #6e7781; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{- range .Description }}
+<p>{{ . }}</p>
+{{- end }}
+<h2>Summary</h2>
+<p>{{ .Index.Total }} reports of {{ len .Index.Endpoints }} endpoints: {{ .Index.Success }} success, {{ .Index.Error }} error, {{ .Index.NoResponse }} no response, {{ .Index.Failed }} failed.</p>
+{{- if .Index.Endpoints }}
+<table>
+<thead><tr><th>Method</th><th>Path</th><th>Success</th><th>Error</th><th>No response</th><th>Failed</th><th>Total</th></tr></thead>
+<tbody>
+{{- range .Index.Endpoints }}
+<tr><td>{{ .Method }}</td><td>{{ .Path }}</td><td>{{ .Success }}</td><td>{{ .Error }}</td><td>{{ .NoResponse }}</td><td>{{ .Failed }}</td><td>{{ len .Reports }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+{{- end }}
+{{- range .Index.Endpoints }}
+<h2>{{ .Method }} {{ .Path }}</h2>
+<table>
+<thead><tr><th>Status</th><th>Result</th><th>Name</th><th>Duration</th><th>Report</th></tr></thead>
+<tbody>
+{{- range .Reports }}
+<tr><td><span class="badge badge-{{ statusClass .StatusCode }}">{{ statusLabel .StatusCode }}</span></td><td class="{{ .Result }}">{{ .Result }}</td><td>{{ .Name }}</td><td>{{ duration .Duration }}</td><td><a href="{{ .File }}">{{ .File }}</a></td></tr>
+{{- end }}
+</tbody>
+</table>
+{{- end }}
+{{- if .Index.Others }}
+<h2>Other reports</h2>
+<ul>
+{{- range .Index.Others }}
+<li><a href="{{ .File }}">{{ if .Name }}{{ .Name }}{{ else }}{{ .File }}{{ end }}</a></li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`))
+
+// index generate an index for a directory full of reports.
 func index(cmd *cobra.Command, args []string) error {
 	i, err := newIndexer(cmd, args)
 	if err != nil {
@@ -0,0 +1,155 @@
+package sub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nao1215/spectest"
+)
+
+// indexReportFiles are the report files of the index tests.
+func indexReportFiles(target string) []spectest.ReportFile {
+	passed, failed := true, false
+	return []spectest.ReportFile{
+		{Path: filepath.Join(target, "create_user.md"), Meta: spectest.Meta{Method: "POST", Path: "/users", Name: "create user", StatusCode: 201, Duration: int64(3 * time.Millisecond)}},
+		{Path: filepath.Join(target, "users", "get_user.html"), Meta: spectest.Meta{Method: "GET", Path: "/users/1", Name: "get user", StatusCode: 200, Passed: &passed}},
+		{Path: filepath.Join(target, "users", "missing_user.html"), Meta: spectest.Meta{Method: "GET", Path: "/users/1", Name: "missing user", StatusCode: 404}},
+		{Path: filepath.Join(target, "users", "broken_user.html"), Meta: spectest.Meta{Method: "GET", Path: "/users/1", Name: "broken user", StatusCode: 500, Passed: &failed}},
+		{Path: filepath.Join(target, "users", "timeout_user.html"), Meta: spectest.Meta{Method: "GET", Path: "/users/1", Name: "timeout user"}},
+		{Path: filepath.Join(target, "list_users.md"), Meta: spectest.Meta{Method: "GET", Path: "/users", Name: "list users", StatusCode: 200}},
+		{Path: filepath.Join(target, "coverage.md"), Meta: spectest.Meta{ReportFileName: "coverage"}},
+		{Path: filepath.Join(target, "index.md"), Meta: spectest.Meta{ReportFileName: "index"}},
+	}
+}
+
+func Test_newReportIndex(t *testing.T) {
+	target := filepath.Join("reports", "api")
+	idx, err := newReportIndex(target, indexReportFiles(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &reportIndex{
+		Endpoints: []*indexEndpoint{
+			{Method: "GET", Path: "/users", Success: 1, Reports: []indexReport{
+				{File: "list_users.md", Name: "list users", StatusCode: 200, Result: "unknown"},
+			}},
+			{Method: "POST", Path: "/users", Success: 1, Reports: []indexReport{
+				{File: "create_user.md", Name: "create user", StatusCode: 201, Duration: 3 * time.Millisecond, Result: "unknown"},
+			}},
+			{Method: "GET", Path: "/users/1", Success: 1, Error: 2, NoResponse: 1, Failed: 1, Reports: []indexReport{
+				{File: "users/timeout_user.html", Name: "timeout user", Result: "unknown"},
+				{File: "users/get_user.html", Name: "get user", StatusCode: 200, Result: "passed"},
+				{File: "users/missing_user.html", Name: "missing user", StatusCode: 404, Result: "unknown"},
+				{File: "users/broken_user.html", Name: "broken user", StatusCode: 500, Result: "failed"},
+			}},
+		},
+		Others:     []indexReport{{File: "coverage.md", Result: "unknown"}},
+		Success:    3,
+		Error:      2,
+		NoResponse: 1,
+		Failed:     1,
+	}
+	if diff := cmp.Diff(want, idx); diff != "" {
+		t.Errorf("newReportIndex() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_reportIndex_markdown(t *testing.T) {
+	idx, err := newReportIndex("reports", indexReportFiles("reports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := idx.markdown("API", []string{"description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"# API",
+		"description",
+		"6 reports of 3 endpoints: 3 success, 2 error, 1 no response, 1 failed.",
+		"| GET    | /users/1 |       1 |     2 |           1 |      1 |     4 |",
+		"| ![no response](https://img.shields.io/badge/no%20response-lightgrey) | unknown | timeout user |",
+		"## GET /users/1",
+		"| ![404](https://img.shields.io/badge/404-yellow)                      | unknown | missing user |",
+		"| ![500](https://img.shields.io/badge/500-red)                         | failed  | broken user  |",
+		"[users/get_user.html](users/get_user.html)",
+		"## Other reports",
+		"- [coverage.md](coverage.md)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("index markdown does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func Test_reportIndex_html(t *testing.T) {
+	idx, err := newReportIndex("reports", indexReportFiles("reports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := idx.html("API <v1>", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"<h1>API &lt;v1&gt;</h1>",
+		"<p>6 reports of 3 endpoints: 3 success, 2 error, 1 no response, 1 failed.</p>",
+		"<tr><td>GET</td><td>/users/1</td><td>1</td><td>2</td><td>1</td><td>1</td><td>4</td></tr>",
+		`<span class="badge badge-none">no response</span>`,
+		`<td class="failed">failed</td><td>broken user</td>`,
+		"<h2>POST /users</h2>",
+		`<span class="badge badge-warning">404</span>`,
+		`<span class="badge badge-danger">500</span>`,
+		`<td>3ms</td><td><a href="create_user.md">create_user.md</a></td>`,
+		`<li><a href="coverage.md">coverage.md</a></li>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("index html does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func Test_newReportIndexMarkdownTitle(t *testing.T) {
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "get_user.md"), []byte("# get user\n## GET /users/1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "notes.md"), []byte("no heading\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reports, err := spectest.ReadReportFiles(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := newReportIndex(target, reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []indexReport{
+		{File: "get_user.md", Name: "get user", Result: "unknown"},
+		{File: "notes.md", Result: "unknown"},
+	}
+	if diff := cmp.Diff(want, idx.Others); diff != "" {
+		t.Errorf("newReportIndex() others mismatch (-want +got):\n%s", diff)
+	}
+
+	data, err := idx.markdown("API", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- [get user](get_user.md)", "- [notes.md](notes.md)"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("index markdown does not contain %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package sub
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root element of the JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is the result of a package.
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is the result of a test or a subtest.
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+// junitMessage is the failure, error or skip message of the test case.
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+// junitOutput is the output of the passed test case.
+type junitOutput struct {
+	Body string `xml:",cdata"`
+}
+
+// packageFailureName returns the name of the test case that reports the failure outside of the tests.
+func packageFailureName(pkg *packageResult) string {
+	if pkg.FailedBuild != "" {
+		return "[build failed]"
+	}
+	return "[setup failed]"
+}
+
+// packageFailureOutput returns the output of the failure outside of the tests.
+// It returns false if the package did not fail outside of the tests.
+func packageFailureOutput(pkg *packageResult, tests []*testCase, buildOutput map[string][]string) ([]string, bool) {
+	if pkg.Action != "fail" {
+		return nil, false
+	}
+	for _, tc := range tests {
+		if tc.Package == pkg.Name && tc.Action == "fail" {
+			return nil, false
+		}
+	}
+	if pkg.FailedBuild != "" {
+		return buildOutput[pkg.FailedBuild], true
+	}
+	return pkg.Output, true
+}
+
+// junitReport converts the test results to the JUnit XML report.
+func junitReport(packages []*packageResult, tests []*testCase, buildOutput map[string][]string, timestamp time.Time) *junitTestSuites {
+	report := &junitTestSuites{}
+	var total time.Duration
+	for _, pkg := range packages {
+		suite := junitTestSuite{
+			Name:      pkg.Name,
+			Time:      junitTime(pkg.Elapsed),
+			Timestamp: timestamp.UTC().Format("2006-01-02T15:04:05"),
+		}
+		total += pkg.Elapsed
+
+		for _, tc := range tests {
+			if tc.Package != pkg.Name || tc.Action == "" {
+				continue
+			}
+			testCase := junitTestCase{
+				ClassName: tc.Package,
+				Name:      tc.Name,
+				Time:      junitTime(tc.Elapsed),
+			}
+			output := junitText(strings.Join(tc.Output, "\n"))
+			switch tc.Action {
+			case "fail":
+				testCase.Failure = &junitMessage{Message: "Failed", Body: output}
+				suite.Failures++
+			case "skip":
+				testCase.Skipped = &junitMessage{Message: junitText(skipReason(tc.Output))}
+				suite.Skipped++
+			default:
+				if output != "" {
+					testCase.SystemOut = &junitOutput{Body: output}
+				}
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Tests++
+		}
+
+		if output, ok := packageFailureOutput(pkg, tests, buildOutput); ok {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				ClassName: pkg.Name,
+				Name:      packageFailureName(pkg),
+				Time:      junitTime(pkg.Elapsed),
+				Error:     &junitMessage{Message: "Failed", Body: junitText(strings.Join(output, "\n"))},
+			})
+			suite.Tests++
+			suite.Errors++
+		}
+		if suite.Tests == 0 {
+			// The package has no test files.
+			continue
+		}
+
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+	}
+	report.Time = junitTime(total)
+	return report
+}
+
+// ansiEscape matches the ANSI escape sequences, e.g. the colors of the test output.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// junitText removes the ANSI escape sequences and the characters that are not allowed in XML 1.0,
+// e.g. \x00 or \x1b, from the output. encoding/xml writes CDATA sections without checking the characters,
+// so the report is rejected by the CI servers otherwise. Tab, newline and carriage return are kept.
+func junitText(s string) string {
+	s = ansiEscape.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r == 0xfffe, r == 0xffff:
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, ""))
+}
+
+// junitTime formats the duration in seconds.
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// skipReasonPrefix is the "file_test.go:12: " prefix that t.Skip adds to the message.
+var skipReasonPrefix = regexp.MustCompile(`^\S+\.go:\d+: `)
+
+// skipReason returns the message passed to t.Skip.
+func skipReason(output []string) string {
+	reasons := []string{}
+	for _, line := range output {
+		line = skipReasonPrefix.ReplaceAllString(strings.TrimSpace(line), "")
+		if line != "" {
+			reasons = append(reasons, line)
+		}
+	}
+	return strings.Join(reasons, " ")
+}
+
+// tapReport converts the test results to the Test Anything Protocol (TAP) version 13 report.
+func tapReport(packages []*packageResult, tests []*testCase, buildOutput map[string][]string) string {
+	lines := []string{}
+	n := 0
+	for _, pkg := range packages {
+		for _, tc := range tests {
+			if tc.Package != pkg.Name || tc.Action == "" {
+				continue
+			}
+			n++
+			description := fmt.Sprintf("%s %s", tc.Package, tc.Name)
+			switch tc.Action {
+			case "fail":
+				lines = append(lines, fmt.Sprintf("not ok %d - %s", n, description))
+				lines = append(lines, tapDiagnostic(tc.Elapsed, tc.Output)...)
+			case "skip":
+				lines = append(lines, fmt.Sprintf("ok %d - %s # SKIP %s", n, description, skipReason(tc.Output)))
+			default:
+				lines = append(lines, fmt.Sprintf("ok %d - %s", n, description))
+			}
+		}
+
+		if output, ok := packageFailureOutput(pkg, tests, buildOutput); ok {
+			n++
+			lines = append(lines, fmt.Sprintf("not ok %d - %s %s", n, pkg.Name, packageFailureName(pkg)))
+			lines = append(lines, tapDiagnostic(pkg.Elapsed, output)...)
+		}
+	}
+	header := []string{"TAP version 13", fmt.Sprintf("1..%d", n)}
+	return strings.Join(append(header, lines...), "\n") + "\n"
+}
+
+// tapDiagnostic returns the YAML diagnostic block of the failed test.
+func tapDiagnostic(elapsed time.Duration, output []string) []string {
+	lines := []string{
+		"  ---",
+		fmt.Sprintf("  duration_ms: %d", elapsed.Milliseconds()),
+	}
+	if len(output) > 0 {
+		lines = append(lines, "  output: |")
+		for _, line := range output {
+			lines = append(lines, "    "+line)
+		}
+	}
+	return append(lines, "  ...")
+}
+
+// writeReports writes the JUnit XML, the TAP and the re-run reports if the flags are set.
+func (s *spectester) writeReports() error {
+	if s.flags.junit != "" {
+		data, err := xml.MarshalIndent(junitReport(s.packages, s.tests, s.buildOutput, s.startedAt), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeReportFile(s.flags.junit, append([]byte(xml.Header), append(data, '\n')...)); err != nil {
+			return fmt.Errorf("failed to write JUnit XML report: %w", err)
+		}
+	}
+	if s.flags.tap != "" {
+		if err := writeReportFile(s.flags.tap, []byte(tapReport(s.packages, s.tests, s.buildOutput))); err != nil {
+			return fmt.Errorf("failed to write TAP report: %w", err)
+		}
+	}
+	if s.flags.rerunFailsReport != "" {
+		return s.writeRerunReport()
+	}
+	return nil
+}
+
+// writeReportFile writes the report. The parent directory is created if it does not exist.
+func writeReportFile(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0o600)
+}
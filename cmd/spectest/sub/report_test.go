@@ -0,0 +1,237 @@
+package sub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newParsedSpectester returns the spectester that has parsed the test events.
+func newParsedSpectester(t *testing.T, args []string, events []string) *spectester {
+	t.Helper()
+
+	s, err := newSpectester(nil, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		s.parse(event)
+	}
+	return s
+}
+
+// reportEvents are the test events of a package with a passed, a failed and a skipped test,
+// and a package that failed to build.
+var reportEvents = []string{
+	`{"Action":"run","Package":"example.com/a","Test":"TestPass"}`,
+	`{"Action":"output","Package":"example.com/a","Test":"TestPass","Output":"    a_test.go:5: hello\n"}`,
+	`{"Action":"pass","Package":"example.com/a","Test":"TestPass","Elapsed":0.01}`,
+	`{"Action":"run","Package":"example.com/a","Test":"TestFail"}`,
+	`{"Action":"output","Package":"example.com/a","Test":"TestFail","Output":"    a_test.go:9: boom\n"}`,
+	`{"Action":"fail","Package":"example.com/a","Test":"TestFail","Elapsed":1.5}`,
+	`{"Action":"run","Package":"example.com/a","Test":"TestSkip"}`,
+	`{"Action":"output","Package":"example.com/a","Test":"TestSkip","Output":"    a_test.go:13: not implemented\n"}`,
+	`{"Action":"skip","Package":"example.com/a","Test":"TestSkip"}`,
+	`{"Action":"fail","Package":"example.com/a","Elapsed":1.6}`,
+	`{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-output","Output":"b_test.go:5:33: undefined: undefined\n"}`,
+	`{"Action":"fail","Package":"example.com/b","FailedBuild":"example.com/b [example.com/b.test]"}`,
+	`{"Action":"skip","Package":"example.com/c","Output":"?   \texample.com/c\t[no test files]\n"}`,
+}
+
+func Test_junitReport(t *testing.T) {
+	s := newParsedSpectester(t, nil, reportEvents)
+	got := junitReport(s.packages, s.tests, s.buildOutput, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	want := &junitTestSuites{
+		Tests: 4, Failures: 1, Errors: 1, Skipped: 1, Time: "1.600",
+		Suites: []junitTestSuite{
+			{
+				Name: "example.com/a", Tests: 3, Failures: 1, Skipped: 1, Time: "1.600", Timestamp: "2024-01-02T03:04:05",
+				TestCases: []junitTestCase{
+					{ClassName: "example.com/a", Name: "TestPass", Time: "0.010", SystemOut: &junitOutput{Body: "    a_test.go:5: hello"}},
+					{ClassName: "example.com/a", Name: "TestFail", Time: "1.500", Failure: &junitMessage{Message: "Failed", Body: "    a_test.go:9: boom"}},
+					{ClassName: "example.com/a", Name: "TestSkip", Time: "0.000", Skipped: &junitMessage{Message: "not implemented"}},
+				},
+			},
+			{
+				Name: "example.com/b", Tests: 1, Errors: 1, Time: "0.000", Timestamp: "2024-01-02T03:04:05",
+				TestCases: []junitTestCase{
+					{ClassName: "example.com/b", Name: "[build failed]", Time: "0.000", Error: &junitMessage{Message: "Failed", Body: "b_test.go:5:33: undefined: undefined"}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(xml.Name{})); diff != "" {
+		t.Errorf("junitReport() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_junitReportControlCharacters(t *testing.T) {
+	s := newParsedSpectester(t, nil, []string{
+		`{"Action":"run","Package":"example.com/a","Test":"TestFail"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestFail","Output":"    a_test.go:9: \u001b[31mboom\u001b[0m\u0000\u0007\tdone\r\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestFail","Elapsed":1.5}`,
+		`{"Action":"fail","Package":"example.com/a","Elapsed":1.5}`,
+	})
+	data, err := xml.Marshal(junitReport(s.packages, s.tests, s.buildOutput, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The report must be a well-formed XML document.
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("the report is not well-formed: %v\n%s", err, data)
+		}
+	}
+	if want := "<![CDATA[    a_test.go:9: boom\tdone\r]]>"; !bytes.Contains(data, []byte(want)) {
+		t.Errorf("the report does not contain %q:\n%s", want, data)
+	}
+}
+
+func Test_tapReport(t *testing.T) {
+	s := newParsedSpectester(t, nil, reportEvents)
+
+	want := `TAP version 13
+1..4
+ok 1 - example.com/a TestPass
+not ok 2 - example.com/a TestFail
+  ---
+  duration_ms: 1500
+  output: |
+        a_test.go:9: boom
+  ...
+ok 3 - example.com/a TestSkip # SKIP not implemented
+not ok 4 - example.com/b [build failed]
+  ---
+  duration_ms: 0
+  output: |
+    b_test.go:5:33: undefined: undefined
+  ...
+`
+	if diff := cmp.Diff(want, tapReport(s.packages, s.tests, s.buildOutput)); diff != "" {
+		t.Errorf("tapReport() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_writeReports(t *testing.T) {
+	dir := t.TempDir()
+	junit := filepath.Join(dir, "reports", "junit.xml")
+	tap := filepath.Join(dir, "reports", "result.tap")
+	s := newParsedSpectester(t, []string{"--junit", junit, "--tap=" + tap, "./..."}, reportEvents)
+	if diff := cmp.Diff([]string{"./..."}, s.args); diff != "" {
+		t.Errorf("go test args mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := s.writeReports(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(junit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &junitTestSuites{}
+	if err := xml.Unmarshal(data, report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 4 || len(report.Suites) != 2 {
+		t.Errorf("unexpected JUnit XML report: %s", data)
+	}
+	if _, err := os.Stat(tap); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_parseWrapperFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     wrapperFlags
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			name:     "go test flags are kept",
+			args:     []string{"-v", "-run", "TestA", "-count=1", "./..."},
+			want:     wrapperFlags{slowest: defaultSlowest},
+			wantArgs: []string{"-v", "-run", "TestA", "-count=1", "./..."},
+		},
+		{
+			name:     "wrapper flags are removed",
+			args:     []string{"--junit", "junit.xml", "-v", "--tap=result.tap", "./..."},
+			want:     wrapperFlags{junit: "junit.xml", tap: "result.tap", slowest: defaultSlowest},
+			wantArgs: []string{"-v", "./..."},
+		},
+		{
+			name:     "arguments of the test binary are not parsed",
+			args:     []string{"./...", "-args", "--junit", "junit.xml"},
+			want:     wrapperFlags{slowest: defaultSlowest},
+			wantArgs: []string{"./...", "-args", "--junit", "junit.xml"},
+		},
+		{
+			name:     "boolean flags",
+			args:     []string{"--ci", "--fail-on-skip=true", "--fail-on-no-tests=false", "./..."},
+			want:     wrapperFlags{ci: true, failOnSkip: true, slowest: defaultSlowest},
+			wantArgs: []string{"./..."},
+		},
+		{
+			name:     "rerun flags",
+			args:     []string{"--rerun-fails=3", "--rerun-fails-report", "rerun.json", "./..."},
+			want:     wrapperFlags{rerunFails: 3, rerunFailsReport: "rerun.json", slowest: defaultSlowest},
+			wantArgs: []string{"./..."},
+		},
+		{
+			name:     "timing flags",
+			args:     []string{"--slowest", "0", "--slow-test-threshold=2s", "./..."},
+			want:     wrapperFlags{slowTestThreshold: 2 * time.Second},
+			wantArgs: []string{"./..."},
+		},
+		{
+			name:    "invalid threshold",
+			args:    []string{"--slow-test-threshold", "fast"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid rerun count",
+			args:    []string{"--rerun-fails=-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid boolean value",
+			args:    []string{"--ci=yes"},
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			args:    []string{"./...", "--junit"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotArgs, err := parseWrapperFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWrapperFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, *got, cmp.AllowUnexported(wrapperFlags{})); diff != "" {
+				t.Errorf("flags mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantArgs, gotArgs); diff != "" {
+				t.Errorf("args mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
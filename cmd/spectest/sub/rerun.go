@@ -0,0 +1,230 @@
+package sub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// goTestValueFlags are the flags of 'go test' whose value can be the next argument. e.g. -run TestA
+var goTestValueFlags = map[string]bool{
+	"bench": true, "benchtime": true, "blockprofile": true, "blockprofilerate": true, "count": true,
+	"covermode": true, "coverpkg": true, "coverprofile": true, "cpu": true, "cpuprofile": true,
+	"exec": true, "fuzz": true, "fuzzminimizetime": true, "fuzztime": true, "gcflags": true,
+	"ldflags": true, "list": true, "memprofile": true, "memprofilerate": true, "mod": true,
+	"modfile": true, "mutexprofile": true, "mutexprofilefraction": true, "o": true, "outputdir": true,
+	"overlay": true, "p": true, "parallel": true, "pkgdir": true, "run": true, "shuffle": true,
+	"skip": true, "tags": true, "timeout": true, "toolexec": true, "trace": true, "vet": true,
+}
+
+// rerunArgs returns the arguments of 'go test' without the package patterns, -run and -count,
+// so the failed tests of a package can be re-run with their own -run pattern.
+func rerunArgs(args []string) []string {
+	rerun := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-args" || arg == "--args" {
+			return append(rerun, args[i:]...)
+		}
+		if !strings.HasPrefix(arg, "-") {
+			// A package pattern.
+			continue
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		name = strings.TrimPrefix(name, "test.")
+		takesNext := goTestValueFlags[name] && !hasValue && i+1 < len(args)
+		if name == "run" || name == "count" {
+			if takesNext {
+				i++
+			}
+			continue
+		}
+		rerun = append(rerun, arg)
+		if takesNext {
+			i++
+			rerun = append(rerun, args[i])
+		}
+	}
+	return rerun
+}
+
+// rerunFailedTests re-runs the failed top level tests of each package up to --rerun-fails times.
+// The tests that pass on retry are marked as flaky, and counted as passed tests.
+func (s *spectester) rerunFailedTests() error {
+	failed := failedTopLevelTests(s.tests)
+	failedPackages := map[string]bool{}
+	for pkg := range failed {
+		failedPackages[pkg] = true
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	args := rerunArgs(s.args)
+	for attempt := 0; attempt < s.flags.rerunFails && len(failed) > 0; attempt++ {
+		next := map[string][]string{}
+		for _, pkg := range sortedKeys(failed) {
+			retry := &spectester{
+				args:        append(append(append([]string{}, args...), "-count=1", "-run", runPattern(failed[pkg])), pkg),
+				flags:       &wrapperFlags{ci: s.flags.ci},
+				packages:    []*packageResult{},
+				tests:       []*testCase{},
+				testIndex:   map[string]*testCase{},
+				buildOutput: map[string][]string{},
+			}
+			if err := retry.runTest(); err != nil {
+				return err
+			}
+			if remaining := s.applyRetry(pkg, failed[pkg], retry.tests); len(remaining) > 0 {
+				next[pkg] = remaining
+			}
+		}
+		failed = next
+	}
+
+	// The package passes if all failed tests passed on retry.
+	allPassed := true
+	for _, pkg := range s.packages {
+		if failedPackages[pkg.Name] && len(failed[pkg.Name]) == 0 && pkg.FailedBuild == "" {
+			pkg.Action = "pass"
+		}
+		if pkg.Action == "fail" {
+			allPassed = false
+		}
+	}
+	if allPassed {
+		s.exitCode = 0
+	}
+	return nil
+}
+
+// applyRetry updates the results of the tests with the results of the retry,
+// and returns the top level tests that are still failing.
+// A test that did not run on retry, e.g. because of a build error, is still failing.
+func (s *spectester) applyRetry(pkg string, topLevelTests []string, retried []*testCase) []string {
+	passed := map[string]bool{}
+	for _, tc := range retried {
+		if original, ok := s.testIndex[testKey(tc.Package, tc.Name)]; ok && tc.Action != "" {
+			original.Attempts++
+		}
+		if tc.Action == "pass" && !strings.Contains(tc.Name, "/") {
+			passed[tc.Name] = true
+		}
+	}
+
+	// The subtests of the passed top level test passed too.
+	for _, tc := range s.tests {
+		if tc.Package == pkg && tc.Action == "fail" && passed[topLevelName(tc.Name)] {
+			tc.Action = "pass"
+			tc.Flaky = true
+			atomic.AddInt32(&s.stats.Fail, -1)
+			atomic.AddInt32(&s.stats.Pass, 1)
+			atomic.AddInt32(&s.stats.Flaky, 1)
+		}
+	}
+
+	remaining := []string{}
+	for _, name := range topLevelTests {
+		if !passed[name] {
+			remaining = append(remaining, name)
+		}
+	}
+	return remaining
+}
+
+// topLevelName returns the name of the top level test of the test. e.g. TestA for TestA/sub
+func topLevelName(name string) string {
+	topLevel, _, _ := strings.Cut(name, "/")
+	return topLevel
+}
+
+// sortedKeys returns the sorted keys of the map.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flakyTests returns the names of the flaky tests. e.g. "example.com/a TestA"
+func flakyTests(tests []*testCase) []string {
+	flaky := []string{}
+	for _, tc := range tests {
+		if tc.Flaky {
+			flaky = append(flaky, fmt.Sprintf("%s %s", tc.Package, tc.Name))
+		}
+	}
+	return flaky
+}
+
+// rerunReport is the JSON report of the test results with the re-runs.
+type rerunReport struct {
+	// RerunFails is the maximum number of re-runs.
+	RerunFails int `json:"rerun_fails"`
+	// Passed is the number of the tests that passed in the first run.
+	Passed int `json:"passed"`
+	// Flaky is the number of the tests that failed and then passed on retry.
+	Flaky int `json:"flaky"`
+	// Failed is the number of the tests that failed in every run.
+	Failed int `json:"failed"`
+	// Skipped is the number of the skipped tests.
+	Skipped int `json:"skipped"`
+	// Tests is the list of the tests.
+	Tests []rerunReportTest `json:"tests"`
+}
+
+// rerunReportTest is the result of a test in the rerunReport.
+type rerunReportTest struct {
+	// Package is the import path of the package of the test.
+	Package string `json:"package"`
+	// Test is the name of the test.
+	Test string `json:"test"`
+	// Status is passed, flaky, failed or skipped.
+	Status string `json:"status"`
+	// Attempts is the number of the runs of the test.
+	Attempts int `json:"attempts"`
+}
+
+// newRerunReport returns the JSON report of the tests.
+func newRerunReport(rerunFails int, tests []*testCase) *rerunReport {
+	report := &rerunReport{RerunFails: rerunFails, Tests: []rerunReportTest{}}
+	for _, tc := range tests {
+		if tc.Action == "" {
+			continue
+		}
+		test := rerunReportTest{Package: tc.Package, Test: tc.Name, Attempts: tc.Attempts + 1}
+		switch {
+		case tc.Flaky:
+			test.Status = "flaky"
+			report.Flaky++
+		case tc.Action == "fail":
+			test.Status = "failed"
+			report.Failed++
+		case tc.Action == "skip":
+			test.Status = "skipped"
+			report.Skipped++
+		default:
+			test.Status = "passed"
+			report.Passed++
+		}
+		report.Tests = append(report.Tests, test)
+	}
+	return report
+}
+
+// writeRerunReport writes the JSON report of the re-run tests.
+func (s *spectester) writeRerunReport() error {
+	data, err := json.MarshalIndent(newRerunReport(s.flags.rerunFails, s.tests), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeReportFile(s.flags.rerunFailsReport, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write re-run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package sub
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_rerunArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "package patterns, -run and -count are removed",
+			args: []string{"-v", "-run", "TestA", "-count=2", "-race", "./...", "example.com/b"},
+			want: []string{"-v", "-race"},
+		},
+		{
+			name: "values of the flags are kept",
+			args: []string{"-timeout", "30s", "-tags=integration", "./..."},
+			want: []string{"-timeout", "30s", "-tags=integration"},
+		},
+		{
+			name: "arguments of the test binary are kept",
+			args: []string{"./...", "-args", "-update"},
+			want: []string{"-args", "-update"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, rerunArgs(tt.args)); diff != "" {
+				t.Errorf("rerunArgs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_spectester_applyRetry(t *testing.T) {
+	s := newParsedSpectester(t, []string{"--rerun-fails=2"}, []string{
+		`{"Action":"fail","Package":"example.com/a","Test":"TestFlaky/sub"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestFlaky"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestBroken"}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestOK"}`,
+		`{"Action":"fail","Package":"example.com/a"}`,
+	})
+	retry := newParsedSpectester(t, nil, []string{
+		`{"Action":"pass","Package":"example.com/a","Test":"TestFlaky/sub"}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestFlaky"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestBroken"}`,
+		`{"Action":"fail","Package":"example.com/a"}`,
+	})
+
+	remaining := s.applyRetry("example.com/a", []string{"TestFlaky", "TestBroken"}, retry.tests)
+	if diff := cmp.Diff([]string{"TestBroken"}, remaining); diff != "" {
+		t.Errorf("remaining tests mismatch (-want +got):\n%s", diff)
+	}
+
+	wantStats := TestStats{Pass: 3, Fail: 1, Total: 4, Flaky: 2}
+	if diff := cmp.Diff(wantStats, s.stats); diff != "" {
+		t.Errorf("stats mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"example.com/a TestFlaky/sub", "example.com/a TestFlaky"}, flakyTests(s.tests)); diff != "" {
+		t.Errorf("flakyTests() mismatch (-want +got):\n%s", diff)
+	}
+
+	want := &rerunReport{
+		RerunFails: 2, Passed: 1, Flaky: 2, Failed: 1,
+		Tests: []rerunReportTest{
+			{Package: "example.com/a", Test: "TestFlaky/sub", Status: "flaky", Attempts: 2},
+			{Package: "example.com/a", Test: "TestFlaky", Status: "flaky", Attempts: 2},
+			{Package: "example.com/a", Test: "TestBroken", Status: "failed", Attempts: 2},
+			{Package: "example.com/a", Test: "TestOK", Status: "passed", Attempts: 1},
+		},
+	}
+	if diff := cmp.Diff(want, newRerunReport(s.flags.rerunFails, s.tests)); diff != "" {
+		t.Errorf("newRerunReport() mismatch (-want +got):\n%s", diff)
+	}
+}
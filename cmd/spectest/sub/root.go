@@ -35,6 +35,7 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -44,7 +45,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
-	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/nao1215/spectest"
@@ -61,21 +62,51 @@ func Execute() int {
 	// However, spf13/cobra parses the arguments and throws an error stating "unknown command"
 	// if it is encountered. Therefore, if an unknown command is found, I want to forcibly
 	// execute the root command.
-	if _, _, err := rootCmd.Find(os.Args[1:]); err != nil {
-		if strings.HasPrefix(err.Error(), "unknown command") && !strings.Contains(err.Error(), "help") && !strings.Contains(err.Error(), "version") {
-			if err := root(rootCmd, os.Args[1:]); err != nil {
-				fmt.Fprintf(os.Stderr, "%s", err.Error())
-				return 1
-			}
-			return 0
+	// The flags of 'go test' and the wrapper, e.g. "spectest -v" or "spectest --junit report.xml",
+	// are not subcommands either.
+	_, _, err := rootCmd.Find(os.Args[1:])
+	if (err != nil && strings.HasPrefix(err.Error(), "unknown command") && !strings.Contains(err.Error(), "help") && !strings.Contains(err.Error(), "version")) ||
+		isWrapperInvocation(os.Args[1:]) {
+		return exitCode(root(rootCmd, os.Args[1:]))
+	}
+	return exitCode(rootCmd.Execute())
+}
+
+// exitCodeError is an error that has the exit code of the process.
+type exitCodeError struct {
+	// code is the exit code.
+	code int
+	// message is printed to stderr. It is empty if the reason is already printed, e.g. the failed tests.
+	message string
+}
+
+// Error returns the message.
+func (e *exitCodeError) Error() string {
+	return e.message
+}
+
+// exitCode prints the error to stderr, and returns the exit code of the process.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		if exitErr.message != "" {
+			fmt.Fprintln(os.Stderr, exitErr.message)
 		}
+		return exitErr.code
 	}
+	fmt.Fprintf(os.Stderr, "%s", err.Error())
+	return 1
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "%s", err.Error())
-		return 1
+// isWrapperInvocation returns true if the arguments start with a flag other than the help flag.
+func isWrapperInvocation(args []string) bool {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		return false
 	}
-	return 0
+	return args[0] != "-h" && args[0] != "--help"
 }
 
 // newRootCmd returns a root command.
@@ -94,12 +125,20 @@ By default, spectest command is a wrapper for 'go test' command.`,
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newBugReportCmd())
 	cmd.AddCommand(newIndexCmd())
+	cmd.AddCommand(newCoverageCmd())
+	cmd.AddCommand(newRunCmd())
+	cmd.AddCommand(newWatchCmd())
+	cmd.AddCommand(newServeCmd())
 	return cmd
 }
 
 // root is a root command.
 func root(cmd *cobra.Command, args []string) error {
-	return newSpectester(cmd, args).run()
+	s, err := newSpectester(cmd, args)
+	if err != nil {
+		return fmt.Errorf("failed to initialize spectest command: %w", err)
+	}
+	return s.run()
 }
 
 // TestStats holds the test statistics.
@@ -112,24 +151,51 @@ type TestStats struct {
 	Skip int32
 	// Total is the number of total tests.
 	Total int32
+	// BuildErrors is the number of packages that failed to build. They are not counted as failed tests.
+	BuildErrors int32
+	// Flaky is the number of tests that failed and then passed on retry. They are counted as passed tests.
+	Flaky int32
 }
 
 // spectester is a struct for spectest command.
 type spectester struct {
-	args            []string
-	stats           TestStats
-	allTestMessages []string
-	interval        *spectest.Interval
+	args     []string
+	flags    *wrapperFlags
+	stats    TestStats
+	interval *spectest.Interval
+	// startedAt is the time when the tests started.
+	startedAt time.Time
+	// packages is the list of the tested packages in the order of the first event.
+	packages []*packageResult
+	// tests is the list of the tests in the order of the first event.
+	tests []*testCase
+	// testIndex is the index of tests. The key is made by testKey.
+	testIndex map[string]*testCase
+	// buildOutput is the output of the build. The key is the import path of the package.
+	buildOutput map[string][]string
+	// buildImportPath is the import path of the last build output that is not a test event.
+	// Before Go 1.24, the go command writes the build errors to stderr after a "# <import path>" line.
+	buildImportPath string
+	// exitCode is the exit code of 'go test'.
+	exitCode int
 }
 
 // newSpectester returns a spectester.
-func newSpectester(_ *cobra.Command, args []string) *spectester {
-	return &spectester{
-		args:            args,
-		stats:           TestStats{},
-		allTestMessages: []string{},
-		interval:        spectest.NewInterval(),
+func newSpectester(_ *cobra.Command, args []string) (*spectester, error) {
+	flags, goTestArgs, err := parseWrapperFlags(args)
+	if err != nil {
+		return nil, err
 	}
+	return &spectester{
+		args:        goTestArgs,
+		flags:       flags,
+		stats:       TestStats{},
+		interval:    spectest.NewInterval(),
+		packages:    []*packageResult{},
+		tests:       []*testCase{},
+		testIndex:   map[string]*testCase{},
+		buildOutput: map[string][]string{},
+	}, nil
 }
 
 // run runs the spectest command.
@@ -137,7 +203,42 @@ func (s *spectester) run() error {
 	if err := s.canUseGoCommand(); err != nil {
 		return fmt.Errorf("spectest command requires go command. please install go command")
 	}
-	return s.runTest()
+	if s.flags.ci {
+		color.NoColor = true
+	}
+	s.interval.Start()
+	s.startedAt = time.Now()
+	if err := s.runTest(); err != nil {
+		return err
+	}
+	if s.flags.rerunFails > 0 {
+		if err := s.rerunFailedTests(); err != nil {
+			return err
+		}
+	}
+	s.interval.End()
+	s.testResult()
+
+	if err := s.writeReports(); err != nil {
+		return err
+	}
+	return s.result()
+}
+
+// result returns the exitCodeError if the run failed. The exit code of 'go test' is propagated.
+func (s *spectester) result() error {
+	switch {
+	case s.exitCode != 0:
+		return &exitCodeError{code: s.exitCode}
+	case len(testsOverThreshold(s.tests, s.flags.slowTestThreshold)) > 0:
+		return &exitCodeError{code: 1, message: fmt.Sprintf("%d tests took longer than %s (--slow-test-threshold)",
+			len(testsOverThreshold(s.tests, s.flags.slowTestThreshold)), s.flags.slowTestThreshold)}
+	case s.flags.failOnSkip && s.stats.Skip > 0:
+		return &exitCodeError{code: 1, message: fmt.Sprintf("%d tests were skipped (--fail-on-skip)", s.stats.Skip)}
+	case s.flags.failOnNoTests && s.stats.Total == 0:
+		return &exitCodeError{code: 1, message: "no tests were run (--fail-on-no-tests)"}
+	}
+	return nil
 }
 
 // canUseGoCommand returns true if go command is available.
@@ -148,16 +249,11 @@ func (s *spectester) canUseGoCommand() error {
 
 // runTest runs the test command.
 func (s *spectester) runTest() error {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	defer wg.Wait()
-
 	r, w := io.Pipe()
-	defer w.Close() //nolint
 
 	args := append([]string{"test"}, s.args...)
-	if !slices.Contains(args, "-v") {
-		args = append(args, "-v") // This option is required to count the number of tests.
+	if !slices.Contains(args, "-json") {
+		args = append(args, "-json") // The test events are read from the output of test2json.
 	}
 
 	cmd := exec.Command("go", args...) //#nosec
@@ -165,17 +261,13 @@ func (s *spectester) runTest() error {
 	cmd.Stdout = w
 	cmd.Env = os.Environ()
 
-	s.interval.Start()
 	if err := cmd.Start(); err != nil {
-		wg.Done()
 		return err
 	}
 
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go s.consume(&wg, r)
-	defer func() {
-		s.interval.End()
-		s.testResult()
-	}()
 
 	sigc := make(chan os.Signal, 1)
 	done := make(chan struct{})
@@ -183,6 +275,7 @@ func (s *spectester) runTest() error {
 		done <- struct{}{}
 	}()
 	signal.Notify(sigc)
+	defer signal.Stop(sigc)
 
 	go func() {
 		for {
@@ -200,13 +293,22 @@ func (s *spectester) runTest() error {
 		}
 	}()
 
-	if err := cmd.Wait(); err != nil {
-		if _, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
-			return nil
+	err := cmd.Wait()
+	// All events must be parsed before the result is printed.
+	w.Close() //nolint
+	wg.Wait()
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		s.exitCode = exitErr.ExitCode()
+		if s.exitCode < 0 {
+			// The process was killed by a signal.
+			s.exitCode = 1
 		}
-		return err
 	}
-
 	return nil
 }
 
@@ -215,7 +317,11 @@ func (s *spectester) consume(wg *sync.WaitGroup, r io.Reader) {
 	defer wg.Done()
 	reader := bufio.NewReader(r)
 	for {
-		l, _, err := reader.ReadLine()
+		// The events of long output lines are longer than the buffer of the reader.
+		l, err := reader.ReadString('\n')
+		if l != "" {
+			s.parse(strings.TrimSuffix(l, "\n"))
+		}
 		if err == io.EOF {
 			return
 		}
@@ -223,137 +329,244 @@ func (s *spectester) consume(wg *sync.WaitGroup, r io.Reader) {
 			fmt.Fprintln(os.Stderr, err.Error())
 			return
 		}
-		s.parse(string(l))
 	}
 }
 
-// parse parses a line of test output. It updates the test statistics.
+// parse parses a line of 'go test -json' output. It updates the test statistics.
+// The lines that are not test events, e.g. the errors of the go command, are printed to stderr.
 func (s *spectester) parse(line string) {
-	trimmed := strings.TrimSpace(line)
+	event := testEvent{}
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Action == "" {
+		s.parseBuildOutput(line)
+		fmt.Fprintln(os.Stderr, line)
+		return
+	}
 
-	switch {
-	case strings.HasPrefix(trimmed, "ok"):
-		fallthrough
-	case strings.HasPrefix(trimmed, "FAIL"):
-		fallthrough
-	case strings.HasPrefix(trimmed, "PASS"):
-		fallthrough
-	case strings.Contains(trimmed, "[no test files]"):
+	switch event.Action {
+	case "build-output":
+		s.buildOutput[event.ImportPath] = append(s.buildOutput[event.ImportPath], strings.TrimSuffix(event.Output, "\n"))
 		return
+	case "build-fail":
+		return
+	}
 
-	case strings.HasPrefix(trimmed, "=== RUN"):
-		fallthrough
-	case strings.HasPrefix(trimmed, "=== CONT"):
-		fallthrough
-	case strings.HasPrefix(trimmed, "=== PAUSE"):
-		s.allTestMessages = append(s.allTestMessages, line)
+	if event.Test == "" {
+		s.parsePackageEvent(event)
 		return
+	}
+
+	tc := s.testCase(event.Package, event.Test)
+	switch event.Action {
+	case "output":
+		output := strings.TrimSuffix(event.Output, "\n")
+		if !isFramingOutput(output) {
+			tc.Output = append(tc.Output, output)
+		}
 
 	// passed
-	case strings.HasPrefix(trimmed, "--- PASS"):
-		fmt.Fprint(os.Stdout, color.GreenString("."))
+	case "pass":
+		s.progress(color.GreenString("."))
 		atomic.AddInt32(&s.stats.Pass, 1)
 		atomic.StoreInt32(&s.stats.Total, atomic.AddInt32(&s.stats.Total, 1))
-		s.allTestMessages = append(s.allTestMessages, line)
+		tc.Action = event.Action
+		tc.Elapsed = seconds(event.Elapsed)
 
 	// skipped
-	case strings.HasPrefix(trimmed, "--- SKIP"):
-		fmt.Fprint(os.Stdout, color.BlueString("."))
+	case "skip":
+		s.progress(color.BlueString("."))
 		atomic.AddInt32(&s.stats.Skip, 1)
 		atomic.StoreInt32(&s.stats.Total, atomic.AddInt32(&s.stats.Total, 1))
-		s.allTestMessages = append(s.allTestMessages, line)
+		tc.Action = event.Action
+		tc.Elapsed = seconds(event.Elapsed)
 
 	// failed
-	case strings.HasPrefix(trimmed, "--- FAIL"):
-		fmt.Fprint(os.Stdout, color.RedString("."))
+	case "fail":
+		s.progress(color.RedString("."))
 		atomic.AddInt32(&s.stats.Fail, 1)
 		atomic.StoreInt32(&s.stats.Total, atomic.AddInt32(&s.stats.Total, 1))
-		s.allTestMessages = append(s.allTestMessages, line)
+		tc.Action = event.Action
+		tc.Elapsed = seconds(event.Elapsed)
+
+	default:
+		return
+	}
+}
 
+// parseBuildOutput keeps the build output that is not a test event.
+// Before Go 1.24, the build errors are not test events. They are written to stderr like below.
+//
+//	# example.com/b [example.com/b.test]
+//	b_test.go:5:33: undefined: undefined
+func (s *spectester) parseBuildOutput(line string) {
+	if importPath, ok := strings.CutPrefix(line, "# "); ok {
+		s.buildImportPath = importPath
+		return
+	}
+	if s.buildImportPath != "" {
+		s.buildOutput[s.buildImportPath] = append(s.buildOutput[s.buildImportPath], line)
+	}
+}
+
+// failedBuildImportPath returns the import path of the build output of the package that failed to build.
+// It is used before Go 1.24, where the test events do not have FailedBuild. If the package itself has no
+// build output, e.g. one of its dependencies failed to build, the last build output is used.
+func (s *spectester) failedBuildImportPath(pkg string) string {
+	importPaths := make([]string, 0, len(s.buildOutput))
+	for importPath := range s.buildOutput {
+		importPaths = append(importPaths, importPath)
+	}
+	slices.Sort(importPaths)
+	for _, importPath := range importPaths {
+		if importPath == pkg || strings.HasPrefix(importPath, pkg+" ") {
+			return importPath
+		}
+	}
+	if s.buildImportPath != "" {
+		return s.buildImportPath
+	}
+	return pkg
+}
+
+// isBuildFailedOutput returns true if the output line is the summary of the package that failed to build,
+// e.g. "FAIL example.com/b [build failed]".
+func isBuildFailedOutput(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "FAIL") && strings.HasSuffix(trimmed, "[build failed]")
+}
+
+// parsePackageEvent parses the package level event.
+func (s *spectester) parsePackageEvent(event testEvent) {
+	pkg := s.packageResult(event.Package)
+	switch event.Action {
+	case "output":
+		output := strings.TrimSuffix(event.Output, "\n")
+		if isBuildFailedOutput(output) && pkg.FailedBuild == "" {
+			pkg.FailedBuild = s.failedBuildImportPath(pkg.Name)
+		}
+		if !isFramingOutput(output) && !isPackageSummaryOutput(output) {
+			pkg.Output = append(pkg.Output, output)
+		}
+	case "pass", "skip", "fail":
+		pkg.Action = event.Action
+		pkg.Elapsed = seconds(event.Elapsed)
+		if event.FailedBuild != "" {
+			pkg.FailedBuild = event.FailedBuild
+		}
+		if pkg.FailedBuild != "" {
+			atomic.AddInt32(&s.stats.BuildErrors, 1)
+		}
 	default:
-		s.allTestMessages = append(s.allTestMessages, line)
 		return
 	}
 }
 
+// progress prints the progress of the tests. It prints nothing in CI mode.
+func (s *spectester) progress(dot string) {
+	if !s.flags.ci {
+		fmt.Fprint(os.Stdout, dot)
+	}
+}
+
+// packageResult returns the result of the package. It is created at the first event of the package.
+func (s *spectester) packageResult(name string) *packageResult {
+	for _, pkg := range s.packages {
+		if pkg.Name == name {
+			return pkg
+		}
+	}
+	pkg := &packageResult{Name: name, Output: []string{}}
+	s.packages = append(s.packages, pkg)
+	return pkg
+}
+
+// testCase returns the result of the test. It is created at the first event of the test.
+func (s *spectester) testCase(pkg, test string) *testCase {
+	s.packageResult(pkg)
+	if tc, ok := s.testIndex[testKey(pkg, test)]; ok {
+		return tc
+	}
+	tc := &testCase{Package: pkg, Name: test, Output: []string{}}
+	s.testIndex[testKey(pkg, test)] = tc
+	s.tests = append(s.tests, tc)
+	return tc
+}
+
 // testResult prints the test result.
 func (s *spectester) testResult() {
-	if s.stats.Fail > 0 {
+	if s.flags.ci {
+		printPackageResults(s.packages, s.tests)
+	}
+	if flaky := flakyTests(s.tests); len(flaky) > 0 {
+		fmt.Printf("\n[Flaky Tests]\n")
+		for _, name := range flaky {
+			fmt.Printf(" %s\n", color.YellowString(name))
+		}
+	}
+	printTimings(s.packages, s.tests, s.flags.slowest, s.flags.slowTestThreshold)
+	printTestResult(s.stats, s.interval.Duration(), failureMessages(s.packages, s.tests, s.buildOutput))
+}
+
+// printTestResult prints the error messages of the failed tests and the test statistics.
+func printTestResult(stats TestStats, duration time.Duration, failMessages []string) {
+	if len(failMessages) > 0 {
 		fmt.Printf("\n[Error Messages]\n")
-		for _, msg := range extractFailTestMessage(s.allTestMessages) {
+		for _, msg := range failMessages {
 			fmt.Printf(" %s\n", msg)
 		}
 	}
 
 	fmt.Printf("\n[Test Results]\n")
-	fmt.Printf(" - Execution Time: %s\n", s.interval.Duration())
-	fmt.Printf(" - Total         : %d\n", s.stats.Total)
-	fmt.Printf(" - Passed        : %s\n", color.GreenString("%d", s.stats.Pass))
-	if s.stats.Fail == 0 {
-		fmt.Printf(" - Failed        : %d\n", s.stats.Fail)
+	fmt.Printf(" - Execution Time: %s\n", duration)
+	fmt.Printf(" - Total         : %d\n", stats.Total)
+	fmt.Printf(" - Passed        : %s\n", color.GreenString("%d", stats.Pass))
+	if stats.Fail == 0 {
+		fmt.Printf(" - Failed        : %d\n", stats.Fail)
 	} else {
-		fmt.Printf(" - Failed        : %s\n", color.RedString("%d", s.stats.Fail))
+		fmt.Printf(" - Failed        : %s\n", color.RedString("%d", stats.Fail))
 	}
-	if s.stats.Skip == 0 {
-		fmt.Printf(" - Skipped       : %d\n", s.stats.Skip)
+	if stats.Skip == 0 {
+		fmt.Printf(" - Skipped       : %d\n", stats.Skip)
 	} else {
-		fmt.Printf(" - Skipped       : %s\n", color.BlueString("%d", s.stats.Skip))
+		fmt.Printf(" - Skipped       : %s\n", color.BlueString("%d", stats.Skip))
+	}
+	if stats.Flaky > 0 {
+		fmt.Printf(" - Flaky         : %s\n", color.YellowString("%d", stats.Flaky))
+	}
+	if stats.BuildErrors > 0 {
+		fmt.Printf(" - Build Errors  : %s\n", color.RedString("%d", stats.BuildErrors))
 	}
 }
 
-// extractFailTestMessage extracts the error message of the failed test.
-func extractFailTestMessage(testResultMsgs []string) []string {
-	failTestMessages := []string{}
-	beforeRunPos := 0
-	lastFailPos := 0
-	lastRunMsg := ""
-
-	for i, msg := range testResultMsgs {
-		switch {
-		case strings.Contains(msg, "=== RUN"):
-			if lastRunMsg != "" && strings.Contains(msg, fmt.Sprintf("%s/", lastRunMsg)) {
+// printPackageResults prints the result of each package.
+func printPackageResults(packages []*packageResult, tests []*testCase) {
+	fmt.Printf("\n[Package Results]\n")
+	for _, pkg := range packages {
+		stats := TestStats{}
+		for _, tc := range tests {
+			if tc.Package != pkg.Name {
 				continue
 			}
-
-			if beforeRunPos < lastFailPos {
-				for _, v := range testResultMsgs[beforeRunPos:lastFailPos] {
-					if !strings.Contains(v, "--- FAIL") &&
-						!strings.Contains(v, "--- PASS") &&
-						!strings.Contains(v, "--- SKIP") &&
-						!strings.Contains(v, "=== RUN") &&
-						!strings.Contains(v, "=== CONT") &&
-						!strings.Contains(v, "=== PAUSE") {
-						failTestMessages = append(failTestMessages, fmt.Sprintf("    %s", color.RedString(v)))
-					}
-				}
+			switch tc.Action {
+			case "pass":
+				stats.Pass++
+			case "fail":
+				stats.Fail++
+			case "skip":
+				stats.Skip++
 			}
-			lastRunMsg = extractStringBeforeThrash(msg)
-			beforeRunPos = i
-		case strings.Contains(msg, "--- FAIL"):
-			lastFailPos = i
-			failTestMessages = append(failTestMessages, msg)
-		default:
 		}
-	}
 
-	if beforeRunPos < lastFailPos {
-		for _, v := range testResultMsgs[beforeRunPos:lastFailPos] {
-			if !strings.Contains(v, "--- FAIL") &&
-				!strings.Contains(v, "--- PASS") &&
-				!strings.Contains(v, "--- SKIP") &&
-				!strings.Contains(v, "=== RUN") &&
-				!strings.Contains(v, "=== CONT") &&
-				!strings.Contains(v, "=== PAUSE") {
-				failTestMessages = append(failTestMessages, fmt.Sprintf("    %s", color.RedString(v)))
-			}
+		status := "ok"
+		switch {
+		case pkg.FailedBuild != "":
+			status = "BUILD FAIL"
+		case pkg.Action == "fail":
+			status = "FAIL"
+		case pkg.Action == "skip" && stats.Pass+stats.Fail+stats.Skip == 0:
+			fmt.Printf(" %-10s %s [no test files]\n", "?", pkg.Name)
+			continue
 		}
+		fmt.Printf(" %-10s %s (%s) passed: %d, failed: %d, skipped: %d\n",
+			status, pkg.Name, pkg.Elapsed, stats.Pass, stats.Fail, stats.Skip)
 	}
-	return failTestMessages
-}
-
-func extractStringBeforeThrash(s string) string {
-	if index := strings.Index(s, "/"); index != -1 {
-		return s[:index]
-	}
-	return s
 }
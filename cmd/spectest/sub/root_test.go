@@ -2,11 +2,15 @@
 package sub
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/fatih/color"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -35,4 +39,176 @@ func Test_Execute(t *testing.T) {
 			t.Errorf("Execute() mismatch (-want +got):\n%s", diff)
 		}
 	})
+	t.Run("generate coverage", func(t *testing.T) {
+		output := t.TempDir()
+		os.Args = []string{"spectest", "coverage", filepath.Join("testdata", "coverage", "reports"),
+			"--spec", filepath.Join("testdata", "coverage", "openapi.yaml"), "-o", output}
+
+		exitCode := Execute()
+		if exitCode != 0 {
+			t.Fatalf("Execute() = %v, want %v", exitCode, 0)
+		}
+
+		got, err := os.ReadFile(filepath.Join(output, "coverage.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := strings.Join(strings.Fields(string(got)), " ")
+		for _, want := range []string{
+			"2 of 3 operation and status code pairs are covered (66.7%).",
+			"| getUser | GET | /users/{id} | 404 |",
+			"| listUsers | GET | /users | 200 | list_users |",
+		} {
+			if !strings.Contains(report, want) {
+				t.Errorf("coverage.md does not contain %q:\n%s", want, got)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(output, "coverage.json")); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("run spec files", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status": "ok"}`))
+		}))
+		defer server.Close()
+
+		reportDir := t.TempDir()
+		os.Args = []string{"spectest", "run", filepath.Join("testdata", "run"), "--base-url", server.URL, "-r", reportDir}
+		if exitCode := Execute(); exitCode != 0 {
+			t.Fatalf("Execute() = %v, want %v", exitCode, 0)
+		}
+
+		reports, err := filepath.Glob(filepath.Join(reportDir, "*.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 2 {
+			t.Errorf("want 2 markdown reports, got %v", reports)
+		}
+	})
+	t.Run("run spec files that fail", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		os.Args = []string{"spectest", "run", filepath.Join("testdata", "run"), "--base-url", server.URL}
+		if exitCode := Execute(); exitCode != 1 {
+			t.Errorf("Execute() = %v, want %v", exitCode, 1)
+		}
+	})
+}
+
+func Test_spectester_parse(t *testing.T) {
+	s, err := newSpectester(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`{"Action":"start","Package":"example.com/a"}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestA"}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestA/x"}`,
+		`{"Action":"run","Package":"example.com/a","Test":"TestA/y"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestA/x","Output":"=== PAUSE TestA/x\n"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestA/y","Output":"    a_test.go:10: --- PASS: not a result\n"}`,
+		`{"Action":"output","Package":"example.com/a","Test":"TestA/x","Output":"    a_test.go:12: boom\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestA/x","Elapsed":0.5}`,
+		`{"Action":"pass","Package":"example.com/a","Test":"TestA/y","Elapsed":0.25}`,
+		`{"Action":"fail","Package":"example.com/a","Test":"TestA","Elapsed":0.5}`,
+		`{"Action":"skip","Package":"example.com/a","Test":"TestB"}`,
+		`{"Action":"output","Package":"example.com/a","Output":"FAIL\texample.com/a\t0.5s\n"}`,
+		`{"Action":"fail","Package":"example.com/a","Elapsed":0.5}`,
+		`{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-output","Output":"b_test.go:5:33: undefined: undefined\n"}`,
+		`{"ImportPath":"example.com/b [example.com/b.test]","Action":"build-fail"}`,
+		`{"Action":"fail","Package":"example.com/b","Elapsed":0,"FailedBuild":"example.com/b [example.com/b.test]"}`,
+	} {
+		s.parse(line)
+	}
+
+	wantStats := TestStats{Pass: 1, Fail: 2, Skip: 1, Total: 4, BuildErrors: 1}
+	if diff := cmp.Diff(wantStats, s.stats); diff != "" {
+		t.Errorf("stats mismatch (-want +got):\n%s", diff)
+	}
+
+	want := []string{
+		"FAIL example.com/a",
+		"  --- FAIL: TestA (0.50s)",
+		"  --- FAIL: TestA/x (0.50s)",
+		"    " + color.RedString("    a_test.go:12: boom"),
+		"FAIL example.com/b",
+		"    " + color.RedString("b_test.go:5:33: undefined: undefined"),
+	}
+	if diff := cmp.Diff(want, failureMessages(s.packages, s.tests, s.buildOutput)); diff != "" {
+		t.Errorf("failure messages mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"    a_test.go:10: --- PASS: not a result"}, s.testIndex[testKey("example.com/a", "TestA/y")].Output); diff != "" {
+		t.Errorf("output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_spectester_parseBuildFailedBeforeGo124(t *testing.T) {
+	s, err := newSpectester(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Before Go 1.24, the build errors are written to stderr and the events have no FailedBuild.
+	for _, line := range []string{
+		`# example.com/b [example.com/b.test]`,
+		`b_test.go:5:33: undefined: undefined`,
+		`{"Action":"start","Package":"example.com/b"}`,
+		`{"Action":"output","Package":"example.com/b","Output":"FAIL\texample.com/b [build failed]\n"}`,
+		`{"Action":"fail","Package":"example.com/b","Elapsed":0}`,
+	} {
+		s.parse(line)
+	}
+
+	wantStats := TestStats{BuildErrors: 1}
+	if diff := cmp.Diff(wantStats, s.stats); diff != "" {
+		t.Errorf("stats mismatch (-want +got):\n%s", diff)
+	}
+
+	want := []string{
+		"FAIL example.com/b",
+		"    " + color.RedString("b_test.go:5:33: undefined: undefined"),
+	}
+	if diff := cmp.Diff(want, failureMessages(s.packages, s.tests, s.buildOutput)); diff != "" {
+		t.Errorf("failure messages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_spectester_result(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		stats    TestStats
+		wantCode int
+	}{
+		{name: "passed", stats: TestStats{Pass: 1, Total: 1}, wantCode: 0},
+		{name: "exit code of go test is propagated", exitCode: 2, stats: TestStats{BuildErrors: 1}, wantCode: 2},
+		{name: "skipped tests do not fail by default", stats: TestStats{Skip: 1, Total: 1}, wantCode: 0},
+		{name: "fail on skip", args: []string{"--fail-on-skip"}, stats: TestStats{Skip: 1, Total: 1}, wantCode: 1},
+		{name: "fail on no tests", args: []string{"--fail-on-no-tests"}, stats: TestStats{}, wantCode: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSpectester(nil, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.exitCode = tt.exitCode
+			s.stats = tt.stats
+
+			if got := exitCode(s.result()); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
 }
@@ -0,0 +1,185 @@
+package sub
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/specfile"
+	"github.com/spf13/cobra"
+)
+
+// newRunCmd return run command.
+func newRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run declarative spec files written in YAML or JSON",
+		Long: `Run declarative spec files written in YAML or JSON.
+
+The run command reads the spec files (*.yaml, *.yml and *.json) in the target file
+or directory, and sends the request of each spec to the base url. The response is
+checked against the expectation of the spec. If --report-dir is set, the markdown
+report of each spec is generated in the directory.`,
+		RunE:    run,
+		Example: "   spectest run TARGET_DIR --base-url http://localhost:8080",
+	}
+
+	cmd.Flags().StringP("base-url", "b", "", "base url of the system under test (required)")
+	cmd.Flags().StringP("report-dir", "r", "", "output directory of the markdown reports")
+	return cmd
+}
+
+// specRunner is a struct for run command.
+type specRunner struct {
+	// target is a spec file or a directory that has the spec files.
+	target string
+	// baseURL is the url of the system under test.
+	baseURL string
+	// reportDir is a output directory of the markdown reports.
+	reportDir string
+	// stats is the test statistics.
+	stats TestStats
+	// failMessages is the list of messages of the failed specs.
+	failMessages []string
+	// interval is the execution time of the specs.
+	interval *spectest.Interval
+}
+
+// newSpecRunner return specRunner.
+func newSpecRunner(cmd *cobra.Command, args []string) (*specRunner, error) {
+	baseURL, err := cmd.Flags().GetString("base-url")
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		return nil, errors.New("--base-url is required")
+	}
+
+	reportDir, err := cmd.Flags().GetString("report-dir")
+	if err != nil {
+		return nil, err
+	}
+
+	target := "."
+	if len(args) > 0 {
+		target = args[0]
+	}
+
+	return &specRunner{
+		target:       target,
+		baseURL:      baseURL,
+		reportDir:    reportDir,
+		stats:        TestStats{},
+		failMessages: []string{},
+		interval:     spectest.NewInterval(),
+	}, nil
+}
+
+// run runs the spec files.
+func (s *specRunner) run() error {
+	files, err := specfile.Load(s.target)
+	if err != nil {
+		return err
+	}
+
+	runner := specfile.NewRunner().BaseURL(s.baseURL)
+	if s.reportDir != "" {
+		runner.Report(spectest.SequenceReport(spectest.ReportFormatterConfig{
+			Path: s.reportDir,
+			Kind: spectest.ReportKindMarkdown,
+		}))
+	}
+
+	s.interval.Start()
+	for _, f := range files {
+		for _, spec := range f.Specs {
+			s.runSpec(runner, f.Name+"/"+spec.Name, spec)
+		}
+	}
+	s.interval.End()
+
+	printTestResult(s.stats, s.interval.Duration(), s.failMessages)
+	if s.stats.Fail > 0 {
+		return &exitCodeError{code: 1, message: fmt.Sprintf("%d of %d specs failed", s.stats.Fail, s.stats.Total)}
+	}
+	return nil
+}
+
+// runSpec runs the spec, and updates the test statistics.
+func (s *specRunner) runSpec(runner *specfile.Runner, name string, spec specfile.Spec) {
+	t := &specT{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		// Fatal stops the goroutine like testing.T.FailNow.
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("panic: %v", r)
+			}
+		}()
+		runner.Run(t, spec)
+	}()
+	wg.Wait()
+
+	s.stats.Total++
+	if !t.failed() {
+		fmt.Fprint(os.Stdout, color.GreenString("."))
+		s.stats.Pass++
+		return
+	}
+	fmt.Fprint(os.Stdout, color.RedString("."))
+	s.stats.Fail++
+	s.failMessages = append(s.failMessages, fmt.Sprintf("--- FAIL: %s", name))
+	for _, msg := range t.messages {
+		for _, line := range strings.Split(msg, "\n") {
+			s.failMessages = append(s.failMessages, fmt.Sprintf("    %s", color.RedString(line)))
+		}
+	}
+}
+
+// specT is a spectest.TestingT that collects the failure messages of a spec.
+type specT struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+// Errorf records the failure message.
+func (t *specT) Errorf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, fmt.Sprintf(format, args...))
+}
+
+// Fatal records the failure message, and stops the spec.
+func (t *specT) Fatal(args ...interface{}) {
+	t.Errorf("%s", fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+// Fatalf records the failure message, and stops the spec.
+func (t *specT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// failed returns true if the spec failed.
+func (t *specT) failed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.messages) > 0
+}
+
+// run runs the declarative spec files.
+func run(cmd *cobra.Command, args []string) error {
+	s, err := newSpecRunner(cmd, args)
+	if err != nil {
+		return fmt.Errorf("failed to initialize run command: %w", err)
+	}
+	return s.run()
+}
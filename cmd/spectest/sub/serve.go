@@ -0,0 +1,500 @@
+package sub
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nao1215/spectest"
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd return serve command.
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Browse the generated reports in a local web server",
+		Long: `Browse the generated reports in a local web server.
+
+The serve command starts a web server that shows a searchable index of the reports
+(HTML, Markdown, HAR and JSON) in the target directory. The index can be filtered by the
+method, path, status code, test name and result of the report. The markdown reports
+are rendered as HTML. The index is read from the meta data of the reports each time
+the page is loaded, so the reports generated after the server started are shown too.`,
+		RunE:    serve,
+		Example: "   spectest serve TARGET_DIR --addr localhost:8080",
+	}
+
+	cmd.Flags().StringP("addr", "a", "localhost:8080", "address of the web server")
+	return cmd
+}
+
+// reportServer is a struct for serve command.
+type reportServer struct {
+	// dir is the directory that has the reports.
+	dir string
+	// addr is the address of the web server.
+	addr string
+}
+
+// newReportServer return reportServer.
+func newReportServer(cmd *cobra.Command, args []string) (*reportServer, error) {
+	addr, err := cmd.Flags().GetString("addr")
+	if err != nil {
+		return nil, err
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	return &reportServer{
+		dir:  dir,
+		addr: addr,
+	}, nil
+}
+
+// run starts the web server.
+func (s *reportServer) run() error {
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("serving the reports in %s at http://%s\n", s.dir, s.addr)
+	return server.ListenAndServe()
+}
+
+// handler returns the handler of the web server.
+// "/" is the index of the reports, and "/reports/" serves the reports in the directory.
+func (s *reportServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/reports/", s.report)
+	return mux
+}
+
+// reportFilter is the filter of the index. The empty field matches any report.
+type reportFilter struct {
+	// Query matches the test name, method, path or file name of the report.
+	Query string
+	// Name matches the test name of the report.
+	Name string
+	// Method is the http method of the report.
+	Method string
+	// Path matches the http request path of the report.
+	Path string
+	// Status is the status code (e.g. 200) or the class of the status code (e.g. 4xx).
+	Status string
+	// Result is passed, failed or unknown.
+	Result string
+}
+
+// newReportFilter returns the filter in the query string.
+func newReportFilter(r *http.Request) reportFilter {
+	q := r.URL.Query()
+	return reportFilter{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Name:   strings.TrimSpace(q.Get("name")),
+		Method: strings.ToUpper(strings.TrimSpace(q.Get("method"))),
+		Path:   strings.TrimSpace(q.Get("path")),
+		Status: strings.ToLower(strings.TrimSpace(q.Get("status"))),
+		Result: strings.ToLower(strings.TrimSpace(q.Get("result"))),
+	}
+}
+
+// match returns true if the report matches the filter.
+func (f reportFilter) match(r indexEntry) bool {
+	if f.Query != "" && !containsFold(r.Name+" "+r.Method+" "+r.Path+" "+r.File, f.Query) {
+		return false
+	}
+	if f.Name != "" && !containsFold(r.Name, f.Name) {
+		return false
+	}
+	if f.Method != "" && r.Method != f.Method {
+		return false
+	}
+	if f.Path != "" && !containsFold(r.Path, f.Path) {
+		return false
+	}
+	if f.Status != "" && !matchStatus(r.StatusCode, f.Status) {
+		return false
+	}
+	if f.Result != "" && r.Result != f.Result {
+		return false
+	}
+	return true
+}
+
+// containsFold returns true if s contains substr, ignoring the case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// matchStatus returns true if the status code matches the status code (e.g. 200) or the class of the status code (e.g. 4xx).
+func matchStatus(code int, status string) bool {
+	if len(status) == 3 && strings.HasSuffix(status, "xx") {
+		return code/100 == int(status[0]-'0')
+	}
+	return strconv.Itoa(code) == status
+}
+
+// indexEntry is a row of the index.
+type indexEntry struct {
+	// File is the path of the report relative to the directory, with slashes.
+	File string
+	// Kind is HTML, Markdown, HAR or JSON.
+	Kind string
+	// Name is the test name of the report.
+	Name string
+	// Method is the http method of the report.
+	Method string
+	// Path is the http request path of the report.
+	Path string
+	// StatusCode is the final status code of the report. It is zero if the report has no meta data.
+	StatusCode int
+	// Duration is the duration of the report.
+	Duration time.Duration
+	// Result is passed, failed or unknown.
+	Result string
+}
+
+// reportResult returns the result of the report. It is unknown if the report does not record the result.
+func reportResult(meta spectest.Meta) string {
+	switch {
+	case meta.Passed == nil:
+		return "unknown"
+	case *meta.Passed:
+		return "passed"
+	default:
+		return "failed"
+	}
+}
+
+// reportKindNames is the name of each report kind shown in the index.
+var reportKindNames = map[spectest.ReportKind]string{
+	spectest.ReportKindHTML:     "HTML",
+	spectest.ReportKindMarkdown: "Markdown",
+	spectest.ReportKindHAR:      "HAR",
+	spectest.ReportKindJSON:     "JSON",
+}
+
+// indexEntries returns the reports in the directory that match the filter.
+func (s *reportServer) indexEntries(filter reportFilter) ([]indexEntry, error) {
+	reports, err := spectest.ReadReportFiles(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []indexEntry{}
+	for _, r := range reports {
+		rel, err := filepath.Rel(s.dir, r.Path)
+		if err != nil {
+			return nil, err
+		}
+		entry := indexEntry{
+			File:       filepath.ToSlash(rel),
+			Kind:       reportKindNames[r.Kind],
+			Name:       r.Meta.Name,
+			Method:     strings.ToUpper(r.Meta.Method),
+			Path:       r.Meta.Path,
+			StatusCode: r.Meta.StatusCode,
+			Duration:   time.Duration(r.Meta.Duration).Round(time.Millisecond),
+			Result:     reportResult(r.Meta),
+		}
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// index shows the index of the reports.
+func (s *reportServer) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	filter := newReportFilter(r)
+	entries, err := s.indexEntries(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, struct {
+		Dir     string
+		Filter  reportFilter
+		Entries []indexEntry
+		Methods []string
+		Results []string
+	}{
+		Dir:     s.dir,
+		Filter:  filter,
+		Entries: entries,
+		Methods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions},
+		Results: []string{"passed", "failed", "unknown"},
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// report serves the report. The markdown report is rendered as HTML unless the query has "raw".
+func (s *reportServer) report(w http.ResponseWriter, r *http.Request) {
+	// path.Clean with the leading slash removes "..", so the file is always in the directory.
+	rel := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/reports/"))
+	file := filepath.Join(s.dir, filepath.FromSlash(rel))
+	if path.Ext(rel) != ".md" || r.URL.Query().Has("raw") {
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := markdownTemplate.Execute(w, struct {
+		File string
+		Body template.HTML
+	}{
+		File: strings.TrimPrefix(rel, "/"),
+		Body: template.HTML(renderMarkdown(string(data))), //nolint:gosec // every text in the markdown is escaped.
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var (
+	// markdownHeading is a heading of the markdown. e.g. "## title"
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	// markdownInline is an image or a link of the markdown. e.g. ![alt](src) or [text](href)
+	markdownInline = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]*)\)`)
+	// markdownTableDelimiter is the delimiter row between the header and the body of a table. e.g. |---|:--:|
+	markdownTableDelimiter = regexp.MustCompile(`^\|[\s:|-]+\|$`)
+)
+
+// renderMarkdown renders the markdown written by the markdown reports and the index command as HTML.
+// It supports the headings, the lists, the tables, the code blocks, the images, the links,
+// the horizontal rules and the line breaks. The mermaid code block is rendered by mermaid.js.
+func renderMarkdown(src string) string {
+	var b strings.Builder
+	inList := false
+	closeList := func() {
+		if inList {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if lang, ok := strings.CutPrefix(trimmed, "```"); ok {
+			closeList()
+			code := []string{}
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			if lang == "mermaid" {
+				fmt.Fprintf(&b, "<pre class=\"mermaid\">%s</pre>\n", html.EscapeString(strings.Join(code, "\n")))
+				continue
+			}
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "|") {
+			closeList()
+			table := []string{}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				table = append(table, strings.TrimSpace(lines[i]))
+			}
+			i--
+			b.WriteString(renderTable(table))
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			closeList()
+		case trimmed == "---":
+			closeList()
+			b.WriteString("<hr>\n")
+		case markdownHeading.MatchString(trimmed):
+			closeList()
+			m := markdownHeading.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2]), len(m[1]))
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", renderInline(trimmed[2:]))
+		default:
+			closeList()
+			b.WriteString(renderInline(trimmed))
+			if strings.HasSuffix(line, "  ") {
+				b.WriteString("<br>")
+			}
+			b.WriteString("\n")
+		}
+	}
+	closeList()
+	return b.String()
+}
+
+// renderTable renders the rows of the table. The first row is the header, and the delimiter row is skipped.
+func renderTable(rows []string) string {
+	var b strings.Builder
+	b.WriteString("<table>\n")
+	for i, row := range rows {
+		if markdownTableDelimiter.MatchString(row) {
+			continue
+		}
+		tag := "td"
+		if i == 0 {
+			tag = "th"
+		}
+		b.WriteString("<tr>")
+		for _, cell := range strings.Split(strings.Trim(row, "|"), "|") {
+			fmt.Fprintf(&b, "<%s>%s</%s>", tag, renderInline(strings.TrimSpace(cell)), tag)
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// renderInline renders the images and the links in the text, and escapes the rest of the text.
+// The link to a markdown file is kept, so the linked report is rendered too.
+func renderInline(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownInline.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		label := html.EscapeString(text[m[4]:m[5]])
+		target := html.EscapeString(text[m[6]:m[7]])
+		if strings.HasPrefix(strings.ToLower(text[m[6]:m[7]]), "javascript:") {
+			target = ""
+		}
+		if m[3] > m[2] {
+			fmt.Fprintf(&b, `<img src="%s" alt="%s">`, target, label)
+		} else {
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, target, label)
+		}
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// serveStyle is the style sheet of the pages. It is inlined, so the pages work offline.
+const serveStyle = `
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+a { color: #0969da; }
+form { display: flex; flex-wrap: wrap; gap: 0.5em; margin-bottom: 1em; }
+input, select, button { padding: 0.3em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #d0d7de; padding: 0.4em 0.6em; text-align: left; }
+th { background: #f6f8fa; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; }
+.unknown { color: #57606a; }
+`
+
+// indexTemplate is the template of the index page.
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>spectest reports</title>
+<style>` + serveStyle + `</style>
+</head>
+<body>
+<h1>spectest reports</h1>
+<p>{{ .Dir }}</p>
+<form method="get" action="/">
+<input type="search" name="q" placeholder="search" value="{{ .Filter.Query }}">
+<input type="text" name="name" placeholder="test name" value="{{ .Filter.Name }}">
+<select name="method">
+<option value="">any method</option>
+{{- range $m := .Methods }}
+<option value="{{ $m }}"{{ if eq $m $.Filter.Method }} selected{{ end }}>{{ $m }}</option>
+{{- end }}
+</select>
+<input type="text" name="path" placeholder="path" value="{{ .Filter.Path }}">
+<input type="text" name="status" placeholder="status (200, 4xx)" value="{{ .Filter.Status }}">
+<select name="result">
+<option value="">any result</option>
+{{- range $r := .Results }}
+<option value="{{ $r }}"{{ if eq $r $.Filter.Result }} selected{{ end }}>{{ $r }}</option>
+{{- end }}
+</select>
+<button type="submit">filter</button>
+<a href="/">clear</a>
+</form>
+<table>
+<thead><tr><th>Result</th><th>Method</th><th>Path</th><th>Status</th><th>Name</th><th>Duration</th><th>Report</th></tr></thead>
+<tbody>
+{{- range .Entries }}
+<tr>
+<td class="{{ .Result }}">{{ .Result }}</td>
+<td>{{ .Method }}</td>
+<td>{{ .Path }}</td>
+<td>{{ if .StatusCode }}{{ .StatusCode }}{{ end }}</td>
+<td>{{ .Name }}</td>
+<td>{{ if .Duration }}{{ .Duration }}{{ end }}</td>
+<td><a href="/reports/{{ .File }}">{{ .File }}</a> ({{ .Kind }})</td>
+</tr>
+{{- else }}
+<tr><td colspan="7">no reports found</td></tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// markdownTemplate is the template of the rendered markdown report.
+var markdownTemplate = template.Must(template.New("markdown").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .File }}</title>
+<style>` + serveStyle + `</style>
+</head>
+<body>
+<p><a href="/">index</a> / {{ .File }} (<a href="?raw">raw</a>)</p>
+{{ .Body }}
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: true });
+</script>
+</body>
+</html>
+`))
+
+// serve starts the web server to browse the reports.
+func serve(cmd *cobra.Command, args []string) error {
+	s, err := newReportServer(cmd, args)
+	if err != nil {
+		return fmt.Errorf("failed to initialize serve command: %w", err)
+	}
+	return s.run()
+}
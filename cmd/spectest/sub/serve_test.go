@@ -0,0 +1,163 @@
+package sub
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTestReportServer returns the test server for the reports in the directory.
+func newTestReportServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer((&reportServer{dir: dir}).handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get returns the status code and the body of the response.
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url) //nolint:gosec,noctx // url of the test server
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func Test_reportServer_index(t *testing.T) {
+	server := newTestReportServer(t, map[string]string{
+		"get_user.md":                 "## GET /users/1",
+		"get_user.meta.json":          `{"method":"get","name":"get user","passed":true,"path":"/users/1","report_file_name":"get_user","status_code":200}`,
+		"users/create_user.md":        "## POST /users",
+		"users/create_user.meta.json": `{"method":"post","name":"create user","path":"/users","report_file_name":"create_user","status_code":201}`,
+		"missing_user.html":           `<html><script type="application/json" id="metaJson">{"method":"get","name":"missing user","passed":false,"path":"/users/2","status_code":404}</script></html>`,
+		"index.md":                    "# index",
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "all reports", query: "", want: []string{"get_user.md", "index.md", "missing_user.html", "users/create_user.md"}},
+		{name: "search", query: "?q=USERS/1", want: []string{"get_user.md"}},
+		{name: "test name", query: "?name=user", want: []string{"get_user.md", "missing_user.html", "users/create_user.md"}},
+		{name: "method", query: "?method=post", want: []string{"users/create_user.md"}},
+		{name: "path", query: "?path=/users/", want: []string{"get_user.md", "missing_user.html"}},
+		{name: "status code", query: "?status=404", want: []string{"missing_user.html"}},
+		{name: "status class", query: "?status=2xx", want: []string{"get_user.md", "users/create_user.md"}},
+		{name: "failed", query: "?result=failed", want: []string{"missing_user.html"}},
+		{name: "passed", query: "?result=passed", want: []string{"get_user.md"}},
+		{name: "unknown result", query: "?result=unknown", want: []string{"index.md", "users/create_user.md"}},
+		{name: "combined", query: "?method=GET&status=2xx", want: []string{"get_user.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(t, server.URL+"/"+tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("unexpected status code %d: %s", code, body)
+			}
+			got := []string{}
+			for _, line := range strings.Split(body, "\n") {
+				if _, link, ok := strings.Cut(line, `<a href="/reports/`); ok {
+					file, _, _ := strings.Cut(link, `"`)
+					got = append(got, file)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("index mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_reportServer_report(t *testing.T) {
+	server := newTestReportServer(t, map[string]string{
+		"get.md": "## GET /image\n![Badge](https://img.shields.io/badge/200-green)\n  \n```mermaid\nsequenceDiagram\n    cli->>sut: GET /image\n```\n" +
+			"#### Event 1\n  \nGET /image HTTP/1.1  \nHost: <sut>  \n  \n---\n- [index](index.md)\n\n" +
+			"| Status | Report |\n|--------|--------|\n| ![200](https://img.shields.io/badge/200-green) | [get](get.md) |\n",
+		"get.png": "png",
+	})
+
+	t.Run("render markdown", func(t *testing.T) {
+		code, body := get(t, server.URL+"/reports/get.md")
+		if code != http.StatusOK {
+			t.Fatalf("unexpected status code %d: %s", code, body)
+		}
+		for _, want := range []string{
+			"<h2>GET /image</h2>",
+			`<img src="https://img.shields.io/badge/200-green" alt="Badge">`,
+			"<pre class=\"mermaid\">sequenceDiagram\n    cli-&gt;&gt;sut: GET /image</pre>",
+			"<h4>Event 1</h4>",
+			"GET /image HTTP/1.1<br>",
+			"Host: &lt;sut&gt;<br>",
+			"<hr>",
+			`<ul>` + "\n" + `<li><a href="index.md">index</a></li>`,
+			"<table>\n<tr><th>Status</th><th>Report</th></tr>\n" +
+				`<tr><td><img src="https://img.shields.io/badge/200-green" alt="200"></td><td><a href="get.md">get</a></td></tr>` + "\n</table>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("rendered markdown does not contain %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("raw markdown", func(t *testing.T) {
+		_, body := get(t, server.URL+"/reports/get.md?raw")
+		if !strings.HasPrefix(body, "## GET /image\n") {
+			t.Errorf("unexpected raw markdown: %s", body)
+		}
+	})
+
+	t.Run("image", func(t *testing.T) {
+		if _, body := get(t, server.URL+"/reports/get.png"); body != "png" {
+			t.Errorf("unexpected image: %s", body)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if code, _ := get(t, server.URL+"/reports/missing.md"); code != http.StatusNotFound {
+			t.Errorf("unexpected status code %d", code)
+		}
+	})
+
+	t.Run("outside of the directory", func(t *testing.T) {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "reports")
+		if err := os.Mkdir(dir, 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(parent, "secret.md"), []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/reports/x", nil)
+		req.URL.Path = "/reports/../secret.md"
+		rec := httptest.NewRecorder()
+		(&reportServer{dir: dir}).report(rec, req)
+		if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "secret") {
+			t.Errorf("served a file outside of the directory: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
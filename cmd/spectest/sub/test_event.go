@@ -0,0 +1,125 @@
+package sub
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// testEvent is an event of 'go test -json'. See 'go doc test2json' for the details.
+type testEvent struct {
+	// Time is the time of the event.
+	Time time.Time `json:"Time"`
+	// Action is the kind of the event. e.g. run, pass, fail, skip, output, build-output
+	Action string `json:"Action"`
+	// Package is the import path of the package being tested.
+	Package string `json:"Package"`
+	// Test is the name of the test. It is empty for the package level events.
+	Test string `json:"Test"`
+	// Elapsed is the seconds elapsed by the test or the package.
+	Elapsed float64 `json:"Elapsed"`
+	// Output is the output of the test, the package or the build.
+	Output string `json:"Output"`
+	// ImportPath is the import path of the package being built. It is set on the build events.
+	ImportPath string `json:"ImportPath"`
+	// FailedBuild is the import path of the package that failed to build. It is set since Go 1.24.
+	// Before Go 1.24, the build failure is detected by the "[build failed]" output of the package.
+	FailedBuild string `json:"FailedBuild"`
+}
+
+// testCase is the result of a test or a subtest.
+type testCase struct {
+	// Package is the import path of the package of the test.
+	Package string
+	// Name is the name of the test. e.g. TestUser/get_user
+	Name string
+	// Action is the result of the test: pass, fail or skip. It is empty while the test is running.
+	Action string
+	// Elapsed is the execution time of the test.
+	Elapsed time.Duration
+	// Output is the output of the test without the "=== RUN" and "--- PASS" lines.
+	Output []string
+	// Flaky is true if the test failed and then passed on retry.
+	Flaky bool
+	// Attempts is the number of the re-runs of the test.
+	Attempts int
+}
+
+// packageResult is the result of a package.
+type packageResult struct {
+	// Name is the import path of the package.
+	Name string
+	// Action is the result of the package: pass, fail or skip.
+	Action string
+	// Elapsed is the execution time of the package.
+	Elapsed time.Duration
+	// Output is the package level output, e.g. a panic outside of the tests.
+	Output []string
+	// FailedBuild is the import path of the package that failed to build.
+	FailedBuild string
+}
+
+// testKey returns the key of the test in the package.
+func testKey(pkg, test string) string {
+	return pkg + "\x00" + test
+}
+
+// seconds converts the elapsed seconds of the event to time.Duration.
+func seconds(elapsed float64) time.Duration {
+	return time.Duration(elapsed * float64(time.Second))
+}
+
+// isFramingOutput returns true if the output line is written by the testing package to
+// frame the test output, e.g. "=== RUN" or "--- PASS". The result is already in the event.
+func isFramingOutput(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPackageSummaryOutput returns true if the output line is the summary of the package, e.g. "ok  pkg 0.1s".
+func isPackageSummaryOutput(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "PASS" || trimmed == "FAIL" ||
+		strings.HasPrefix(trimmed, "ok ") || strings.HasPrefix(trimmed, "ok\t") ||
+		strings.HasPrefix(trimmed, "FAIL\t") || strings.HasPrefix(trimmed, "?")
+}
+
+// failureMessages returns the messages of the failed tests and packages grouped by package.
+func failureMessages(packages []*packageResult, tests []*testCase, buildOutput map[string][]string) []string {
+	messages := []string{}
+	for _, pkg := range packages {
+		if pkg.Action != "fail" {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("FAIL %s", pkg.Name))
+
+		failedTests := 0
+		for _, tc := range tests {
+			if tc.Package != pkg.Name || tc.Action != "fail" {
+				continue
+			}
+			failedTests++
+			messages = append(messages, fmt.Sprintf("  --- FAIL: %s (%.2fs)", tc.Name, tc.Elapsed.Seconds()))
+			for _, line := range tc.Output {
+				messages = append(messages, fmt.Sprintf("    %s", color.RedString(line)))
+			}
+		}
+		if failedTests > 0 {
+			continue
+		}
+
+		// The package failed outside of the tests, e.g. a build error or a panic in TestMain.
+		output, _ := packageFailureOutput(pkg, tests, buildOutput)
+		for _, line := range output {
+			messages = append(messages, fmt.Sprintf("    %s", color.RedString(line)))
+		}
+	}
+	return messages
+}
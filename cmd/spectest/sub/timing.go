@@ -0,0 +1,98 @@
+package sub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// leafTests returns the finished tests that have no subtests. The elapsed time of a parent
+// test includes its subtests, so only the leaves are compared.
+func leafTests(tests []*testCase) []*testCase {
+	parents := map[string]bool{}
+	for _, tc := range tests {
+		if i := strings.LastIndex(tc.Name, "/"); i >= 0 {
+			parents[testKey(tc.Package, tc.Name[:i])] = true
+		}
+	}
+
+	leaves := []*testCase{}
+	for _, tc := range tests {
+		if tc.Action == "" || tc.Action == "skip" || parents[testKey(tc.Package, tc.Name)] {
+			continue
+		}
+		leaves = append(leaves, tc)
+	}
+	return leaves
+}
+
+// slowestTests returns the n slowest tests in descending order of the elapsed time.
+func slowestTests(tests []*testCase, n int) []*testCase {
+	leaves := leafTests(tests)
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return leaves[i].Elapsed > leaves[j].Elapsed
+	})
+	if len(leaves) > n {
+		leaves = leaves[:n]
+	}
+	return leaves
+}
+
+// testsOverThreshold returns the tests that took longer than the threshold.
+func testsOverThreshold(tests []*testCase, threshold time.Duration) []*testCase {
+	over := []*testCase{}
+	if threshold <= 0 {
+		return over
+	}
+	for _, tc := range leafTests(tests) {
+		if tc.Elapsed > threshold {
+			over = append(over, tc)
+		}
+	}
+	return over
+}
+
+// packageDurations returns the packages that ran tests in descending order of the elapsed time.
+func packageDurations(packages []*packageResult) []*packageResult {
+	durations := []*packageResult{}
+	for _, pkg := range packages {
+		if pkg.Action == "" || (pkg.Action == "skip" && pkg.Elapsed == 0) {
+			// The package has no test files.
+			continue
+		}
+		durations = append(durations, pkg)
+	}
+	sort.SliceStable(durations, func(i, j int) bool {
+		return durations[i].Elapsed > durations[j].Elapsed
+	})
+	return durations
+}
+
+// printTimings prints the slowest tests, the execution time of each package and the tests over the threshold.
+func printTimings(packages []*packageResult, tests []*testCase, slowest int, threshold time.Duration) {
+	if slowest > 0 {
+		if slow := slowestTests(tests, slowest); len(slow) > 0 {
+			fmt.Printf("\n[Slowest Tests]\n")
+			for _, tc := range slow {
+				fmt.Printf(" %10s %s %s\n", tc.Elapsed.Round(time.Millisecond), tc.Package, tc.Name)
+			}
+		}
+
+		if durations := packageDurations(packages); len(durations) > 0 {
+			fmt.Printf("\n[Package Times]\n")
+			for _, pkg := range durations {
+				fmt.Printf(" %10s %s\n", pkg.Elapsed.Round(time.Millisecond), pkg.Name)
+			}
+		}
+	}
+
+	if over := testsOverThreshold(tests, threshold); len(over) > 0 {
+		fmt.Printf("\n[Tests Over Threshold (%s)]\n", threshold)
+		for _, tc := range over {
+			fmt.Printf(" %s\n", color.RedString("%10s %s %s", tc.Elapsed.Round(time.Millisecond), tc.Package, tc.Name))
+		}
+	}
+}
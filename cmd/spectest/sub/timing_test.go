@@ -0,0 +1,75 @@
+package sub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// timingEvents are the test events of two packages with the subtests.
+var timingEvents = []string{
+	`{"Action":"pass","Package":"example.com/a","Test":"TestParent/fast","Elapsed":0.1}`,
+	`{"Action":"pass","Package":"example.com/a","Test":"TestParent/slow","Elapsed":2.5}`,
+	`{"Action":"pass","Package":"example.com/a","Test":"TestParent","Elapsed":2.6}`,
+	`{"Action":"fail","Package":"example.com/a","Test":"TestFailed","Elapsed":1.2}`,
+	`{"Action":"skip","Package":"example.com/a","Test":"TestSkipped","Elapsed":9}`,
+	`{"Action":"fail","Package":"example.com/a","Elapsed":3.9}`,
+	`{"Action":"pass","Package":"example.com/b","Test":"TestB","Elapsed":0.5}`,
+	`{"Action":"pass","Package":"example.com/b","Elapsed":0.6}`,
+	`{"Action":"skip","Package":"example.com/c"}`,
+}
+
+// names returns the names of the tests. e.g. "example.com/a TestA"
+func names(tests []*testCase) []string {
+	got := []string{}
+	for _, tc := range tests {
+		got = append(got, tc.Package+" "+tc.Name)
+	}
+	return got
+}
+
+func Test_slowestTests(t *testing.T) {
+	s := newParsedSpectester(t, nil, timingEvents)
+
+	want := []string{"example.com/a TestParent/slow", "example.com/a TestFailed", "example.com/b TestB"}
+	if diff := cmp.Diff(want, names(slowestTests(s.tests, 3))); diff != "" {
+		t.Errorf("slowestTests() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_testsOverThreshold(t *testing.T) {
+	s := newParsedSpectester(t, nil, timingEvents)
+
+	want := []string{"example.com/a TestParent/slow", "example.com/a TestFailed"}
+	if diff := cmp.Diff(want, names(testsOverThreshold(s.tests, time.Second))); diff != "" {
+		t.Errorf("testsOverThreshold() mismatch (-want +got):\n%s", diff)
+	}
+	if got := testsOverThreshold(s.tests, 0); len(got) != 0 {
+		t.Errorf("testsOverThreshold() with no threshold = %v, want empty", names(got))
+	}
+}
+
+func Test_packageDurations(t *testing.T) {
+	s := newParsedSpectester(t, nil, timingEvents)
+
+	got := []string{}
+	for _, pkg := range packageDurations(s.packages) {
+		got = append(got, pkg.Name+" "+pkg.Elapsed.String())
+	}
+	if diff := cmp.Diff([]string{"example.com/a 3.9s", "example.com/b 600ms"}, got); diff != "" {
+		t.Errorf("packageDurations() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_spectester_result_slowTestThreshold(t *testing.T) {
+	s := newParsedSpectester(t, []string{"--slow-test-threshold=2s"}, timingEvents)
+	if got := exitCode(s.result()); got != 1 {
+		t.Errorf("exit code = %d, want 1", got)
+	}
+
+	s = newParsedSpectester(t, []string{"--slow-test-threshold=3s"}, timingEvents)
+	if got := exitCode(s.result()); got != 0 {
+		t.Errorf("exit code = %d, want 0", got)
+	}
+}
@@ -0,0 +1,465 @@
+package sub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newWatchCmd return watch command.
+func newWatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch [packages] [-- go test flags]",
+		Short: "Re-run the tests of the packages whenever their files change",
+		Long: `Re-run the tests of the packages whenever their files change.
+
+The watch command watches the Go files and the testdata directory (including golden files)
+of the selected packages. When the files change, it clears the screen and re-runs the tests
+of the changed packages and the selected packages that depend on them with the spectest wrapper.
+Bursts of saves are debounced. The arguments after "--" are passed to 'go test'.
+
+While watching, enter the following keys and press Enter:
+  f: re-run the failed tests only
+  a: re-run all the selected packages
+  q: quit`,
+		RunE:    watch,
+		Example: "   spectest watch ./... -- -race",
+	}
+
+	cmd.Flags().DurationP("interval", "i", 500*time.Millisecond, "interval to check the files for changes")
+	cmd.Flags().DurationP("debounce", "d", 300*time.Millisecond, "quiet period after the last change before the tests run")
+	return cmd
+}
+
+// watchedPackage is a package watched by the watch command. The fields are read from 'go list -json'.
+type watchedPackage struct {
+	// ImportPath is the import path of the package.
+	ImportPath string
+	// Dir is the directory of the package.
+	Dir string
+	// Deps is the list of the import paths of the dependencies of the package.
+	Deps []string
+	// TestImports is the list of the import paths imported by the internal tests of the package.
+	TestImports []string
+	// XTestImports is the list of the import paths imported by the external tests of the package.
+	XTestImports []string
+}
+
+// dependsOn returns true if the package or its tests import one of the owners.
+// The dependencies of the test imports are followed if they are selected packages.
+func (p *watchedPackage) dependsOn(owners map[string]bool, selected map[string]*watchedPackage) bool {
+	for _, dep := range p.Deps {
+		if owners[dep] {
+			return true
+		}
+	}
+	for _, imports := range [][]string{p.TestImports, p.XTestImports} {
+		for _, imp := range imports {
+			if owners[imp] {
+				return true
+			}
+			if pkg, ok := selected[imp]; ok && pkg != p {
+				for _, dep := range pkg.Deps {
+					if owners[dep] {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fileStamp is the state of a file used to detect changes.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watcher is a struct for watch command.
+type watcher struct {
+	// patterns is the list of the package patterns. e.g. ./...
+	patterns []string
+	// goTestArgs is the list of the arguments passed to 'go test'.
+	goTestArgs []string
+	// interval is the interval to check the files for changes.
+	interval time.Duration
+	// debounce is the quiet period after the last change before the tests run.
+	debounce time.Duration
+	// packages is the list of the selected packages.
+	packages []*watchedPackage
+	// failed is the list of the failed top level tests of each package in the last run.
+	failed map[string][]string
+	// out is the output of the watch command.
+	out io.Writer
+}
+
+// newWatcher return watcher.
+func newWatcher(cmd *cobra.Command, args []string) (*watcher, error) {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.New("--interval must be positive")
+	}
+	debounce, err := cmd.Flags().GetDuration("debounce")
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := args
+	goTestArgs := []string{}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		patterns = args[:dash]
+		goTestArgs = args[dash:]
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	return &watcher{
+		patterns:   patterns,
+		goTestArgs: goTestArgs,
+		interval:   interval,
+		debounce:   debounce,
+		failed:     map[string][]string{},
+		out:        os.Stdout,
+	}, nil
+}
+
+// run watches the files, and runs the tests until the user quits.
+func (w *watcher) run() error {
+	if err := w.loadPackages(); err != nil {
+		return err
+	}
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
+
+	w.test(w.importPaths(), nil)
+	snapshot := w.scan()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sigc:
+			return nil
+		case key, ok := <-keys:
+			if !ok {
+				// stdin is closed. e.g. the command runs in the background.
+				keys = nil
+				continue
+			}
+			switch key {
+			case "q":
+				return nil
+			case "a":
+				w.test(w.importPaths(), nil)
+			case "f":
+				w.testFailed()
+			}
+			snapshot = w.scan()
+		case <-ticker.C:
+			changed := changedFiles(snapshot, w.scan())
+			if len(changed) == 0 {
+				continue
+			}
+			changed, snapshot = w.settle(changed)
+
+			// New files may add or remove packages.
+			if err := w.loadPackages(); err != nil {
+				fmt.Fprintln(w.out, err.Error())
+				continue
+			}
+			if affected := w.affected(changed); len(affected) > 0 {
+				w.test(affected, changed)
+			}
+			snapshot = w.scan()
+		}
+	}
+}
+
+// settle waits until the files stop changing for the debounce period, and returns all changed files.
+func (w *watcher) settle(changed []string) ([]string, map[string]fileStamp) {
+	all := map[string]bool{}
+	for _, path := range changed {
+		all[path] = true
+	}
+
+	snapshot := w.scan()
+	for {
+		time.Sleep(w.debounce)
+		next := w.scan()
+		more := changedFiles(snapshot, next)
+		if len(more) == 0 {
+			break
+		}
+		for _, path := range more {
+			all[path] = true
+		}
+		snapshot = next
+	}
+
+	paths := make([]string, 0, len(all))
+	for path := range all {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, snapshot
+}
+
+// loadPackages lists the selected packages with 'go list -json'.
+func (w *watcher) loadPackages() error {
+	args := append([]string{"list", "-json"}, w.patterns...)
+	cmd := exec.Command("go", args...) //#nosec
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w: %s", err, stderr.String())
+	}
+
+	packages := []*watchedPackage{}
+	decoder := json.NewDecoder(bytes.NewReader(stdout))
+	for decoder.More() {
+		pkg := &watchedPackage{}
+		if err := decoder.Decode(pkg); err != nil {
+			return fmt.Errorf("failed to parse the output of go list: %w", err)
+		}
+		packages = append(packages, pkg)
+	}
+	w.packages = packages
+	return nil
+}
+
+// scan returns the state of the watched files: the Go files and the files under the testdata directory.
+func (w *watcher) scan() map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, pkg := range w.packages {
+		entries, err := os.ReadDir(pkg.Dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+				continue
+			}
+			addStamp(stamps, filepath.Join(pkg.Dir, entry.Name()), entry)
+		}
+
+		_ = filepath.WalkDir(filepath.Join(pkg.Dir, "testdata"), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil //nolint:nilerr // The package may have no testdata.
+			}
+			if !d.IsDir() {
+				addStamp(stamps, path, d)
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+// addStamp adds the state of the file.
+func addStamp(stamps map[string]fileStamp, path string, entry fs.DirEntry) {
+	info, err := entry.Info()
+	if err != nil {
+		return
+	}
+	stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// changedFiles returns the files that are created, updated or removed.
+func changedFiles(before, after map[string]fileStamp) []string {
+	changed := []string{}
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// affected returns the import paths of the packages that have the changed files,
+// and of the selected packages whose code or tests depend on them.
+func (w *watcher) affected(changed []string) []string {
+	owners := map[string]bool{}
+	selected := map[string]*watchedPackage{}
+	for _, pkg := range w.packages {
+		selected[pkg.ImportPath] = pkg
+		for _, path := range changed {
+			if ownsFile(pkg.Dir, path) {
+				owners[pkg.ImportPath] = true
+			}
+		}
+	}
+
+	affected := []string{}
+	for _, pkg := range w.packages {
+		if owners[pkg.ImportPath] || pkg.dependsOn(owners, selected) {
+			affected = append(affected, pkg.ImportPath)
+		}
+	}
+	return affected
+}
+
+// ownsFile returns true if the file is a Go file of the package directory, or a file under its testdata.
+func ownsFile(dir, path string) bool {
+	if filepath.Dir(path) == dir {
+		return true
+	}
+	rel, err := filepath.Rel(filepath.Join(dir, "testdata"), path)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// importPaths returns the import paths of the selected packages.
+func (w *watcher) importPaths() []string {
+	paths := make([]string, 0, len(w.packages))
+	for _, pkg := range w.packages {
+		paths = append(paths, pkg.ImportPath)
+	}
+	return paths
+}
+
+// test clears the screen, and runs the tests of the packages with the spectest wrapper.
+func (w *watcher) test(packages []string, changed []string) {
+	fmt.Fprint(w.out, "\033[H\033[2J")
+	for _, path := range changed {
+		fmt.Fprintf(w.out, "changed: %s\n", path)
+	}
+
+	failed := w.runWrapper(append(append([]string{}, w.goTestArgs...), packages...))
+	for _, pkg := range packages {
+		delete(w.failed, pkg)
+	}
+	for pkg, tests := range failed {
+		w.failed[pkg] = tests
+	}
+	w.help()
+}
+
+// testFailed clears the screen, and re-runs the failed tests of the last run.
+func (w *watcher) testFailed() {
+	fmt.Fprint(w.out, "\033[H\033[2J")
+	if len(w.failed) == 0 {
+		fmt.Fprintln(w.out, "no failed tests")
+		w.help()
+		return
+	}
+
+	pkgs := make([]string, 0, len(w.failed))
+	for pkg := range w.failed {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		// -run applies to every package, so the packages are tested one by one.
+		args := append(append([]string{}, w.goTestArgs...), "-run", runPattern(w.failed[pkg]), pkg)
+		delete(w.failed, pkg)
+		for p, tests := range w.runWrapper(args) {
+			w.failed[p] = tests
+		}
+	}
+	w.help()
+}
+
+// runWrapper runs 'go test' with the spectest wrapper, and returns the failed top level tests of each package.
+func (w *watcher) runWrapper(args []string) map[string][]string {
+	s, err := newSpectester(nil, args)
+	if err != nil {
+		fmt.Fprintln(w.out, err.Error())
+		return nil
+	}
+	w.printRunError(s.run())
+	return failedTopLevelTests(s.tests)
+}
+
+// printRunError prints the error of the wrapper run, e.g. the reason of --fail-on-skip.
+// The exit code error without the message is not printed, because the failed tests are already printed.
+func (w *watcher) printRunError(err error) {
+	if err == nil {
+		return
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) && exitErr.message == "" {
+		return
+	}
+	fmt.Fprintln(w.out, err.Error())
+}
+
+// help prints the keys of the watch command.
+func (w *watcher) help() {
+	fmt.Fprintf(w.out, "\nwatching %s ... (f: re-run failed tests, a: re-run all, q: quit)\n", strings.Join(w.patterns, " "))
+}
+
+// failedTopLevelTests returns the failed top level tests of each package.
+// A subtest is re-run by running its top level test.
+func failedTopLevelTests(tests []*testCase) map[string][]string {
+	failed := map[string][]string{}
+	seen := map[string]bool{}
+	for _, tc := range tests {
+		if tc.Action != "fail" {
+			continue
+		}
+		name := topLevelName(tc.Name)
+		if seen[testKey(tc.Package, name)] {
+			continue
+		}
+		seen[testKey(tc.Package, name)] = true
+		failed[tc.Package] = append(failed[tc.Package], name)
+	}
+	return failed
+}
+
+// runPattern returns the anchored regular expression of -run that matches only the tests.
+func runPattern(tests []string) string {
+	quoted := make([]string, 0, len(tests))
+	for _, test := range tests {
+		quoted = append(quoted, regexp.QuoteMeta(test))
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// readKeys sends the lines of the input to the channel. The channel is closed at the end of the input.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		keys <- strings.ToLower(strings.TrimSpace(scanner.Text()))
+	}
+}
+
+// watch re-runs the tests whenever the files change.
+func watch(cmd *cobra.Command, args []string) error {
+	w, err := newWatcher(cmd, args)
+	if err != nil {
+		return fmt.Errorf("failed to initialize watch command: %w", err)
+	}
+	return w.run()
+}
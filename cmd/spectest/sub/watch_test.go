@@ -0,0 +1,155 @@
+package sub
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_changedFiles(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileStamp{
+		"a.go":       {modTime: now, size: 1},
+		"b.go":       {modTime: now, size: 1},
+		"c.go":       {modTime: now, size: 1},
+		"removed.go": {modTime: now, size: 1},
+	}
+	after := map[string]fileStamp{
+		"a.go":     {modTime: now, size: 1},
+		"b.go":     {modTime: now.Add(time.Second), size: 1},
+		"c.go":     {modTime: now, size: 2},
+		"added.go": {modTime: now, size: 1},
+	}
+
+	want := []string{"added.go", "b.go", "c.go", "removed.go"}
+	if diff := cmp.Diff(want, changedFiles(before, after)); diff != "" {
+		t.Errorf("changedFiles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_watcher_affected(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "src", "app")
+	w := &watcher{
+		packages: []*watchedPackage{
+			{ImportPath: "example.com/app", Dir: root, Deps: []string{"example.com/app/model", "net/http"}},
+			{ImportPath: "example.com/app/model", Dir: filepath.Join(root, "model"), Deps: []string{"time"}},
+			{ImportPath: "example.com/app/cli", Dir: filepath.Join(root, "cli"), Deps: []string{"os"}, TestImports: []string{"example.com/app/testutil"}},
+			{ImportPath: "example.com/app/testutil", Dir: filepath.Join(root, "testutil"), Deps: []string{"example.com/app/fixture"}},
+			{ImportPath: "example.com/app/fixture", Dir: filepath.Join(root, "fixture")},
+			{ImportPath: "example.com/app/api", Dir: filepath.Join(root, "api"), XTestImports: []string{"example.com/app/api", "example.com/app/fixture"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{
+			name:    "go file of the dependency",
+			changed: []string{filepath.Join(root, "model", "user.go")},
+			want:    []string{"example.com/app", "example.com/app/model"},
+		},
+		{
+			name:    "golden file in testdata",
+			changed: []string{filepath.Join(root, "cli", "testdata", "golden", "help.txt")},
+			want:    []string{"example.com/app/cli"},
+		},
+		{
+			name:    "go file of the root package",
+			changed: []string{filepath.Join(root, "main_test.go")},
+			want:    []string{"example.com/app"},
+		},
+		{
+			name:    "go file of the package imported only by the internal tests",
+			changed: []string{filepath.Join(root, "testutil", "server.go")},
+			want:    []string{"example.com/app/cli", "example.com/app/testutil"},
+		},
+		{
+			name:    "go file of the dependency of the test imports",
+			changed: []string{filepath.Join(root, "fixture", "users.go")},
+			want:    []string{"example.com/app/cli", "example.com/app/testutil", "example.com/app/fixture", "example.com/app/api"},
+		},
+		{
+			name:    "file outside of the packages",
+			changed: []string{filepath.Join(root, "docs", "README.md")},
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, w.affected(tt.changed)); diff != "" {
+				t.Errorf("affected() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_failedTopLevelTests(t *testing.T) {
+	tests := []*testCase{
+		{Package: "example.com/a", Name: "TestA", Action: "fail"},
+		{Package: "example.com/a", Name: "TestA/sub", Action: "fail"},
+		{Package: "example.com/a", Name: "TestB", Action: "pass"},
+		{Package: "example.com/a", Name: "TestC/sub", Action: "fail"},
+		{Package: "example.com/b", Name: "TestD", Action: "skip"},
+	}
+
+	want := map[string][]string{"example.com/a": {"TestA", "TestC"}}
+	if diff := cmp.Diff(want, failedTopLevelTests(tests)); diff != "" {
+		t.Errorf("failedTopLevelTests() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_runPattern(t *testing.T) {
+	pattern := runPattern([]string{"TestA", "TestA_b"})
+	re := regexp.MustCompile(pattern)
+	for name, want := range map[string]bool{"TestA": true, "TestA_b": true, "TestAB": false, "XTestA": false} {
+		if got := re.MatchString(name); got != want {
+			t.Errorf("%s matches %s = %v, want %v", pattern, name, got, want)
+		}
+	}
+}
+
+func Test_readKeys(t *testing.T) {
+	keys := make(chan string)
+	go readKeys(strings.NewReader("f\n A \nq\n"), keys)
+
+	got := []string{}
+	for key := range keys {
+		got = append(got, key)
+	}
+	if diff := cmp.Diff([]string{"f", "a", "q"}, got); diff != "" {
+		t.Errorf("readKeys() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_watcher_printRunError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "no error", err: nil, want: ""},
+		{name: "failed tests are already printed", err: &exitCodeError{code: 1}, want: ""},
+		{name: "reason of the failure", err: &exitCodeError{code: 1, message: "1 tests were skipped (--fail-on-skip)"}, want: "1 tests were skipped (--fail-on-skip)\n"},
+		{name: "other error", err: errors.New("go: command not found"), want: "go: command not found\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			w := &watcher{out: out}
+			w.printRunError(tt.err)
+			if got := out.String(); got != tt.want {
+				t.Errorf("printRunError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
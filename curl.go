@@ -0,0 +1,173 @@
+package spectest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// curlCommand returns the curl command that sends the same request: method, url with query,
+// headers, cookies, basic auth and body. Multipart bodies are converted to -F options.
+// If baseURL is set, it replaces the scheme and the host of the request served by a http.Handler.
+// The body of the request is read and restored.
+func curlCommand(req *http.Request, baseURL string) string {
+	if req == nil {
+		return ""
+	}
+	body, err := readAndRestoreBody(&req.Body)
+	if err != nil {
+		body = nil
+	}
+
+	args := []string{fmt.Sprintf("curl -X %s %s", req.Method, shellQuote(curlURL(req, baseURL)))}
+
+	user, password, basicAuth := req.BasicAuth()
+	if basicAuth {
+		args = append(args, "-u "+shellQuote(user+":"+password))
+	}
+
+	multipartArgs, isMultipart := curlMultipartArgs(req.Header.Get("Content-Type"), body)
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch {
+		case key == "Content-Length":
+			continue
+		case key == "Authorization" && basicAuth:
+			continue
+		case key == "Content-Type" && isMultipart:
+			// curl sets the content type with its own boundary.
+			continue
+		}
+		for _, value := range req.Header[key] {
+			if key == "Cookie" {
+				args = append(args, "-b "+shellQuote(value))
+				continue
+			}
+			args = append(args, "-H "+shellQuote(key+": "+value))
+		}
+	}
+	if req.Host != "" && req.URL.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H "+shellQuote("Host: "+req.Host))
+	}
+
+	switch {
+	case isMultipart:
+		args = append(args, multipartArgs...)
+	case len(body) > 0:
+		args = append(args, "--data-raw "+shellQuote(string(body)))
+	}
+	return strings.Join(args, " \\\n  ")
+}
+
+// inboundCurlCommand returns the curl command of the first request that the consumer sent to the system under test.
+func inboundCurlCommand(recorder *Recorder) string {
+	for _, event := range recorder.Events {
+		if req, ok := event.(HTTPRequest); ok && req.Source == ConsumerDefaultName {
+			return curlCommand(req.Value, "")
+		}
+	}
+	return ""
+}
+
+// curlMultipartArgs converts the multipart/form-data body to the -F options of curl.
+// It returns false if the body is not a multipart body.
+func curlMultipartArgs(contentType string, body []byte) ([]string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, false
+	}
+
+	args := []string{}
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return args, true
+		}
+		if err != nil {
+			return nil, false
+		}
+		if part.FileName() != "" {
+			field := fmt.Sprintf("%s=@%s", part.FormName(), part.FileName())
+			if partType := part.Header.Get("Content-Type"); partType != "" {
+				field += ";type=" + partType
+			}
+			args = append(args, "-F "+shellQuote(field))
+			continue
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, false
+		}
+		args = append(args, "-F "+shellQuote(part.FormName()+"="+string(value)))
+	}
+}
+
+// curlURL returns the url of the curl command. The base url is used only if the url of the request has no host.
+func curlURL(req *http.Request, baseURL string) string {
+	if baseURL == "" || req.URL.Host != "" {
+		return absoluteURL(req)
+	}
+	return strings.TrimSuffix(baseURL, "/") + req.URL.RequestURI()
+}
+
+// absoluteURL returns the url of the request with the scheme and the host.
+// The url of the request served by a http.Handler has no host, so the Host field is used.
+func absoluteURL(req *http.Request) string {
+	u := *req.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	return u.String()
+}
+
+// shellQuote quotes the string for POSIX shells. Strings with control characters
+// are quoted with the ANSI-C quoting, $'...', so they can be pasted safely.
+func shellQuote(s string) string {
+	hasControl := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsControl(r) && r != '\n' && r != '\t'
+	}) >= 0
+	if !hasControl {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' || c == '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package spectest
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	t.Run("request with query, headers, cookies and basic auth", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users?name=it's&page=2", nil)
+		req.Host = "server"
+		req.Header.Set("Accept", "application/json")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+		req.SetBasicAuth("user", "password")
+
+		want := strings.Join([]string{
+			`curl -X GET 'http://server/users?name=it'\''s&page=2'`,
+			`-u 'user:password'`,
+			`-H 'Accept: application/json'`,
+			`-b 'session=abc'`,
+		}, " \\\n  ")
+		if got := curlCommand(req, ""); got != want {
+			t.Errorf("want:\n%s\ngot:\n%s", want, got)
+		}
+	})
+
+	t.Run("request with raw body keeps the body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/users", strings.NewReader(`{"name": "Tom"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Length", "15")
+
+		want := strings.Join([]string{
+			`curl -X POST 'http://localhost:8080/users'`,
+			`-H 'Content-Type: application/json'`,
+			`--data-raw '{"name": "Tom"}'`,
+		}, " \\\n  ")
+		if got := curlCommand(req, ""); got != want {
+			t.Errorf("want:\n%s\ngot:\n%s", want, got)
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != `{"name": "Tom"}` {
+			t.Errorf("the body is not restored: %s", body)
+		}
+	})
+
+	t.Run("multipart body is converted to form options", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("name", "Tom"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.CreateFormFile("avatar", "tom.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/users", body)
+		req.Host = "server"
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		want := strings.Join([]string{
+			`curl -X POST 'http://server/users'`,
+			`-F 'name=Tom'`,
+			`-F 'avatar=@tom.png;type=application/octet-stream'`,
+		}, " \\\n  ")
+		if got := curlCommand(req, ""); got != want {
+			t.Errorf("want:\n%s\ngot:\n%s", want, got)
+		}
+	})
+
+	t.Run("base url replaces the host of the request served by the handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users?page=2", nil)
+		req.Host = "server"
+
+		want := `curl -X GET 'http://localhost:8080/api/users?page=2'`
+		if got := curlCommand(req, "http://localhost:8080/api/"); got != want {
+			t.Errorf("want:\n%s\ngot:\n%s", want, got)
+		}
+	})
+
+	t.Run("base url is not used if the request has a host", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+
+		want := `curl -X GET 'http://example.com/users'`
+		if got := curlCommand(req, "http://localhost:8080"); got != want {
+			t.Errorf("want:\n%s\ngot:\n%s", want, got)
+		}
+	})
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "hello", want: `'hello'`},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "line1\nline2", want: "'line1\nline2'"},
+		{in: "a\r\nb's\x00", want: `$'a\r\nb\'s\x00'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFailureMessageArgsCurl(t *testing.T) {
+	got := messageFromMsgAndArgs("Status code 500 not equal to 200", failureMessageArgs{Name: "get user", Curl: "curl -X GET 'http://server/user'"})
+	want := []labeledContent{
+		{"Messages", "Status code 500 not equal to 200"},
+		{"Name", "get user"},
+		{"cURL", "curl -X GET 'http://server/user'"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("want %v, got %v", want[i], got[i])
+		}
+	}
+}
+
+func TestSpecTestCurlBaseURL(t *testing.T) {
+	req := New().
+		CurlBaseURL("http://localhost:8080").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).
+		Get("/users")
+	req.Expect(t).Status(http.StatusOK).End()
+
+	want := `curl -X GET 'http://localhost:8080/users'`
+	if got := req.specTest.failureMessageArgs().Curl; got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -10,6 +10,10 @@ import (
 // debug is used to enable/disable debug logging
 type debug struct {
 	enabled bool
+	// redaction is the redaction policy of the dumped requests and responses
+	redaction *Redaction
+	// curlBaseURL is the base url of the dumped cURL command. See SpecTest.CurlBaseURL.
+	curlBaseURL string
 }
 
 // newDebug creates a new debug setting
@@ -33,11 +37,13 @@ func (d *debug) dumpRequest(req *http.Request) {
 	if !d.isEnable() {
 		return
 	}
+	req = activeRedaction(d.redaction).request(req)
 	requestDump, err := httputil.DumpRequest(req, true)
 	if err == nil {
 		debugLog(requestDebugPrefix(), "inbound http request", string(requestDump))
 	}
 	// TODO: handle error
+	debugLog(requestDebugPrefix(), "inbound http request as cURL", curlCommand(req, d.curlBaseURL))
 }
 
 // dumpResponse is used to dump the response.
@@ -46,7 +52,7 @@ func (d *debug) dumpResponse(res *http.Response) {
 	if !d.isEnable() {
 		return
 	}
-	responseDump, err := httputil.DumpResponse(res, true)
+	responseDump, err := httputil.DumpResponse(activeRedaction(d.redaction).response(res), true)
 	if err == nil {
 		debugLog(responseDebugPrefix(), "final response", string(responseDump))
 	}
@@ -68,13 +74,14 @@ func (d *debug) mock(res *http.Response, req *http.Request) {
 		return
 	}
 
-	requestDump, err := httputil.DumpRequestOut(req, true)
+	redaction := activeRedaction(d.redaction)
+	requestDump, err := httputil.DumpRequestOut(redaction.request(req), true)
 	if err == nil {
 		debugLog(requestDebugPrefix(), "request to mock", string(requestDump))
 	}
 
 	if res != nil {
-		responseDump, err := httputil.DumpResponse(res, true)
+		responseDump, err := httputil.DumpResponse(redaction.response(res), true)
 		if err == nil {
 			debugLog(responseDebugPrefix(), "response from mock", string(responseDump))
 		}
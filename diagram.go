@@ -2,6 +2,7 @@ package spectest
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,6 +36,20 @@ type (
 		WebSequenceDSL string
 		// MetaJSON is the JSON representation of the meta data
 		MetaJSON htmlTemplate.JS
+		// Attempts is the number of requests sent by Response.Eventually
+		Attempts int
+		// Curl is the curl command that reproduces the inbound request
+		Curl string
+		// Embedded is true if the report embeds every asset instead of loading them from the CDNs
+		Embedded bool
+		// DiagramSVG is the sequence diagram rendered at generation time. It is set only if Embedded is true.
+		DiagramSVG htmlTemplate.HTML
+		// Images are the images of the responses as data URLs by the log entry index. They are set only if Embedded is true.
+		Images map[int]htmlTemplate.URL
+		// Assertions are the results of the assertions
+		Assertions []AssertionResult
+		// Passed is true if every assertion passed
+		Passed bool
 	}
 
 	// SequenceDiagramFormatter implementation of a ReportFormatter
@@ -43,6 +58,10 @@ type (
 		storagePath string
 		// fs is the file system used to save the report
 		fs fileSystem
+		// metaJSON is true if the meta data is saved to a JSON file next to the report
+		metaJSON bool
+		// embedAssets is true if the report embeds every asset instead of loading them from the CDNs
+		embedAssets bool
 	}
 )
 
@@ -53,8 +72,26 @@ type ReportFormatterConfig struct {
 	Path string
 	// Kind is the kind of report to generate
 	Kind ReportKind
+	// MetaJSON saves the meta data of the report to "<report file name>.meta.json" next to the report.
+	// The meta data files are read by ReadMetas and the "spectest coverage" command.
+	MetaJSON bool
+	// Assets is how the HTML report loads its assets. By default, they are loaded from the CDNs.
+	// It is ignored by the other report kinds.
+	Assets ReportAssets
 }
 
+// ReportAssets is how the HTML report loads its assets (styles, scripts and the sequence diagram).
+type ReportAssets uint
+
+const (
+	// ReportAssetsCDN loads the assets from the public CDNs, and the sequence diagram is drawn by the browser.
+	// This is the default.
+	ReportAssetsCDN ReportAssets = 0
+	// ReportAssetsEmbedded embeds every asset in the HTML report, so the report works with no network.
+	// The sequence diagram is rendered to inline SVG, and the images of the responses are embedded as data URLs.
+	ReportAssetsEmbedded ReportAssets = 1
+)
+
 // ReportKind is the kind of the report.
 type ReportKind uint
 
@@ -63,6 +100,10 @@ const (
 	ReportKindHTML ReportKind = 0
 	// ReportKindMarkdown is the Markdown report kind.
 	ReportKindMarkdown ReportKind = 1
+	// ReportKindHAR is the HTTP Archive (HAR) 1.2 report kind. It can be opened in browser devtools and other HAR viewers.
+	ReportKindHAR ReportKind = 2
+	// ReportKindJSON is the machine-readable JSON report kind. The format is described by JSONReportSchema.
+	ReportKindJSON ReportKind = 3
 )
 
 // SequenceDiagram produce a sequence diagram at the given path or .sequence by default.
@@ -80,21 +121,41 @@ func SequenceDiagram(path ...string) ReportFormatter {
 
 // SequenceReport produce a sequence diagram at the given path or .sequence by default.
 // SequenceDiagramFormatter generate html report or markdown report with sequence diagram.
+// If the kind is ReportKindHAR, HARFormatter generate HTTP Archive file.
+// If the kind is ReportKindJSON, JSONReportFormatter generate JSON report.
 func SequenceReport(config ReportFormatterConfig) ReportFormatter {
 	if config.Path == "" {
 		config.Path = ".sequence"
 	}
-	if config.Kind == ReportKindMarkdown {
-		return &MarkdownFormatter{storagePath: config.Path, fs: &defaultFileSystem{}}
+	switch config.Kind {
+	case ReportKindMarkdown:
+		return &MarkdownFormatter{storagePath: config.Path, fs: &defaultFileSystem{}, metaJSON: config.MetaJSON}
+	case ReportKindHAR:
+		return &HARFormatter{storagePath: config.Path, fs: &defaultFileSystem{}, metaJSON: config.MetaJSON}
+	case ReportKindJSON:
+		return &JSONReportFormatter{storagePath: config.Path, fs: &defaultFileSystem{}, metaJSON: config.MetaJSON}
+	}
+	return &SequenceDiagramFormatter{
+		storagePath: config.Path,
+		fs:          &defaultFileSystem{},
+		metaJSON:    config.MetaJSON,
+		embedAssets: config.Assets == ReportAssetsEmbedded,
 	}
-	return &SequenceDiagramFormatter{storagePath: config.Path, fs: &defaultFileSystem{}}
 }
 
-// Format formats the events received by the recorder
+// Format formats the events received by the recorder. It panics if the report can not be generated.
 func (sdf *SequenceDiagramFormatter) Format(recorder *Recorder) {
+	if err := sdf.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError formats the events received by the recorder.
+// It returns the error if the report can not be generated.
+func (sdf *SequenceDiagramFormatter) FormatWithError(recorder *Recorder) error {
 	output, err := sdf.newHTMLTemplateModel(recorder)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	template, err := htmlTemplate.New("sequenceDiagram").
@@ -113,33 +174,39 @@ func (sdf *SequenceDiagramFormatter) Format(recorder *Recorder) {
 		}).
 		Parse(reportTemplate)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	var out bytes.Buffer
 	err = template.Execute(&out, output)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	fileName := fmt.Sprintf("%s.html", recorder.Meta.reportFileName())
 	err = sdf.fs.mkdirAll(sdf.storagePath, os.ModePerm)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	saveFilesTo := filepath.Join(sdf.storagePath, fileName)
 
 	f, err := sdf.fs.create(saveFilesTo)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer f.Close() //nolint
 
 	s, _ := filepath.Abs(saveFilesTo)
 	_, err = f.WriteString(out.String())
 	if err != nil {
-		panic(err)
+		return err
 	}
 	fmt.Printf("Created sequence diagram (%s): %s\n", fileName, filepath.FromSlash(s))
+
+	if sdf.metaJSON {
+		return writeMetaJSON(sdf.fs, sdf.storagePath, recorder.Meta)
+	}
+	return nil
 }
 
 // formatDiagramRequest formats the HTTP request into a string for logging purposes.
@@ -180,6 +247,7 @@ func (sdf *SequenceDiagramFormatter) newHTMLTemplateModel(recorder *Recorder) (h
 		return htmlTemplateModel{}, errors.New("no events are defined")
 	}
 	var logs []LogEntry
+	images := map[int]htmlTemplate.URL{}
 	webSequenceDiagram := &webSequenceDiagramDSL{meta: recorder.Meta}
 
 	for i, event := range recorder.Events {
@@ -202,7 +270,12 @@ func (sdf *SequenceDiagramFormatter) newHTMLTemplateModel(recorder *Recorder) (h
 			// If the Content Type is an image, display the image in the report instead of the response body (binary).
 			contentType := extractContentType(entry.Header)
 			if isImage(contentType) {
-				generateImage(entry.Body, sdf.storagePath, recorder.Meta.reportFileName(), contentType, i)
+				if sdf.embedAssets {
+					images[len(logs)] = imageDataURL(contentType, entry.Body)
+				}
+				if err := generateImage(entry.Body, sdf.storagePath, recorder.Meta.reportFileName(), contentType, i); err != nil {
+					return htmlTemplateModel{}, err
+				}
 				entry.Body = filepath.Clean(filepath.Base(imagePath(sdf.storagePath, recorder.Meta.reportFileName(), contentType, i)))
 			}
 			entry.Timestamp = v.Timestamp
@@ -217,12 +290,17 @@ func (sdf *SequenceDiagramFormatter) newHTMLTemplateModel(recorder *Recorder) (h
 			contentType := extractContentType(v.Header)
 			body := v.Body
 			if isImage(contentType) {
-				generateImage(v.Body, sdf.storagePath, recorder.Meta.reportFileName(), contentType, i)
+				if sdf.embedAssets {
+					images[len(logs)] = imageDataURL(contentType, v.Body)
+				}
+				if err := generateImage(v.Body, sdf.storagePath, recorder.Meta.reportFileName(), contentType, i); err != nil {
+					return htmlTemplateModel{}, err
+				}
 				body = filepath.Clean(imagePath(sdf.storagePath, recorder.Meta.reportFileName(), contentType, i))
 			}
 			logs = append(logs, LogEntry{Header: v.Header, Body: body, Timestamp: v.Timestamp})
 		default:
-			panic("received unknown event type")
+			return htmlTemplateModel{}, fmt.Errorf("received unknown event type: %T", event)
 		}
 	}
 
@@ -236,7 +314,7 @@ func (sdf *SequenceDiagramFormatter) newHTMLTemplateModel(recorder *Recorder) (h
 		return htmlTemplateModel{}, err
 	}
 
-	return htmlTemplateModel{
+	model := htmlTemplateModel{
 		WebSequenceDSL: webSequenceDiagram.String(),
 		LogEntries:     logs,
 		Title:          recorder.Title,
@@ -248,7 +326,29 @@ func (sdf *SequenceDiagramFormatter) newHTMLTemplateModel(recorder *Recorder) (h
 		// This can potentially lead to 'Cross-site Scripting' vulnerabilities,
 		// in case the attacker controls the input. (Confidence: LOW, Severity: MEDIUM)
 		MetaJSON: htmlTemplate.JS(jsonMeta),
-	}, nil
+		Attempts: recorder.Meta.Attempts,
+		Curl:     inboundCurlCommand(recorder),
+	}
+	model.Assertions = recorder.AssertionResults
+	model.Passed = true
+	for _, assertion := range model.Assertions {
+		if !assertion.Passed {
+			model.Passed = false
+		}
+	}
+	if sdf.embedAssets {
+		model.Embedded = true
+		//#nosec G203 -- every text in the diagram is escaped by the renderer.
+		model.DiagramSVG = htmlTemplate.HTML(webSequenceDiagram.svg())
+		model.Images = images
+	}
+	return model, nil
+}
+
+// imageDataURL returns the image as a data URL, so the image is shown without the image file.
+func imageDataURL(contentType, body string) htmlTemplate.URL {
+	//#nosec G203 -- the content type is one of the image types and the body is base64 encoded.
+	return htmlTemplate.URL(fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString([]byte(body))))
 }
 
 // extractContentType extracts the content type from the header.
@@ -274,17 +374,15 @@ func isImage(contentType string) bool {
 }
 
 // generateImage generates an image from the body.
-func generateImage(body, dir, name, contentType string, index int) {
+func generateImage(body, dir, name, contentType string, index int) error {
 	file, err := os.Create(filepath.Clean(imagePath(dir, name, contentType, index)))
 	if err != nil {
-		panic(err) //FIXME: error handling
+		return err
 	}
 	defer file.Close() //nolint
 
 	_, err = file.Write([]byte(body))
-	if err != nil {
-		panic(err) //FIXME: error handling
-	}
+	return err
 }
 
 // imagePath returns the image path.
@@ -365,6 +463,20 @@ type webSequenceDiagramDSL struct {
 	count int
 	// meta is the meta data of the sequence diagram
 	meta *Meta
+	// rows are the rows of the sequence diagram. They are used to render the diagram to SVG.
+	rows []sequenceDiagramRow
+}
+
+// sequenceDiagramRow is a message between two participants of the sequence diagram.
+type sequenceDiagramRow struct {
+	// source is the participant that sends the message
+	source string
+	// target is the participant that receives the message
+	target string
+	// response is true if the message is a response
+	response bool
+	// label is the numbered description of the message. e.g. "(1) GET /users"
+	label string
 }
 
 // addRequestRow adds a request row to the sequence diagram
@@ -389,6 +501,12 @@ func (r *webSequenceDiagramDSL) addRow(operation, source string, target string,
 	}
 
 	r.count++
+	r.rows = append(r.rows, sequenceDiagramRow{
+		source:   source,
+		target:   target,
+		response: operation == "->>",
+		label:    fmt.Sprintf("(%d) %s", r.count, description),
+	})
 	r.data.WriteString(fmt.Sprintf("%s%s%s: (%d) %s\n",
 		quoted(source),
 		operation,
@@ -409,44 +527,75 @@ type MarkdownFormatter struct {
 	storagePath string
 	// fs is the file system used to save the report
 	fs fileSystem
+	// metaJSON is true if the meta data is saved to a JSON file next to the report
+	metaJSON bool
 }
 
-// Format formats the events received by the recorder.
-// TODO: refactor this method
+// Format formats the events received by the recorder. It panics if the report can not be generated.
 func (m *MarkdownFormatter) Format(recorder *Recorder) {
+	if err := m.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError formats the events received by the recorder.
+// It returns the error if the report can not be generated.
+func (m *MarkdownFormatter) FormatWithError(recorder *Recorder) error {
 	if len(recorder.Events) == 0 {
-		panic("no events are defined") // TODO: error handling
+		return errors.New("no events are defined")
+	}
+
+	status, err := recorder.ResponseStatus()
+	if err != nil {
+		return err
+	}
+
+	logs, err := m.logEntry(recorder.Events)
+	if err != nil {
+		return err
 	}
 
 	if err := m.fs.mkdirAll(m.storagePath, os.ModePerm); err != nil {
-		panic(err) // TODO: error handling
+		return err
 	}
 
 	fileName := fmt.Sprintf("%s.md", recorder.Meta.reportFileName())
 	f, err := m.fs.create(filepath.Clean(filepath.Join(m.storagePath, fileName)))
 	if err != nil {
-		panic(err) // TODO: error handling
+		return err
 	}
+	defer f.Close() //nolint
 
-	status, err := recorder.ResponseStatus()
-	if err != nil {
-		panic(err) // TODO: error handling
+	if err := m.generateMarkdown(f, recorder, status, logs); err != nil {
+		return err
 	}
 
-	logs, err := m.logEntry(recorder.Events)
-	if err != nil {
-		panic(err) // TODO: error handling
+	if m.metaJSON {
+		return writeMetaJSON(m.fs, m.storagePath, recorder.Meta)
 	}
-	m.generateMarkdown(f, recorder, status, logs)
+	return nil
 }
 
 // generateMarkdown generates a markdown report.
-func (m *MarkdownFormatter) generateMarkdown(w io.Writer, recorder *Recorder, status int, logs []LogEntry) {
+func (m *MarkdownFormatter) generateMarkdown(w io.Writer, recorder *Recorder, status int, logs []LogEntry) error {
 	markdown := m.statusBadge(md.NewMarkdown(w).H2(recorder.Title), status).LF()
 	if recorder.SubTitle != "" {
 		markdown = markdown.H3(recorder.SubTitle).LF()
 	}
-	markdown = markdown.CodeBlocks(md.SyntaxHighlightMermaid, m.mermaidSequenceDiagram(recorder)).LF()
+	if recorder.Meta.Attempts > 0 {
+		markdown = markdown.PlainTextf("Attempts: %d", recorder.Meta.Attempts).LF()
+	}
+	diagram, err := m.mermaidSequenceDiagram(recorder)
+	if err != nil {
+		return err
+	}
+	markdown = markdown.CodeBlocks(md.SyntaxHighlightMermaid, diagram).LF()
+	if curl := inboundCurlCommand(recorder); curl != "" {
+		markdown = markdown.H2("cURL").CodeBlocks(md.SyntaxHighlightShell, curl).LF()
+	}
+	if assertions := recorder.AssertionResults; len(assertions) > 0 {
+		markdown = m.assertions(markdown, assertions)
+	}
 
 	markdown = markdown.H2("Event log")
 	for i, log := range logs {
@@ -457,7 +606,9 @@ func (m *MarkdownFormatter) generateMarkdown(w io.Writer, recorder *Recorder, st
 		if log.Body != "" {
 			contentType := extractContentType(log.Header)
 			if isImage(contentType) {
-				generateImage(log.Body, m.storagePath, recorder.Meta.reportFileName(), contentType, i)
+				if err := generateImage(log.Body, m.storagePath, recorder.Meta.reportFileName(), contentType, i); err != nil {
+					return err
+				}
 				body := filepath.Clean(imageName(recorder.Meta.reportFileName(), contentType, i))
 				markdown = markdown.PlainText(md.Image(body, body)).LF()
 			} else if strings.Contains(contentType, "application/json") {
@@ -469,12 +620,40 @@ func (m *MarkdownFormatter) generateMarkdown(w io.Writer, recorder *Recorder, st
 		markdown = markdown.HorizontalRule().LF()
 	}
 
-	if err := markdown.Build(); err != nil {
-		panic(err) // TODO: error handling
+	return markdown.Build()
+}
+
+// assertions returns a markdown with the results of the assertions and the diffs of the failed assertions.
+func (m *MarkdownFormatter) assertions(markdown *md.Markdown, assertions []AssertionResult) *md.Markdown {
+	rows := make([][]string, 0, len(assertions))
+	for _, a := range assertions {
+		result := "PASS"
+		if !a.Passed {
+			result = "FAIL"
+		}
+		rows = append(rows, []string{result, markdownTableCell(a.Name), markdownTableCell(a.Message)})
 	}
+	markdown = markdown.H2("Assertions").CustomTable(md.TableSet{
+		Header: []string{"Result", "Assertion", "Message"},
+		Rows:   rows,
+	}, md.TableOptions{AutoWrapText: false, AutoFormatHeaders: false}).LF()
+
+	for _, a := range assertions {
+		if diff := a.Diff(); diff != "" {
+			markdown = markdown.H4(a.Name).CodeBlocks(md.SyntaxHighlightDiff, diff).LF()
+		}
+	}
+	return markdown
+}
+
+// markdownTableCell escapes the text, so the text does not break the markdown table.
+func markdownTableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
 }
 
-func (m *MarkdownFormatter) mermaidSequenceDiagram(recorder *Recorder) string {
+// mermaidSequenceDiagram returns the mermaid sequence diagram of the events.
+func (m *MarkdownFormatter) mermaidSequenceDiagram(recorder *Recorder) (string, error) {
 	seq := sequence.NewDiagram(io.Discard).AutoNumber()
 
 	for _, event := range recorder.Events {
@@ -488,10 +667,10 @@ func (m *MarkdownFormatter) mermaidSequenceDiagram(recorder *Recorder) string {
 		case MessageResponse:
 			seq.SyncResponse(v.Source, v.Target, v.Header)
 		default:
-			panic("received unknown event type") // TODO: error handling
+			return "", fmt.Errorf("received unknown event type: %T", event)
 		}
 	}
-	return seq.String()
+	return seq.String(), nil
 }
 
 // statusBadge returns a markdown with a status badge based on the HTTP status code.
@@ -532,7 +711,7 @@ func (m *MarkdownFormatter) logEntry(events []Event) ([]LogEntry, error) {
 		case MessageResponse:
 			logs = append(logs, LogEntry{Header: v.Header, Body: v.Body, Timestamp: v.Timestamp})
 		default:
-			panic("received unknown event type")
+			return nil, fmt.Errorf("received unknown event type: %T", event)
 		}
 	}
 	return logs, nil
@@ -0,0 +1,99 @@
+package spectest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// The layout of the sequence diagram rendered to SVG. The text width is estimated,
+// because the fonts of the browser are unknown at generation time.
+const (
+	svgMargin        = 20
+	svgFontSize      = 14
+	svgCharWidth     = 8
+	svgBoxHeight     = 36
+	svgBoxPadding    = 16
+	svgMinBoxWidth   = 80
+	svgRowHeight     = 44
+	svgColumnPadding = 40
+	svgSelfLoopWidth = 40
+)
+
+// svg renders the sequence diagram to SVG, so the HTML report shows the diagram without JavaScript.
+// The participants are placed from left to right in order of appearance. The requests are solid
+// lines with filled arrows, and the responses are dashed lines with open arrows. The labels keep
+// the numbers, e.g. "(1)", so the labels can be linked to the event log.
+func (r *webSequenceDiagramDSL) svg() string {
+	participants := []string{}
+	column := map[string]int{}
+	widest := svgMinBoxWidth
+	hasSelfMessage := false
+	for _, row := range r.rows {
+		for _, name := range []string{row.source, row.target} {
+			if _, ok := column[name]; !ok {
+				column[name] = len(participants)
+				participants = append(participants, name)
+			}
+			widest = max(widest, svgTextWidth(name)+2*svgBoxPadding)
+		}
+		widest = max(widest, svgTextWidth(row.label))
+		if row.source == row.target {
+			hasSelfMessage = true
+		}
+	}
+
+	columnWidth := widest + svgColumnPadding
+	width := 2*svgMargin + len(participants)*columnWidth
+	if hasSelfMessage {
+		width += columnWidth / 2
+	}
+	bottom := svgMargin + svgBoxHeight + (len(r.rows)+1)*svgRowHeight
+	height := bottom + svgBoxHeight + svgMargin
+	center := func(name string) int {
+		return svgMargin + columnWidth/2 + column[name]*columnWidth
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="%d">`,
+		width, height, width, height, svgFontSize)
+	b.WriteString(`<defs>` +
+		`<marker id="arrow-filled" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="#333"/></marker>` +
+		`<marker id="arrow-open" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M 0 0 L 10 5 L 0 10" fill="none" stroke="#333"/></marker>` +
+		`</defs>`)
+
+	for _, name := range participants {
+		x := center(name)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999" stroke-dasharray="4,4"/>`, x, svgMargin+svgBoxHeight, x, bottom)
+		for _, y := range []int{svgMargin, bottom} {
+			boxWidth := max(svgMinBoxWidth, svgTextWidth(name)+2*svgBoxPadding)
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#fff" stroke="#333"/>`, x-boxWidth/2, y, boxWidth, svgBoxHeight)
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle">%s</text>`, x, y+svgBoxHeight/2, html.EscapeString(name))
+		}
+	}
+
+	for i, row := range r.rows {
+		y := svgMargin + svgBoxHeight + (i+1)*svgRowHeight
+		style := `stroke="#333" marker-end="url(#arrow-filled)"`
+		if row.response {
+			style = `stroke="#333" stroke-dasharray="6,4" marker-end="url(#arrow-open)"`
+		}
+		x1, x2 := center(row.source), center(row.target)
+		label := html.EscapeString(row.label)
+		if x1 == x2 {
+			fmt.Fprintf(&b, `<polyline points="%d,%d %d,%d %d,%d %d,%d" fill="none" %s/>`,
+				x1, y-10, x1+svgSelfLoopWidth, y-10, x1+svgSelfLoopWidth, y+10, x1, y+10, style)
+			fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, x1+svgSelfLoopWidth+8, y, label)
+			continue
+		}
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" %s/>`, x1, y, x2, y, style)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text>`, (x1+x2)/2, y-8, label)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// svgTextWidth returns the estimated width of the text in the SVG.
+func svgTextWidth(text string) int {
+	return len([]rune(text)) * svgCharWidth
+}
@@ -1,13 +1,18 @@
 package spectest
 
 import (
+	"encoding/base64"
+	"errors"
+	"html"
 	"html/template"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // MockFS is a mock implementation of the fileSystem interface
@@ -225,6 +230,68 @@ func aRecorder() *Recorder {
 		})
 }
 
+// errFS is a fileSystem that fails to create the directory
+type errFS struct{}
+
+// create fails to create the file
+func (errFS) create(string) (*os.File, error) {
+	return nil, errors.New("read-only file system")
+}
+
+// mkdirAll fails to create the directory
+func (errFS) mkdirAll(string, os.FileMode) error {
+	return errors.New("read-only file system")
+}
+
+// unknownEvent is an event that the formatters do not know
+type unknownEvent struct{}
+
+// GetTime gets the time of the unknownEvent
+func (unknownEvent) GetTime() time.Time { return time.Time{} }
+
+func TestReportFormatterFormatWithError(t *testing.T) {
+	formatters := map[string]func(fs fileSystem) ReportFormatterWithError{
+		"html": func(fs fileSystem) ReportFormatterWithError {
+			return &SequenceDiagramFormatter{storagePath: t.TempDir(), fs: fs}
+		},
+		"markdown": func(fs fileSystem) ReportFormatterWithError {
+			return &MarkdownFormatter{storagePath: t.TempDir(), fs: fs}
+		},
+		"har": func(fs fileSystem) ReportFormatterWithError { return &HARFormatter{storagePath: t.TempDir(), fs: fs} },
+		"json": func(fs fileSystem) ReportFormatterWithError {
+			return &JSONReportFormatter{storagePath: t.TempDir(), fs: fs}
+		},
+	}
+	for name, newFormatter := range formatters {
+		t.Run(name+" file system error", func(t *testing.T) {
+			err := newFormatter(errFS{}).FormatWithError(aRecorder())
+			if err == nil || !strings.Contains(err.Error(), "read-only file system") {
+				t.Errorf("expected the file system error, got %v", err)
+			}
+		})
+	}
+
+	for _, name := range []string{"html", "markdown", "json"} {
+		t.Run(name+" unknown event type", func(t *testing.T) {
+			recorder := aRecorder()
+			recorder.Events = append([]Event{unknownEvent{}}, recorder.Events...)
+			err := formatters[name](&defaultFileSystem{}).FormatWithError(recorder)
+			if err == nil || !strings.Contains(err.Error(), "received unknown event type") {
+				t.Errorf("expected the unknown event type error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("format panics with the error", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected to panic")
+			}
+		}()
+		(&MarkdownFormatter{storagePath: t.TempDir(), fs: errFS{}}).Format(aRecorder())
+	})
+}
+
 func TestNewHttpRequestLogEntry(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/path", strings.NewReader(`{"a": 12345}`))
 
@@ -528,3 +595,81 @@ func Test_imageName(t *testing.T) {
 		})
 	}
 }
+
+func TestWebSequenceDiagramDSLSVG(t *testing.T) {
+	wsd := webSequenceDiagramDSL{meta: &Meta{}}
+	wsd.addRequestRow(ConsumerDefaultName, SystemUnderTestDefaultName, "GET /users?name=<a&b>")
+	wsd.addRequestRow(SystemUnderTestDefaultName, SystemUnderTestDefaultName, "cache lookup")
+	wsd.addResponseRow(SystemUnderTestDefaultName, ConsumerDefaultName, "200")
+
+	svg := wsd.svg()
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"`,
+		`>client</text>`,
+		`>server</text>`,
+		`>(1) GET /users?name=&lt;a&amp;b&gt;</text>`,
+		`<polyline `,
+		`>(2) cache lookup</text>`,
+		`stroke-dasharray="6,4" marker-end="url(#arrow-open)"/><text `,
+		`>(3) 200</text>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg does not contain %q:\n%s", want, svg)
+		}
+	}
+	if strings.Count(svg, ">client</text>") != 2 || strings.Count(svg, ">server</text>") != 2 {
+		t.Errorf("each participant must have a top and a bottom box:\n%s", svg)
+	}
+}
+
+func TestSequenceReportEmbeddedAssets(t *testing.T) {
+	dir := t.TempDir()
+	image, err := os.ReadFile(filepath.Join("testdata", "sample.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(image)
+		}).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Assets: ReportAssetsEmbedded})).
+		CustomReportName("embedded").
+		Get("/image").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	data, err := os.ReadFile(filepath.Join(dir, "embedded.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The base64 image in the attribute is HTML escaped, e.g. "+" is "&#43;".
+	report := html.UnescapeString(string(data))
+	for _, unwanted := range []string{`src="http`, `href="http`, "Diagram.parse", "hljs"} {
+		if strings.Contains(report, unwanted) {
+			t.Errorf("embedded report must not contain %q", unwanted)
+		}
+	}
+	for _, want := range []string{
+		`<div id="d" class="justify-content-center"><svg `,
+		`>(1) GET /image</text>`,
+		`<img src="data:image/png;base64,` + base64.StdEncoding.EncodeToString(image) + `" alt="Image">`,
+		"navigator.clipboard",
+		`<script type="application/json" id="metaJson">`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("embedded report does not contain %q", want)
+		}
+	}
+
+	metas, err := ReadMetas(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 1 || metas[0].Path != "/image" {
+		t.Errorf("unexpected meta data: %+v", metas)
+	}
+}
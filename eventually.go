@@ -0,0 +1,141 @@
+package spectest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventually holds the polling settings of Response.Eventually.
+type eventually struct {
+	// timeout is the maximum time to wait for the assertions to pass
+	timeout time.Duration
+	// interval is the time to wait between attempts
+	interval time.Duration
+}
+
+// newEventually creates a new polling setting.
+func newEventually(timeout, interval time.Duration) *eventually {
+	return &eventually{
+		timeout:  timeout,
+		interval: interval,
+	}
+}
+
+// run sends the request and runs all assertions until they pass or the timeout expires.
+// Failures of each attempt are recorded instead of being reported. When the assertions pass or
+// the timeout expires, the failures of the last attempt are reported to the original TestingT.
+// It returns the response and request of the last attempt and the number of attempts.
+func (e *eventually) run(specTest *SpecTest, attempt func() (*http.Response, *http.Request)) (*http.Response, *http.Request, int) {
+	original := specTest.t
+	defer func() {
+		specTest.t = original
+	}()
+
+	deadline := time.Now().Add(e.timeout)
+	for attempts := 1; ; attempts++ {
+		recorder := newRecordingT()
+		specTest.t = recorder.wrap(original)
+		res, req := recorder.run(attempt)
+		specTest.t = original
+
+		if recorder.passed() {
+			return res, req, attempts
+		}
+		if time.Now().Add(e.interval).After(deadline) {
+			original.Errorf("assertions did not pass after %d attempts in %s. The last failure is:", attempts, e.timeout)
+			recorder.replay(original)
+			return res, req, attempts
+		}
+		time.Sleep(e.interval)
+	}
+}
+
+// recordingFailure is a failure recorded by recordingT.
+type recordingFailure struct {
+	// fatal is true if the failure was reported by Fatal or Fatalf
+	fatal bool
+	// message is the failure message
+	message string
+}
+
+// recordingT is a TestingT that records failures instead of reporting them.
+type recordingT struct {
+	// failures is the list of recorded failures
+	failures []recordingFailure
+}
+
+// namedRecordingT is a recordingT that also exposes the name of the original test.
+// DefaultVerifier adds the name to the failure message.
+type namedRecordingT struct {
+	*recordingT
+	named interface{ Name() string }
+}
+
+// Name returns the name of the original test.
+func (n *namedRecordingT) Name() string {
+	return n.named.Name()
+}
+
+// errRecordingTFatal is used to stop an attempt when Fatal or Fatalf is called.
+type errRecordingTFatal struct{}
+
+var _ TestingT = &recordingT{}
+
+// newRecordingT creates a new recordingT.
+func newRecordingT() *recordingT {
+	return &recordingT{}
+}
+
+// wrap returns the recordingT as a TestingT that has the same name as the original test.
+func (r *recordingT) wrap(original TestingT) TestingT {
+	if named, ok := original.(interface{ Name() string }); ok {
+		return &namedRecordingT{recordingT: r, named: named}
+	}
+	return r
+}
+
+// Errorf records the failure
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, recordingFailure{message: fmt.Sprintf(format, args...)})
+}
+
+// Fatal records the failure and stops the attempt
+func (r *recordingT) Fatal(args ...interface{}) {
+	r.failures = append(r.failures, recordingFailure{fatal: true, message: fmt.Sprint(args...)})
+	panic(errRecordingTFatal{})
+}
+
+// Fatalf records the failure and stops the attempt
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, recordingFailure{fatal: true, message: fmt.Sprintf(format, args...)})
+	panic(errRecordingTFatal{})
+}
+
+// run runs the attempt. It recovers from the panic raised by Fatal or Fatalf.
+func (r *recordingT) run(attempt func() (*http.Response, *http.Request)) (res *http.Response, req *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			if _, ok := err.(errRecordingTFatal); !ok {
+				panic(err)
+			}
+		}
+	}()
+	return attempt()
+}
+
+// passed returns true if no failure was recorded.
+func (r *recordingT) passed() bool {
+	return len(r.failures) == 0
+}
+
+// replay reports the recorded failures to the given TestingT.
+func (r *recordingT) replay(t TestingT) {
+	for _, f := range r.failures {
+		if f.fatal {
+			t.Fatal(f.message)
+			return
+		}
+		t.Errorf("%s", f.message)
+	}
+}
@@ -0,0 +1,156 @@
+package spectest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/jsonpath"
+)
+
+// failureCaptorT is a TestingT that captures failures instead of failing the test.
+type failureCaptorT struct {
+	messages []string
+}
+
+func (f *failureCaptorT) Errorf(format string, args ...interface{}) {
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+func (f *failureCaptorT) Fatal(args ...interface{}) {
+	f.messages = append(f.messages, fmt.Sprint(args...))
+}
+
+func (f *failureCaptorT) Fatalf(format string, args ...interface{}) {
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+// jobHandler returns "pending" until the job is requested doneAfter times.
+func jobHandler(doneAfter int32) (http.HandlerFunc, *int32) {
+	var calls int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) < doneAfter {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"status": "pending"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "done"}`))
+	}, &calls
+}
+
+func TestResponseEventually(t *testing.T) {
+	t.Run("pass after some attempts", func(t *testing.T) {
+		handler, calls := jobHandler(3)
+		reporter := &RecorderCaptor{}
+
+		spectest.New().
+			Report(reporter).
+			HandlerFunc(handler).
+			Get("/jobs/1").
+			Expect(t).
+			Eventually(time.Second, 5*time.Millisecond).
+			Status(http.StatusOK).
+			Assert(jsonpath.Equal("$.status", "done")).
+			Body(`{"status": "done"}`).
+			End()
+
+		spectest.DefaultVerifier{}.Equal(t, int32(3), atomic.LoadInt32(calls))
+		spectest.DefaultVerifier{}.Equal(t, 3, reporter.capturedRecorder.Meta.Attempts)
+		spectest.DefaultVerifier{}.Equal(t, 2, len(reporter.capturedRecorder.Events))
+		// Only the assertions of the last attempt are recorded.
+		spectest.DefaultVerifier{}.Equal(t, 3, len(reporter.capturedRecorder.AssertionResults))
+		spectest.DefaultVerifier{}.Equal(t, true, *reporter.capturedRecorder.Meta.Passed)
+	})
+
+	t.Run("report the last failure on timeout", func(t *testing.T) {
+		handler, calls := jobHandler(1000)
+		captor := &failureCaptorT{}
+
+		spectest.New().
+			HandlerFunc(handler).
+			Get("/jobs/1").
+			Expect(captor).
+			Eventually(50*time.Millisecond, 10*time.Millisecond).
+			Status(http.StatusOK).
+			End()
+
+		if atomic.LoadInt32(calls) < 2 {
+			t.Errorf("expected more than one attempt, got %d", atomic.LoadInt32(calls))
+		}
+		if len(captor.messages) != 2 {
+			t.Fatalf("expected 2 messages, got %d: %v", len(captor.messages), captor.messages)
+		}
+		if !strings.Contains(captor.messages[0], "assertions did not pass after") {
+			t.Errorf("unexpected message: %s", captor.messages[0])
+		}
+		if !strings.Contains(captor.messages[1], "actual  : 202") {
+			t.Errorf("unexpected message: %s", captor.messages[1])
+		}
+	})
+
+	t.Run("use the mocks in every attempt", func(t *testing.T) {
+		var calls int32
+		getJob := spectest.NewMock().
+			Get("http://localhost:8080/jobs/1").
+			RespondWith().
+			Status(http.StatusOK).
+			Body(`{"status": "done"}`).
+			End()
+		reporter := &RecorderCaptor{}
+
+		spectest.New().
+			Report(reporter).
+			Mocks(getJob).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res, err := http.Get("http://localhost:8080/jobs/1")
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				defer res.Body.Close() //nolint
+				if atomic.AddInt32(&calls, 1) < 2 {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+				w.WriteHeader(res.StatusCode)
+				_, _ = io.Copy(w, res.Body)
+			}).
+			Get("/jobs/1").
+			Expect(t).
+			Eventually(time.Second, 5*time.Millisecond).
+			Status(http.StatusOK).
+			Body(`{"status": "done"}`).
+			End()
+
+		spectest.DefaultVerifier{}.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		spectest.DefaultVerifier{}.Equal(t, 2, reporter.capturedRecorder.Meta.Attempts)
+		// Only the mock interaction of the last attempt is recorded.
+		requests := 0
+		for _, event := range reporter.capturedRecorder.Events {
+			if _, ok := event.(spectest.HTTPRequest); ok {
+				requests++
+			}
+		}
+		spectest.DefaultVerifier{}.Equal(t, 2, requests)
+	})
+
+	t.Run("send the request once if the first attempt passes", func(t *testing.T) {
+		handler, calls := jobHandler(1)
+
+		spectest.New().
+			HandlerFunc(handler).
+			Get("/jobs/1").
+			Expect(t).
+			Eventually(time.Second, 5*time.Millisecond).
+			Status(http.StatusOK).
+			End()
+
+		spectest.DefaultVerifier{}.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+}
@@ -0,0 +1,377 @@
+package spectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nao1215/spectest/version"
+)
+
+// harVersion is the version of the HTTP Archive format.
+const harVersion = "1.2"
+
+type (
+	// HARFormatter implementation of a ReportFormatter.
+	// It writes every interaction of the test as an HTTP Archive (HAR) 1.2 file,
+	// so the traffic can be opened in browser devtools and other HAR viewers.
+	HARFormatter struct {
+		// storagePath is the path where the report will be saved
+		storagePath string
+		// fs is the file system used to save the report
+		fs fileSystem
+		// metaJSON is true if the meta data is saved to a JSON file next to the report
+		metaJSON bool
+	}
+
+	// harFile is the root of the HAR file.
+	harFile struct {
+		Log harLog `json:"log"`
+	}
+
+	// harLog is the log of the HAR file.
+	harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Pages   []harPage  `json:"pages"`
+		Entries []harEntry `json:"entries"`
+	}
+
+	// harCreator is the application that created the HAR file.
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	// harPage is the test that the entries belong to.
+	harPage struct {
+		StartedDateTime string         `json:"startedDateTime"`
+		ID              string         `json:"id"`
+		Title           string         `json:"title"`
+		PageTimings     harPageTimings `json:"pageTimings"`
+		Comment         string         `json:"comment,omitempty"`
+	}
+
+	// harPageTimings is the timings of the test. OnLoad is the duration of the test in milliseconds.
+	harPageTimings struct {
+		OnContentLoad float64 `json:"onContentLoad"`
+		OnLoad        float64 `json:"onLoad"`
+	}
+
+	// harEntry is a pair of a request and a response.
+	harEntry struct {
+		Pageref         string      `json:"pageref"`
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+		Comment         string      `json:"comment,omitempty"`
+	}
+
+	// harRequest is the request of the entry.
+	harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		PostData    *harPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	// harResponse is the response of the entry.
+	harResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		Content     harContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	// harCookie is a cookie of the request or the response.
+	harCookie struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Path     string `json:"path,omitempty"`
+		Domain   string `json:"domain,omitempty"`
+		Expires  string `json:"expires,omitempty"`
+		HTTPOnly bool   `json:"httpOnly,omitempty"`
+		Secure   bool   `json:"secure,omitempty"`
+	}
+
+	// harNameValue is a header or a query parameter.
+	harNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// harPostData is the body of the request.
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		// Encoding is "base64" if the text is the base64 encoded binary body. HAR has no encoding
+		// of the request body, so it is a custom field that starts with an underscore.
+		Encoding string `json:"_encoding,omitempty"`
+	}
+
+	// harContent is the body of the response.
+	harContent struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	// harTimings is the timings of the entry in milliseconds.
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// Format formats the events received by the recorder. It panics if the report can not be generated.
+func (h *HARFormatter) Format(recorder *Recorder) {
+	if err := h.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError formats the events received by the recorder.
+// It returns the error if the report can not be generated.
+func (h *HARFormatter) FormatWithError(recorder *Recorder) error {
+	har, err := newHARFile(recorder)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := h.fs.mkdirAll(h.storagePath, os.ModePerm); err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("%s.har", recorder.Meta.reportFileName())
+	saveFilesTo := filepath.Join(h.storagePath, fileName)
+	f, err := h.fs.create(saveFilesTo)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	s, _ := filepath.Abs(saveFilesTo)
+	fmt.Printf("Created HAR file (%s): %s\n", fileName, filepath.FromSlash(s))
+
+	if h.metaJSON {
+		return writeMetaJSON(h.fs, h.storagePath, recorder.Meta)
+	}
+	return nil
+}
+
+// newHARFile converts the events of the recorder to the HAR file.
+// Each request is paired with the first following response that is sent back from its target.
+func newHARFile(recorder *Recorder) (*harFile, error) {
+	creator := version.TagVersion
+	if creator == "" {
+		creator = "unknown"
+	}
+	har := &harFile{
+		Log: harLog{
+			Version: harVersion,
+			Creator: harCreator{Name: version.CommandName, Version: creator},
+			Pages:   []harPage{},
+			Entries: []harEntry{},
+		},
+	}
+
+	pageID := "page_1"
+	var started time.Time
+	if len(recorder.Events) > 0 {
+		started = recorder.Events[0].GetTime()
+	}
+	page := harPage{
+		StartedDateTime: harTime(started),
+		ID:              pageID,
+		Title:           recorder.Title,
+		Comment:         recorder.SubTitle,
+	}
+	if recorder.Meta != nil {
+		page.PageTimings.OnLoad = milliseconds(time.Duration(recorder.Meta.Duration))
+	}
+	har.Log.Pages = append(har.Log.Pages, page)
+
+	paired := map[int]bool{}
+	for i, event := range recorder.Events {
+		req, ok := event.(HTTPRequest)
+		if !ok || req.Value == nil {
+			continue
+		}
+		entry := harEntry{
+			Pageref:         pageID,
+			StartedDateTime: harTime(req.Timestamp),
+			Comment:         fmt.Sprintf("%s -> %s", req.Source, req.Target),
+		}
+		request, err := newHARRequest(req.Value)
+		if err != nil {
+			return nil, err
+		}
+		entry.Request = request
+
+		for j := i + 1; j < len(recorder.Events); j++ {
+			res, ok := recorder.Events[j].(HTTPResponse)
+			if !ok || paired[j] || res.Value == nil || res.Source != req.Target || res.Target != req.Source {
+				continue
+			}
+			paired[j] = true
+			response, err := newHARResponse(res.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry.Response = response
+			entry.Time = milliseconds(res.Timestamp.Sub(req.Timestamp))
+			entry.Timings.Wait = entry.Time
+			break
+		}
+		if entry.Response.HTTPVersion == "" {
+			// The request did not receive a response, e.g. no mock matched it.
+			entry.Response = harResponse{
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []harCookie{},
+				Headers:     []harNameValue{},
+				HeadersSize: -1,
+				BodySize:    -1,
+			}
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+	return har, nil
+}
+
+// newHARRequest converts the http request to the HAR request.
+func newHARRequest(req *http.Request) (harRequest, error) {
+	body, err := readAndRestoreBody(&req.Body)
+	if err != nil {
+		return harRequest{}, err
+	}
+
+	request := harRequest{
+		Method:      req.Method,
+		URL:         absoluteURL(req),
+		HTTPVersion: harHTTPVersion(req.Proto),
+		Cookies:     []harCookie{},
+		Headers:     harNameValues(req.Header),
+		QueryString: harNameValues(req.URL.Query()),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, cookie := range req.Cookies() {
+		request.Cookies = append(request.Cookies, harCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	if len(body) > 0 {
+		request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type")}
+		request.PostData.Text, request.PostData.Encoding = encodeCassetteBody(body)
+	}
+	return request, nil
+}
+
+// newHARResponse converts the http response to the HAR response.
+func newHARResponse(res *http.Response) (harResponse, error) {
+	body, err := readAndRestoreBody(&res.Body)
+	if err != nil {
+		return harResponse{}, err
+	}
+
+	response := harResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: harHTTPVersion(res.Proto),
+		Cookies:     []harCookie{},
+		Headers:     harNameValues(res.Header),
+		Content: harContent{
+			Size:     len(body),
+			MimeType: res.Header.Get("Content-Type"),
+		},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	response.Content.Text, response.Content.Encoding = encodeCassetteBody(body)
+	for _, cookie := range res.Cookies() {
+		c := harCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			c.Expires = harTime(cookie.Expires)
+		}
+		response.Cookies = append(response.Cookies, c)
+	}
+	return response, nil
+}
+
+// readAndRestoreBody reads the body and replaces it with a new reader of the same content.
+func readAndRestoreBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// harNameValues converts the headers or the query parameters to the sorted HAR name-value pairs.
+func harNameValues(values map[string][]string) []harNameValue {
+	pairs := []harNameValue{}
+	for name, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+// harHTTPVersion returns the protocol version. httptest does not always set it.
+func harHTTPVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+// harTime formats the time in ISO 8601 as required by HAR.
+func harTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// milliseconds converts the duration to milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
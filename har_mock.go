@@ -0,0 +1,180 @@
+package spectest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HARFilter configures how MocksFromHAR converts the HAR entries to mocks.
+type HARFilter func(*harFilterConfig)
+
+// harFilterConfig is the configuration built from the HARFilter list.
+type harFilterConfig struct {
+	// hosts is the list of hosts whose entries are kept. All entries are kept if it is empty.
+	hosts []string
+	// matchHeaders is the list of request headers that the mocks match on
+	matchHeaders []string
+	// dropHeaders is the list of response headers that are not replayed
+	dropHeaders []string
+}
+
+// HARHosts keeps only the entries whose request host is one of the given hosts.
+// The host is compared with and without the port. e.g. "api.example.com" or "localhost:8080"
+func HARHosts(hosts ...string) HARFilter {
+	return func(c *harFilterConfig) {
+		c.hosts = append(c.hosts, hosts...)
+	}
+}
+
+// HARMatchHeaders adds the request headers that the mocks match on.
+// By default, the mocks match on the method, the url, the query parameters and the body only.
+func HARMatchHeaders(names ...string) HARFilter {
+	return func(c *harFilterConfig) {
+		c.matchHeaders = append(c.matchHeaders, names...)
+	}
+}
+
+// HARDropHeaders drops the volatile response headers, e.g. Date or X-Request-Id, from the mocks.
+// Content-Encoding, Content-Length and Transfer-Encoding are always dropped because
+// HAR files store the decoded response body.
+func HARDropHeaders(names ...string) HARFilter {
+	return func(c *harFilterConfig) {
+		c.dropHeaders = append(c.dropHeaders, names...)
+	}
+}
+
+// MocksFromHAR reads the HTTP Archive (HAR) file and converts each entry to a mock, so the recorded
+// traffic can be passed to SpecTest.Mocks or NewStandaloneMocks. A mock matches the method, the scheme,
+// host and path of the url, the query parameters and the request body of the entry, and responds with
+// the recorded status, headers, cookies and body. Entries without a response (status 0) are skipped.
+func MocksFromHAR(path string, filters ...HARFilter) ([]*Mock, error) {
+	config := &harFilterConfig{
+		dropHeaders: []string{"Content-Encoding", "Content-Length", "Transfer-Encoding"},
+	}
+	for _, filter := range filters {
+		filter(config)
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAR file: %w", err)
+	}
+	har := harFile{}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file %s: %w", path, err)
+	}
+
+	mocks := []*Mock{}
+	for i, entry := range har.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url in HAR entry %d: %w", i, err)
+		}
+		if !config.keepHost(u) {
+			continue
+		}
+		m, err := config.mock(entry, u)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HAR entry %d: %w", i, err)
+		}
+		mocks = append(mocks, m)
+	}
+	return mocks, nil
+}
+
+// keepHost returns true if the entry for the url is kept.
+func (c *harFilterConfig) keepHost(u *url.URL) bool {
+	if len(c.hosts) == 0 {
+		return true
+	}
+	for _, host := range c.hosts {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDropped returns true if the response header is not replayed.
+// HTTP/2 pseudo headers such as ":status" are always dropped.
+func (c *harFilterConfig) isDropped(name string) bool {
+	if strings.HasPrefix(name, ":") {
+		return true
+	}
+	for _, drop := range c.dropHeaders {
+		if strings.EqualFold(drop, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// mock converts the HAR entry to a mock.
+func (c *harFilterConfig) mock(entry harEntry, u *url.URL) (*Mock, error) {
+	m := NewMock()
+	m.request.method = entry.Request.Method
+	m.request.exactURL(u)
+	for _, name := range c.matchHeaders {
+		for _, header := range entry.Request.Headers {
+			if strings.EqualFold(header.Name, name) {
+				m.request.Header(name, "^"+regexp.QuoteMeta(header.Value)+"$")
+			}
+		}
+	}
+	if entry.Request.PostData != nil {
+		body, err := decodeCassetteBody(entry.Request.PostData.Text, entry.Request.PostData.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		m.request.body = string(body)
+	}
+
+	body, err := decodeCassetteBody(entry.Response.Content.Text, entry.Response.Content.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	m.response.statusCode = entry.Response.Status
+	m.response.body = string(body)
+
+	hasSetCookie := false
+	for _, header := range entry.Response.Headers {
+		if c.isDropped(header.Name) {
+			continue
+		}
+		if textproto.CanonicalMIMEHeaderKey(header.Name) == "Set-Cookie" {
+			hasSetCookie = true
+		}
+		m.response.Header(header.Name, header.Value)
+	}
+	if hasSetCookie {
+		// The cookies are already replayed by the Set-Cookie headers.
+		return m, nil
+	}
+	for _, cookie := range entry.Response.Cookies {
+		replayed := NewCookie(cookie.Name).
+			Value(cookie.Value).
+			Path(cookie.Path).
+			Domain(cookie.Domain).
+			HTTPOnly(cookie.HTTPOnly).
+			Secure(cookie.Secure)
+		if cookie.Expires != "" {
+			expires, err := time.Parse(time.RFC3339Nano, cookie.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cookie expires: %w", err)
+			}
+			replayed.Expires(expires)
+		}
+		m.response.Cookies(replayed)
+	}
+	return m, nil
+}
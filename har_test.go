@@ -0,0 +1,248 @@
+package spectest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+)
+
+func TestHARFormatter(t *testing.T) {
+	dir := t.TempDir()
+	getUser := spectest.NewMock().
+		Get("http://localhost:8080/users/1").
+		RespondWith().
+		Status(http.StatusOK).
+		Body(`{"name": "Tom"}`).
+		End()
+
+	spectest.New("get user").
+		Report(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindHAR})).
+		CustomReportName("get_user").
+		Mocks(getUser).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := http.Get("http://localhost:8080/users/1")
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer res.Body.Close() //nolint
+			body, _ := io.ReadAll(res.Body)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}).
+		Get("/user").
+		Query("id", "1").
+		Cookie("theme", "dark").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	data, err := os.ReadFile(filepath.Join(dir, "get_user.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := struct {
+		Log struct {
+			Version string `json:"version"`
+			Pages   []struct {
+				Title string `json:"title"`
+			} `json:"pages"`
+			Entries []struct {
+				Request struct {
+					Method      string                   `json:"method"`
+					URL         string                   `json:"url"`
+					Cookies     []map[string]interface{} `json:"cookies"`
+					QueryString []map[string]string      `json:"queryString"`
+				} `json:"request"`
+				Response struct {
+					Status  int                      `json:"status"`
+					Cookies []map[string]interface{} `json:"cookies"`
+					Content struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+					} `json:"content"`
+				} `json:"response"`
+				Comment string `json:"comment"`
+			} `json:"entries"`
+		} `json:"log"`
+	}{}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatal(err)
+	}
+
+	spectest.DefaultVerifier{}.Equal(t, "1.2", har.Log.Version)
+	spectest.DefaultVerifier{}.Equal(t, "GET /user?id=1", har.Log.Pages[0].Title)
+	spectest.DefaultVerifier{}.Equal(t, 2, len(har.Log.Entries))
+
+	inbound := har.Log.Entries[0]
+	spectest.DefaultVerifier{}.Equal(t, "client -> server", inbound.Comment)
+	spectest.DefaultVerifier{}.Equal(t, "http://server/user?id=1", inbound.Request.URL)
+	spectest.DefaultVerifier{}.Equal(t, []map[string]string{{"name": "id", "value": "1"}}, inbound.Request.QueryString)
+	spectest.DefaultVerifier{}.Equal(t, []map[string]interface{}{{"name": "theme", "value": "dark"}}, inbound.Request.Cookies)
+	spectest.DefaultVerifier{}.Equal(t, http.StatusOK, inbound.Response.Status)
+	spectest.DefaultVerifier{}.Equal(t, []map[string]interface{}{{"name": "session", "value": "abc", "httpOnly": true}}, inbound.Response.Cookies)
+	spectest.DefaultVerifier{}.Equal(t, "application/json", inbound.Response.Content.MimeType)
+	spectest.DefaultVerifier{}.Equal(t, `{"name": "Tom"}`, inbound.Response.Content.Text)
+
+	mock := har.Log.Entries[1]
+	spectest.DefaultVerifier{}.Equal(t, "server -> localhost:8080", mock.Comment)
+	spectest.DefaultVerifier{}.Equal(t, http.MethodGet, mock.Request.Method)
+	spectest.DefaultVerifier{}.Equal(t, "http://localhost:8080/users/1", mock.Request.URL)
+	spectest.DefaultVerifier{}.Equal(t, `{"name": "Tom"}`, mock.Response.Content.Text)
+}
+
+func TestMocksFromHAR(t *testing.T) {
+	t.Run("replay the entries of the partner api", func(t *testing.T) {
+		mocks, err := spectest.MocksFromHAR("testdata/partner.har",
+			spectest.HARHosts("partner.example.com"),
+			spectest.HARMatchHeaders("X-Api-Version"),
+			spectest.HARDropHeaders("Date"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, 2, len(mocks))
+
+		cli := &http.Client{Transport: http.DefaultTransport}
+		reset := spectest.NewStandaloneMocks(mocks...).HTTPClient(cli).End()
+		defer reset()
+
+		req, err := http.NewRequest(http.MethodGet, "https://partner.example.com/v1/users/1?fields=name", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Api-Version", "2")
+		res, err := cli.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close() //nolint
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, http.StatusOK, res.StatusCode)
+		spectest.DefaultVerifier{}.Equal(t, `{"name": "Tom"}`, string(body))
+		spectest.DefaultVerifier{}.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		spectest.DefaultVerifier{}.Equal(t, "", res.Header.Get("Date"))
+		spectest.DefaultVerifier{}.Equal(t, "", res.Header.Get("Content-Encoding"))
+		spectest.DefaultVerifier{}.Equal(t, 1, len(res.Cookies()))
+		spectest.DefaultVerifier{}.Equal(t, "abc", res.Cookies()[0].Value)
+		spectest.DefaultVerifier{}.Equal(t, true, res.Cookies()[0].HttpOnly)
+
+		// The body is matched semantically, so a different body does not match.
+		res, err = cli.Post("https://partner.example.com/v1/users", "application/json", strings.NewReader(`{"name": "Tom"}`))
+		if err == nil {
+			res.Body.Close() //nolint
+			t.Fatal("expected the request with a different body not to match")
+		}
+		res, err = cli.Post("https://partner.example.com/v1/users", "application/json", strings.NewReader(`{"name": "Jerry"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close() //nolint
+		spectest.DefaultVerifier{}.Equal(t, http.StatusCreated, res.StatusCode)
+	})
+
+	t.Run("import the HAR file exported by the HAR report", func(t *testing.T) {
+		dir := t.TempDir()
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			res, err := http.Get("http://localhost:8080/users/1")
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer res.Body.Close() //nolint
+			body, _ := io.ReadAll(res.Body)
+			_, _ = w.Write(body)
+		}
+
+		spectest.New().
+			Report(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindHAR})).
+			CustomReportName("get_user").
+			Mocks(spectest.NewMock().
+				Get("http://localhost:8080/users/1").
+				RespondWith().
+				Status(http.StatusOK).
+				Body(`{"name": "Tom"}`).
+				End()).
+			HandlerFunc(handler).
+			Get("/user").
+			Expect(t).
+			Body(`{"name": "Tom"}`).
+			End()
+
+		mocks, err := spectest.MocksFromHAR(filepath.Join(dir, "get_user.har"), spectest.HARHosts("localhost"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, 1, len(mocks))
+
+		spectest.New().
+			Mocks(mocks...).
+			HandlerFunc(handler).
+			Get("/user").
+			Expect(t).
+			Body(`{"name": "Tom"}`).
+			End()
+	})
+}
+
+func TestHARBinaryRequestBody(t *testing.T) {
+	dir := t.TempDir()
+	binary := string([]byte{0x89, 'P', 'N', 'G', 0x00, 0xff})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		res, err := http.Post("http://localhost:8080/images", "image/png", strings.NewReader(binary))
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close() //nolint
+		w.WriteHeader(res.StatusCode)
+	}
+
+	spectest.New().
+		Report(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindHAR})).
+		CustomReportName("upload").
+		Mocks(spectest.NewMock().
+			Post("http://localhost:8080/images").
+			Body(binary).
+			RespondWith().
+			Status(http.StatusCreated).
+			End()).
+		HandlerFunc(handler).
+		Post("/upload").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+
+	data, err := os.ReadFile(filepath.Join(dir, "upload.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"_encoding": "base64"`) {
+		t.Errorf("expected the binary request body to be base64 encoded:\n%s", data)
+	}
+
+	mocks, err := spectest.MocksFromHAR(filepath.Join(dir, "upload.har"), spectest.HARHosts("localhost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectest.DefaultVerifier{}.Equal(t, 1, len(mocks))
+
+	// The mock matches the decoded binary body.
+	spectest.New().
+		Mocks(mocks...).
+		HandlerFunc(handler).
+		Post("/upload").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+}
@@ -0,0 +1,111 @@
+package spectest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// mockTransportKey is the context key of the mock transport bound to the inbound request.
+type mockTransportKey struct{}
+
+// withMockTransport returns a copy of ctx that carries the mock transport.
+func withMockTransport(ctx context.Context, transport *Transport) context.Context {
+	return context.WithValue(ctx, mockTransportKey{}, transport)
+}
+
+// mockTransportFromContext returns the mock transport bound to ctx, if any.
+func mockTransportFromContext(ctx context.Context) (*Transport, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	transport, ok := ctx.Value(mockTransportKey{}).(*Transport)
+	return transport, ok && transport != nil
+}
+
+// ClientFromContext returns a http client that sends requests to the mocks of the SpecTest
+// whose handler received ctx. It is intended for handler code that uses its own http client
+// when the mocks are isolated with SpecTest.IsolateMocks.
+// If ctx is not bound to isolated mocks, http.DefaultClient is returned.
+func ClientFromContext(ctx context.Context) *http.Client {
+	transport, ok := mockTransportFromContext(ctx)
+	if !ok {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: transport}
+}
+
+// isolatedDispatcher replaces http.DefaultTransport while at least one SpecTest with isolated mocks is running.
+// It sends the request to the mock transport bound to the request context and every other request to
+// the original transport, so parallel tests never see each other's mocks.
+type isolatedDispatcher struct {
+	// native is http.DefaultTransport as it was when the package was initialized
+	native http.RoundTripper
+}
+
+// RoundTrip sends the request to the mock transport bound to the request context.
+func (d *isolatedDispatcher) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := mockTransportFromContext(req.Context()); ok {
+		return transport.RoundTrip(req)
+	}
+	return d.native.RoundTrip(req)
+}
+
+var (
+	// pristineTransportOnce guards pristineTransport
+	pristineTransportOnce sync.Once
+	// pristineTransport is http.DefaultTransport before any SpecTest replaced it
+	pristineTransport http.RoundTripper
+)
+
+func init() {
+	nativeDefaultTransport()
+}
+
+// nativeDefaultTransport returns http.DefaultTransport as it was when the package was initialized.
+// The current http.DefaultTransport is not used, because it may be the mock transport of a SpecTest
+// without isolated mocks that is running at the same time.
+func nativeDefaultTransport() http.RoundTripper {
+	pristineTransportOnce.Do(func() {
+		pristineTransport = http.DefaultTransport
+	})
+	return pristineTransport
+}
+
+var (
+	// dispatcherMu guards dispatcher and dispatcherUsers
+	dispatcherMu sync.Mutex
+	// dispatcher is the installed isolatedDispatcher. It is nil when no isolated SpecTest is running.
+	dispatcher *isolatedDispatcher
+	// dispatcherUsers is the number of isolated SpecTest that are running.
+	dispatcherUsers int
+)
+
+// acquireDispatcher installs the isolatedDispatcher as http.DefaultTransport if it is not installed yet.
+// It returns http.DefaultTransport as it was when the package was initialized.
+func acquireDispatcher() http.RoundTripper {
+	dispatcherMu.Lock()
+	defer dispatcherMu.Unlock()
+
+	if dispatcherUsers == 0 {
+		dispatcher = &isolatedDispatcher{native: nativeDefaultTransport()}
+		http.DefaultTransport = dispatcher
+	}
+	dispatcherUsers++
+	return dispatcher.native
+}
+
+// releaseDispatcher restores the original http.DefaultTransport when the last isolated SpecTest has finished.
+func releaseDispatcher() {
+	dispatcherMu.Lock()
+	defer dispatcherMu.Unlock()
+
+	if dispatcherUsers == 0 {
+		return
+	}
+	dispatcherUsers--
+	if dispatcherUsers == 0 {
+		http.DefaultTransport = dispatcher.native
+		dispatcher = nil
+	}
+}
@@ -0,0 +1,164 @@
+package spectest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/nao1215/spectest"
+)
+
+func TestIsolateMocks(t *testing.T) {
+	t.Run("parallel tests never see each other's mocks", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			i := i
+			t.Run(fmt.Sprintf("spec %d", i), func(t *testing.T) {
+				t.Parallel()
+
+				// Every spec mocks the same URL with a different body.
+				getUser := spectest.NewMock().
+					Get("http://localhost:8080/user").
+					RespondWith().
+					Status(http.StatusOK).
+					Body(fmt.Sprintf(`{"id": %d}`, i)).
+					End()
+
+				handler := func(w http.ResponseWriter, r *http.Request) {
+					req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, "http://localhost:8080/user", nil)
+					if err != nil {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					defer res.Body.Close() //nolint
+					body, _ := io.ReadAll(res.Body)
+					_, _ = w.Write(body)
+				}
+
+				res := spectest.New().
+					IsolateMocks().
+					Mocks(getUser).
+					HandlerFunc(handler).
+					Get("/user").
+					Expect(t).
+					Status(http.StatusOK).
+					Body(fmt.Sprintf(`{"id": %d}`, i)).
+					End()
+
+				spectest.DefaultVerifier{}.Equal(t, 0, len(res.UnmatchedMocks()))
+			})
+		}
+	})
+
+	t.Run("client from context reaches the isolated mocks", func(t *testing.T) {
+		getUser := spectest.NewMock().
+			Get("http://localhost:8080/user").
+			RespondWith().
+			Status(http.StatusOK).
+			Body(`{"id": 1}`).
+			End()
+
+		spectest.New().
+			IsolateMocks().
+			Mocks(getUser).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res, err := spectest.ClientFromContext(r.Context()).Get("http://localhost:8080/user")
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				defer res.Body.Close() //nolint
+				body, _ := io.ReadAll(res.Body)
+				_, _ = w.Write(body)
+			}).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusOK).
+			Body(`{"id": 1}`).
+			End()
+	})
+
+	t.Run("default transport is restored", func(t *testing.T) {
+		before := http.DefaultTransport
+		getUser := spectest.NewMock().
+			Get("http://localhost:8080/user").
+			RespondWith().
+			Status(http.StatusOK).
+			End()
+
+		spectest.New().
+			IsolateMocks().
+			Mocks(getUser).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if http.DefaultTransport == before {
+					t.Error("expected http.DefaultTransport to be replaced while the test is running")
+				}
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, "http://localhost:8080/user", nil)
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				defer res.Body.Close() //nolint
+				w.WriteHeader(res.StatusCode)
+			}).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+
+		if http.DefaultTransport != before {
+			t.Error("expected http.DefaultTransport to be restored")
+		}
+	})
+
+	t.Run("default transport is restored to the transport before any mock", func(t *testing.T) {
+		before := http.DefaultTransport
+		t.Cleanup(func() { http.DefaultTransport = before })
+
+		// A transport left over by another test must not become the restore target.
+		http.DefaultTransport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("leftover transport")
+		})
+
+		spectest.New().
+			IsolateMocks().
+			Mocks(spectest.NewMock().
+				Get("http://localhost:8080/user").
+				RespondWith().
+				Status(http.StatusOK).
+				End()).
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}).
+			Get("/user").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+
+		if http.DefaultTransport != before {
+			t.Error("expected http.DefaultTransport to be restored to the transport before any mock")
+		}
+	})
+
+	t.Run("client from context without mocks is the default client", func(t *testing.T) {
+		if spectest.ClientFromContext(context.Background()) != http.DefaultClient {
+			t.Error("expected http.DefaultClient")
+		}
+	})
+}
+
+// roundTripperFunc is a http.RoundTripper implemented by a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,277 @@
+package spectest
+
+import (
+	"bytes"
+	_ "embed" // embed the JSON schema of the JSON report
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONReportVersion is the version of the JSON report format.
+// It is changed only if the format changes in a way that breaks the readers.
+const JSONReportVersion = "1"
+
+// jsonReportSuffix is the suffix of the JSON report file. It is not ".json",
+// so the JSON reports are not confused with the meta data files and the cassettes.
+const jsonReportSuffix = ".report.json"
+
+// JSONReportSchema is the JSON Schema (draft-07) of the JSON report.
+//
+//go:embed schema/report.schema.json
+var JSONReportSchema string
+
+// The types of the events in the JSON report.
+const (
+	// JSONReportEventHTTPRequest is the type of the http request event.
+	JSONReportEventHTTPRequest = "http_request"
+	// JSONReportEventHTTPResponse is the type of the http response event.
+	JSONReportEventHTTPResponse = "http_response"
+	// JSONReportEventMessageRequest is the type of the message request event.
+	JSONReportEventMessageRequest = "message_request"
+	// JSONReportEventMessageResponse is the type of the message response event.
+	JSONReportEventMessageResponse = "message_response"
+)
+
+type (
+	// JSONReportFormatter implementation of a ReportFormatter.
+	// It writes the recorder as a JSON document, "<report file name>.report.json",
+	// so that the dashboards and other tools can read the report without scraping HTML.
+	JSONReportFormatter struct {
+		// storagePath is the path where the report will be saved
+		storagePath string
+		// fs is the file system used to save the report
+		fs fileSystem
+		// metaJSON is true if the meta data is saved to a JSON file next to the report
+		metaJSON bool
+	}
+
+	// JSONReport is the content of the JSON report. It is read by ReadJSONReport.
+	// The format is described by JSONReportSchema.
+	JSONReport struct {
+		// Version is the version of the format. See JSONReportVersion.
+		Version string `json:"version"`
+		// Title is the title of the report
+		Title string `json:"title"`
+		// SubTitle is the subtitle of the report
+		SubTitle string `json:"sub_title,omitempty"`
+		// Meta is the meta data of the report
+		Meta Meta `json:"meta"`
+		// Events is the list of events in order of time
+		Events []JSONReportEvent `json:"events"`
+		// Assertions are the results of the assertions in the order they were made
+		Assertions []JSONReportAssertion `json:"assertions,omitempty"`
+	}
+
+	// JSONReportAssertion is the result of an assertion of the JSON report.
+	JSONReportAssertion struct {
+		// Name is the name of the assertion. e.g. "status code", "header Content-Type"
+		Name string `json:"name"`
+		// Passed is true if the assertion passed
+		Passed bool `json:"passed"`
+		// Expected is the expected value
+		Expected string `json:"expected,omitempty"`
+		// Actual is the actual value
+		Actual string `json:"actual,omitempty"`
+		// Message is the failure message
+		Message string `json:"message,omitempty"`
+		// Diff is the unified diff of the expected and actual values of the failed assertion
+		Diff string `json:"diff,omitempty"`
+		// Timestamp is the time of the assertion
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	// JSONReportEvent is an event of the JSON report.
+	JSONReportEvent struct {
+		// Type is the type of the event. e.g. JSONReportEventHTTPRequest
+		Type string `json:"type"`
+		// Source is the name of the sender
+		Source string `json:"source"`
+		// Target is the name of the receiver
+		Target string `json:"target"`
+		// Timestamp is the time of the event
+		Timestamp time.Time `json:"timestamp"`
+		// Method is the method of the http request
+		Method string `json:"method,omitempty"`
+		// URL is the url of the http request
+		URL string `json:"url,omitempty"`
+		// StatusCode is the status code of the http response
+		StatusCode int `json:"status_code,omitempty"`
+		// Proto is the protocol version of the http request or response. e.g. HTTP/1.1
+		Proto string `json:"proto,omitempty"`
+		// Headers are the headers of the http request or response
+		Headers map[string][]string `json:"headers,omitempty"`
+		// Header is the header of the message request or response as it is recorded
+		Header string `json:"header,omitempty"`
+		// Body is the body. The JSON body is indented.
+		Body string `json:"body,omitempty"`
+		// BodyEncoding is "base64" if the body is not valid UTF-8. Otherwise, it is empty.
+		BodyEncoding string `json:"body_encoding,omitempty"`
+	}
+)
+
+// Format formats the events received by the recorder. It panics if the report can not be generated.
+func (j *JSONReportFormatter) Format(recorder *Recorder) {
+	if err := j.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError formats the events received by the recorder.
+// It returns the error if the report can not be generated.
+func (j *JSONReportFormatter) FormatWithError(recorder *Recorder) error {
+	report, err := NewJSONReport(recorder)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := j.fs.mkdirAll(j.storagePath, os.ModePerm); err != nil {
+		return err
+	}
+	fileName := recorder.Meta.reportFileName() + jsonReportSuffix
+	saveFilesTo := filepath.Join(j.storagePath, fileName)
+	f, err := j.fs.create(saveFilesTo)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	s, _ := filepath.Abs(saveFilesTo)
+	fmt.Printf("Created JSON report (%s): %s\n", fileName, filepath.FromSlash(s))
+
+	if j.metaJSON {
+		return writeMetaJSON(j.fs, j.storagePath, recorder.Meta)
+	}
+	return nil
+}
+
+// NewJSONReport converts the recorder to the JSON report.
+func NewJSONReport(recorder *Recorder) (*JSONReport, error) {
+	report := &JSONReport{
+		Version:  JSONReportVersion,
+		Title:    recorder.Title,
+		SubTitle: recorder.SubTitle,
+		Events:   []JSONReportEvent{},
+	}
+	if recorder.Meta != nil {
+		report.Meta = *recorder.Meta
+	}
+
+	for _, event := range recorder.Events {
+		switch e := event.(type) {
+		case HTTPRequest:
+			if e.Value == nil {
+				continue
+			}
+			body, err := readAndRestoreBody(&e.Value.Body)
+			if err != nil {
+				return nil, err
+			}
+			entry := JSONReportEvent{
+				Type:      JSONReportEventHTTPRequest,
+				Source:    e.Source,
+				Target:    e.Target,
+				Timestamp: e.Timestamp,
+				Method:    e.Value.Method,
+				URL:       absoluteURL(e.Value),
+				Proto:     harHTTPVersion(e.Value.Proto),
+				Headers:   copyValues(e.Value.Header),
+			}
+			entry.Body, entry.BodyEncoding = jsonReportBody(body)
+			report.Events = append(report.Events, entry)
+		case HTTPResponse:
+			if e.Value == nil {
+				continue
+			}
+			body, err := readAndRestoreBody(&e.Value.Body)
+			if err != nil {
+				return nil, err
+			}
+			entry := JSONReportEvent{
+				Type:       JSONReportEventHTTPResponse,
+				Source:     e.Source,
+				Target:     e.Target,
+				Timestamp:  e.Timestamp,
+				StatusCode: e.Value.StatusCode,
+				Proto:      harHTTPVersion(e.Value.Proto),
+				Headers:    copyValues(e.Value.Header),
+			}
+			entry.Body, entry.BodyEncoding = jsonReportBody(body)
+			report.Events = append(report.Events, entry)
+		case MessageRequest:
+			report.Events = append(report.Events, JSONReportEvent{
+				Type:      JSONReportEventMessageRequest,
+				Source:    e.Source,
+				Target:    e.Target,
+				Timestamp: e.Timestamp,
+				Header:    e.Header,
+				Body:      e.Body,
+			})
+		case MessageResponse:
+			report.Events = append(report.Events, JSONReportEvent{
+				Type:      JSONReportEventMessageResponse,
+				Source:    e.Source,
+				Target:    e.Target,
+				Timestamp: e.Timestamp,
+				Header:    e.Header,
+				Body:      e.Body,
+			})
+		default:
+			return nil, fmt.Errorf("received unknown event type: %T", event)
+		}
+	}
+	for _, a := range recorder.AssertionResults {
+		report.Assertions = append(report.Assertions, JSONReportAssertion{
+			Name:      a.Name,
+			Passed:    a.Passed,
+			Expected:  a.Expected,
+			Actual:    a.Actual,
+			Message:   a.Message,
+			Diff:      a.Diff(),
+			Timestamp: a.Timestamp,
+		})
+	}
+	return report, nil
+}
+
+// jsonReportBody returns the body and its encoding. The JSON body is indented.
+func jsonReportBody(body []byte) (string, string) {
+	if json.Valid(body) {
+		buf := new(bytes.Buffer)
+		if err := json.Indent(buf, body, "", "  "); err == nil {
+			return buf.String(), ""
+		}
+	}
+	return encodeCassetteBody(body)
+}
+
+// ReadJSONReport reads the JSON report saved by ReportKindJSON.
+// It returns an error if the version of the report is not supported.
+func ReadJSONReport(path string) (*JSONReport, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	report := &JSONReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON report %s: %w", path, err)
+	}
+	if report.Version != JSONReportVersion {
+		return nil, fmt.Errorf("unsupported JSON report version %q in %s", report.Version, path)
+	}
+	return report, nil
+}
+
+// DecodedBody returns the body of the event. The base64 body is decoded.
+func (e JSONReportEvent) DecodedBody() ([]byte, error) {
+	return decodeCassetteBody(e.Body, e.BodyEncoding)
+}
@@ -0,0 +1,147 @@
+package spectest_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nao1215/spectest"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestJSONReportFormatter(t *testing.T) {
+	dir := t.TempDir()
+	getUser := spectest.NewMock().
+		Get("http://localhost:8080/users/1").
+		RespondWith().
+		Status(http.StatusOK).
+		Body(`{"name": "Tom"}`).
+		End()
+
+	spectest.New("get user").
+		Report(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindJSON})).
+		CustomReportName("get_user").
+		Mocks(getUser).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := http.Get("http://localhost:8080/users/1")
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			defer res.Body.Close() //nolint
+			body, _ := io.ReadAll(res.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}).
+		Get("/user").
+		Query("id", "1").
+		Header("X-Request-Id", "abc").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	path := filepath.Join(dir, "get_user.report.json")
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(spectest.JSONReportSchema), gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid() {
+		t.Errorf("the JSON report does not match the schema: %v\n%s", result.Errors(), data)
+	}
+
+	report, err := spectest.ReadJSONReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectest.DefaultVerifier{}.Equal(t, spectest.JSONReportVersion, report.Version)
+	spectest.DefaultVerifier{}.Equal(t, "GET /user?id=1", report.Title)
+	spectest.DefaultVerifier{}.Equal(t, "get user", report.SubTitle)
+	spectest.DefaultVerifier{}.Equal(t, "get_user", report.Meta.ReportFileName)
+	spectest.DefaultVerifier{}.Equal(t, http.MethodGet, report.Meta.Method)
+	spectest.DefaultVerifier{}.Equal(t, http.StatusOK, report.Meta.StatusCode)
+	spectest.DefaultVerifier{}.Equal(t, true, *report.Meta.Passed)
+	spectest.DefaultVerifier{}.Equal(t, 4, len(report.Events))
+	spectest.DefaultVerifier{}.Equal(t, 1, len(report.Assertions))
+	spectest.DefaultVerifier{}.Equal(t, "status code", report.Assertions[0].Name)
+	spectest.DefaultVerifier{}.Equal(t, true, report.Assertions[0].Passed)
+	spectest.DefaultVerifier{}.Equal(t, "200", report.Assertions[0].Actual)
+
+	inbound := report.Events[0]
+	spectest.DefaultVerifier{}.Equal(t, spectest.JSONReportEventHTTPRequest, inbound.Type)
+	spectest.DefaultVerifier{}.Equal(t, spectest.ConsumerDefaultName, inbound.Source)
+	spectest.DefaultVerifier{}.Equal(t, spectest.SystemUnderTestDefaultName, inbound.Target)
+	spectest.DefaultVerifier{}.Equal(t, http.MethodGet, inbound.Method)
+	spectest.DefaultVerifier{}.Equal(t, "http://server/user?id=1", inbound.URL)
+	spectest.DefaultVerifier{}.Equal(t, []string{"abc"}, inbound.Headers["X-Request-Id"])
+
+	mockRequest := report.Events[1]
+	spectest.DefaultVerifier{}.Equal(t, spectest.JSONReportEventHTTPRequest, mockRequest.Type)
+	spectest.DefaultVerifier{}.Equal(t, "http://localhost:8080/users/1", mockRequest.URL)
+	spectest.DefaultVerifier{}.Equal(t, "localhost:8080", mockRequest.Target)
+
+	final := report.Events[3]
+	spectest.DefaultVerifier{}.Equal(t, spectest.JSONReportEventHTTPResponse, final.Type)
+	spectest.DefaultVerifier{}.Equal(t, http.StatusOK, final.StatusCode)
+	spectest.DefaultVerifier{}.Equal(t, "{\n  \"name\": \"Tom\"\n}", final.Body)
+	spectest.DefaultVerifier{}.Equal(t, []string{"application/json"}, final.Headers["Content-Type"])
+	for i := 1; i < len(report.Events); i++ {
+		if report.Events[i].Timestamp.Before(report.Events[i-1].Timestamp) {
+			t.Errorf("the events are not in order of time: %+v", report.Events)
+		}
+	}
+}
+
+func TestNewJSONReport(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	recorder := spectest.NewTestRecorder().
+		AddTitle("publish").
+		AddMessageRequest(spectest.MessageRequest{Source: "app", Target: "queue", Header: "PUBLISH orders", Body: "order 1", Timestamp: now}).
+		AddMessageResponse(spectest.MessageResponse{Source: "queue", Target: "app", Header: "ACK", Timestamp: now.Add(time.Millisecond)}).
+		AddHTTPResponse(spectest.HTTPResponse{
+			Source:    "server",
+			Target:    "client",
+			Value:     &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("\xff\xfe"))},
+			Timestamp: now.Add(2 * time.Millisecond),
+		}).
+		AddAssertionResult(spectest.AssertionResult{Name: "status code", Passed: false, Expected: "201", Actual: "200", Message: "Status code 200 not equal to 201", Timestamp: now.Add(2 * time.Millisecond)})
+
+	report, err := spectest.NewJSONReport(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectest.DefaultVerifier{}.Equal(t, []spectest.JSONReportEvent{
+		{Type: spectest.JSONReportEventMessageRequest, Source: "app", Target: "queue", Timestamp: now, Header: "PUBLISH orders", Body: "order 1"},
+		{Type: spectest.JSONReportEventMessageResponse, Source: "queue", Target: "app", Timestamp: now.Add(time.Millisecond), Header: "ACK"},
+		{Type: spectest.JSONReportEventHTTPResponse, Source: "server", Target: "client", Timestamp: now.Add(2 * time.Millisecond),
+			StatusCode: http.StatusOK, Proto: "HTTP/1.1", Headers: map[string][]string{}, Body: "//4=", BodyEncoding: "base64"},
+	}, report.Events)
+
+	spectest.DefaultVerifier{}.Equal(t, []spectest.JSONReportAssertion{
+		{Name: "status code", Passed: false, Expected: "201", Actual: "200", Message: "Status code 200 not equal to 201",
+			Diff: "--- Expected\n+++ Actual\n@@ -1 +1 @@\n-201\n+200\n", Timestamp: now.Add(2 * time.Millisecond)},
+	}, report.Assertions)
+
+	body, err := report.Events[2].DecodedBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spectest.DefaultVerifier{}.Equal(t, []byte("\xff\xfe"), body)
+}
+
+func TestReadJSONReportUnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.report.json")
+	if err := os.WriteFile(path, []byte(`{"version": "0", "title": "", "meta": {}, "events": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spectest.ReadJSONReport(path); err == nil {
+		t.Error("expected an error for the unsupported version")
+	}
+}
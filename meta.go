@@ -1,8 +1,14 @@
 package spectest
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +20,9 @@ const SystemUnderTestDefaultName = "server"
 
 // Meta represents the meta data for the report.
 type Meta struct {
+	// Attempts represents the number of requests sent by Response.Eventually.
+	// It is zero if the request was sent only once.
+	Attempts int `json:"attempts,omitempty"`
 	// ConsumerName represents the name of the consumer.
 	ConsumerName string `json:"consumer_name,omitempty"`
 	// Duration represents the duration of the report.
@@ -25,12 +34,18 @@ type Meta struct {
 	Method string `json:"method,omitempty"`
 	// Name represents the title of the report.
 	Name string `json:"name,omitempty"`
+	// Passed represents whether every assertion of the report passed.
+	// It is nil if the report does not have the result, e.g. the report was generated by an older version.
+	Passed *bool `json:"passed,omitempty"`
 	// Path represents http request url of the report. e.g. /api/v1/users
 	Path string `json:"path,omitempty"`
 	// ReportFileName represents the name of the report file.
 	ReportFileName string `json:"report_file_name,omitempty"`
 	// StatusCode represents the final http status code of the report.
 	StatusCode int `json:"status_code,omitempty"`
+	// Steps represents the meta data of each step of a scenario report.
+	// Method, Path and StatusCode of a scenario report are empty, because the steps send different requests.
+	Steps []Meta `json:"steps,omitempty"`
 	// TestingTargetName represents the name of the system under test.
 	TestingTargetName string `json:"testing_target_name,omitempty"`
 }
@@ -68,3 +83,221 @@ func (m *Meta) reportFileName() string {
 	}
 	return m.hash()
 }
+
+// metaJSONSuffix is the suffix of the file that holds the meta data of a report.
+const metaJSONSuffix = ".meta.json"
+
+// writeMetaJSON saves the meta data to "<report file name>.meta.json" in the storage path.
+func writeMetaJSON(fsys fileSystem, storagePath string, meta *Meta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := fsys.create(filepath.Clean(filepath.Join(storagePath, meta.reportFileName()+metaJSONSuffix)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// htmlMetaJSONStart and htmlMetaJSONEnd enclose the meta data embedded in the HTML report.
+const (
+	htmlMetaJSONStart = `<script type="application/json" id="metaJson">`
+	htmlMetaJSONEnd   = `</script>`
+)
+
+// ReadMetas reads the meta data of every report in the directory and its subdirectories.
+// It reads the meta data files saved with ReportFormatterConfig.MetaJSON and the meta data
+// embedded in the HTML reports and the JSON reports. If a report has both, the meta data file is used.
+// The meta data are sorted by the report file name.
+func ReadMetas(dir string) ([]Meta, error) {
+	found := map[string]Meta{}
+	fromFile := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		var data []byte
+		key := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(path, metaJSONSuffix), ".html"), jsonReportSuffix)
+		switch {
+		case strings.HasSuffix(path, metaJSONSuffix):
+			if data, err = os.ReadFile(filepath.Clean(path)); err != nil {
+				return err
+			}
+		case strings.HasSuffix(path, jsonReportSuffix):
+			if fromFile[key] {
+				return nil
+			}
+			report, err := os.ReadFile(filepath.Clean(path))
+			if err != nil {
+				return err
+			}
+			if data = extractJSONReportMeta(report); data == nil {
+				return nil
+			}
+		case strings.HasSuffix(path, ".html"):
+			if fromFile[key] {
+				return nil
+			}
+			html, err := os.ReadFile(filepath.Clean(path))
+			if err != nil {
+				return err
+			}
+			if data = extractHTMLMetaJSON(html); data == nil {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		meta := Meta{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("failed to read meta data from %s: %w", path, err)
+		}
+		if meta.ReportFileName == "" {
+			meta.ReportFileName = filepath.Base(key)
+		}
+		found[key] = meta
+		fromFile[key] = fromFile[key] || strings.HasSuffix(path, metaJSONSuffix)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]Meta, 0, len(found))
+	for _, meta := range found {
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].ReportFileName != metas[j].ReportFileName {
+			return metas[i].ReportFileName < metas[j].ReportFileName
+		}
+		return metas[i].Method+metas[i].Path < metas[j].Method+metas[j].Path
+	})
+	return metas, nil
+}
+
+// extractHTMLMetaJSON returns the meta data embedded in the HTML report.
+// It returns nil if the HTML does not have the meta data.
+func extractHTMLMetaJSON(html []byte) []byte {
+	s := string(html)
+	start := strings.Index(s, htmlMetaJSONStart)
+	if start < 0 {
+		return nil
+	}
+	s = s[start+len(htmlMetaJSONStart):]
+	end := strings.Index(s, htmlMetaJSONEnd)
+	if end < 0 {
+		return nil
+	}
+	return []byte(s[:end])
+}
+
+// extractJSONReportMeta returns the meta data of the JSON report.
+// It returns nil if the JSON report does not have the meta data.
+func extractJSONReportMeta(report []byte) []byte {
+	r := struct {
+		Meta json.RawMessage `json:"meta"`
+	}{}
+	if err := json.Unmarshal(report, &r); err != nil || len(r.Meta) == 0 {
+		return nil
+	}
+	return r.Meta
+}
+
+// ReportFile is a report file and its meta data.
+type ReportFile struct {
+	// Path is the path of the report file.
+	Path string
+	// Kind is the kind of the report. It is decided by the file extension.
+	Kind ReportKind
+	// Meta is the meta data of the report. Only ReportFileName is set if the report has no meta data.
+	Meta Meta
+}
+
+// reportKinds is the kind of the report for each file extension.
+var reportKinds = map[string]ReportKind{
+	".html":          ReportKindHTML,
+	".md":            ReportKindMarkdown,
+	".har":           ReportKindHAR,
+	jsonReportSuffix: ReportKindJSON,
+}
+
+// reportFileKind returns the kind of the report file and the path without the extension.
+// It returns false if the file is not a report.
+func reportFileKind(path string) (ReportKind, string, bool) {
+	ext := filepath.Ext(path)
+	if strings.HasSuffix(path, jsonReportSuffix) {
+		ext = jsonReportSuffix
+	}
+	kind, ok := reportKinds[ext]
+	return kind, strings.TrimSuffix(path, ext), ok
+}
+
+// ReadReportFiles reads every report file (HTML, Markdown, HAR and JSON) in the directory and its subdirectories
+// with its meta data. The meta data are read from "<report file name>.meta.json" next to the report, or from
+// the HTML report and the JSON report. Markdown files without the meta data, e.g. an index, are read too.
+// The report files are sorted by the path.
+func ReadReportFiles(dir string) ([]ReportFile, error) {
+	reports := []ReportFile{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		kind, key, ok := reportFileKind(path)
+		if !ok {
+			return nil
+		}
+
+		meta := Meta{}
+		data, err := os.ReadFile(filepath.Clean(key + metaJSONSuffix))
+		switch {
+		case err == nil:
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		case kind == ReportKindHTML:
+			html, err := os.ReadFile(filepath.Clean(path))
+			if err != nil {
+				return err
+			}
+			data = extractHTMLMetaJSON(html)
+		case kind == ReportKindJSON:
+			report, err := os.ReadFile(filepath.Clean(path))
+			if err != nil {
+				return err
+			}
+			data = extractJSONReportMeta(report)
+		default:
+			data = nil
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return fmt.Errorf("failed to read meta data of %s: %w", path, err)
+			}
+		}
+		if meta.ReportFileName == "" {
+			meta.ReportFileName = filepath.Base(key)
+		}
+
+		reports = append(reports, ReportFile{Path: path, Kind: kind, Meta: meta})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Path < reports[j].Path
+	})
+	return reports, nil
+}
@@ -2,6 +2,8 @@ package spectest
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -60,3 +62,140 @@ func TestMetaReportFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestReadMetas(t *testing.T) {
+	dir := t.TempDir()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Kind: ReportKindMarkdown, MetaJSON: true})).
+		CustomReportName("create_user").
+		Post("/users").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: filepath.Join(dir, "html"), MetaJSON: true})).
+		CustomReportName("create_item").
+		Post("/items").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: filepath.Join(dir, "html")})).
+		CustomReportName("create_order").
+		Post("/orders").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Kind: ReportKindJSON, MetaJSON: true})).
+		CustomReportName("create_tag").
+		Post("/tags").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Kind: ReportKindJSON})).
+		CustomReportName("create_note").
+		Post("/notes").
+		Expect(t).
+		Status(http.StatusCreated).
+		End()
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), []byte("# index"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	metas, err := ReadMetas(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 5 {
+		t.Fatalf("expected 5 meta data, got %d: %+v", len(metas), metas)
+	}
+	for i, want := range []struct {
+		reportFileName string
+		path           string
+	}{
+		{reportFileName: "create_item", path: "/items"},
+		{reportFileName: "create_note", path: "/notes"},
+		{reportFileName: "create_order", path: "/orders"},
+		{reportFileName: "create_tag", path: "/tags"},
+		{reportFileName: "create_user", path: "/users"},
+	} {
+		if metas[i].ReportFileName != want.reportFileName || metas[i].Path != want.path {
+			t.Errorf("unexpected meta data: %+v", metas[i])
+		}
+		if metas[i].Method != http.MethodPost || metas[i].StatusCode != http.StatusCreated {
+			t.Errorf("unexpected meta data: %+v", metas[i])
+		}
+	}
+}
+
+func TestReadReportFiles(t *testing.T) {
+	dir := t.TempDir()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Kind: ReportKindMarkdown, MetaJSON: true})).
+		CustomReportName("get_user").
+		Get("/users/1").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: filepath.Join(dir, "html")})).
+		CustomReportName("get_item").
+		Get("/items/1").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	New().
+		HandlerFunc(handler).
+		Report(SequenceReport(ReportFormatterConfig{Path: dir, Kind: ReportKindJSON})).
+		CustomReportName("delete_user").
+		Delete("/users/1").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), []byte("# index"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := ReadReportFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path           string
+		kind           ReportKind
+		reportFileName string
+		method         string
+	}{
+		{path: "delete_user.report.json", kind: ReportKindJSON, reportFileName: "delete_user", method: http.MethodDelete},
+		{path: "get_user.md", kind: ReportKindMarkdown, reportFileName: "get_user", method: http.MethodGet},
+		{path: filepath.Join("html", "get_item.html"), kind: ReportKindHTML, reportFileName: "get_item", method: http.MethodGet},
+		{path: "index.md", kind: ReportKindMarkdown, reportFileName: "index"},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("expected %d report files, got %d: %+v", len(want), len(reports), reports)
+	}
+	for i, w := range want {
+		got := reports[i]
+		if got.Path != filepath.Join(dir, w.path) || got.Kind != w.kind ||
+			got.Meta.ReportFileName != w.reportFileName || got.Meta.Method != w.method {
+			t.Errorf("unexpected report file: %+v", got)
+		}
+	}
+}
@@ -37,6 +37,11 @@ type Transport struct {
 	nativeTransport http.RoundTripper
 	// specTest is the spectest instance
 	specTest *SpecTest
+	// isolated routes requests to the mocks through the request context instead of
+	// replacing http.DefaultTransport. See SpecTest.IsolateMocks.
+	isolated bool
+	// cassette records the requests that do not match any mock. See SpecTest.Cassette.
+	cassette *Cassette
 }
 
 // newTransport creates a new transport
@@ -60,8 +65,6 @@ func newTransport(
 
 	if httpClient != nil {
 		t.nativeTransport = httpClient.Transport
-	} else {
-		t.nativeTransport = http.DefaultTransport
 	}
 	return t
 }
@@ -83,6 +86,9 @@ func (r *Transport) RoundTrip(req *http.Request) (mockResponse *http.Response, e
 
 	matchedResponse, err := matches(req, r.mocks)
 	if err != nil {
+		if r.cassette != nil && r.cassette.isRecording() {
+			return r.passThrough(req)
+		}
 		if r.debug.isEnable() {
 			fmt.Printf("failed to match mocks. Errors: %s\n", err)
 		}
@@ -107,6 +113,11 @@ func (r *Transport) Hijack() {
 		r.httpClient.Transport = r
 		return
 	}
+	if r.isolated {
+		r.nativeTransport = acquireDispatcher()
+		return
+	}
+	r.nativeTransport = http.DefaultTransport
 	http.DefaultTransport = r
 }
 
@@ -116,6 +127,10 @@ func (r *Transport) Reset() {
 		r.httpClient.Transport = r.nativeTransport
 		return
 	}
+	if r.isolated {
+		releaseDispatcher()
+		return
+	}
 	http.DefaultTransport = r.nativeTransport
 }
 
@@ -301,6 +316,8 @@ type StandaloneMocks struct {
 	mocks      Mocks
 	httpClient *http.Client
 	debug      *debug
+	cassette   *Cassette
+	t          TestingT
 }
 
 // NewStandaloneMocks create a series of StandaloneMocks
@@ -323,17 +340,43 @@ func (r *StandaloneMocks) Debug() *StandaloneMocks {
 	return r
 }
 
+// Cassette records the requests that do not match any mock to the cassette file,
+// or replays the recorded requests as mocks. The cassette file is saved when the returned reset function is called.
+// The errors of loading and saving the cassette file are reported to t.
+func (r *StandaloneMocks) Cassette(t TestingT, cassette *Cassette) *StandaloneMocks {
+	r.t = t
+	r.cassette = cassette
+	return r
+}
+
 // End finalizes the mock, ready for use
 func (r *StandaloneMocks) End() func() {
+	mocks := r.mocks
+	if r.cassette != nil {
+		replayed, err := r.cassette.begin()
+		if err != nil {
+			r.t.Fatal(err)
+			return func() {}
+		}
+		mocks = append(append(Mocks{}, r.mocks...), replayed...)
+	}
 	transport := newTransport(
-		r.mocks,
+		mocks,
 		r.httpClient,
 		r.debug,
 		false,
 		nil,
 		nil,
 	)
-	resetFunc := func() { transport.Reset() }
+	transport.cassette = r.cassette
+	resetFunc := func() {
+		transport.Reset()
+		if r.cassette != nil {
+			if err := r.cassette.save(); err != nil {
+				r.t.Errorf("failed to save the cassette: %v", err)
+			}
+		}
+	}
 	transport.Hijack()
 	return resetFunc
 }
@@ -487,6 +530,25 @@ func (m *Mock) parseURL(u string) {
 	m.request.url = parsed
 }
 
+// exactURL configures the mock request to match only the scheme, host, path and query parameters of u.
+// It is used by the mocks that replay recorded traffic. The redacted query parameters match any value.
+func (r *MockRequest) exactURL(u *url.URL) {
+	r.url = &url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "^" + regexp.QuoteMeta(u.Path) + "$",
+	}
+	for key, values := range u.Query() {
+		for _, value := range values {
+			if value == redactedValue {
+				r.QueryPresent(key)
+				continue
+			}
+			r.Query(key, "^"+regexp.QuoteMeta(value)+"$")
+		}
+	}
+}
+
 // matches checks whether the given request matches any of the given mocks
 func matches(req *http.Request, mocks Mocks) (*MockResponse, error) {
 	mockError := newUnmatchedMockError()
@@ -0,0 +1,217 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	md "github.com/nao1215/markdown"
+	"github.com/nao1215/spectest"
+)
+
+// CoverageItem is a pair of an operation and a documented response status code.
+type CoverageItem struct {
+	// OperationID is the operationId of the operation. If the operation has no operationId, it is "METHOD path".
+	OperationID string `json:"operation_id"`
+	// Method is the http method of the operation
+	Method string `json:"method"`
+	// Path is the path template of the operation
+	Path string `json:"path"`
+	// StatusCode is the documented response status code. e.g. 200, 4XX, default
+	StatusCode string `json:"status_code"`
+	// Reports is the list of report file names that exercise the pair
+	Reports []string `json:"reports,omitempty"`
+}
+
+// UndocumentedItem is a recorded request that is not documented in the OpenAPI document.
+type UndocumentedItem struct {
+	// Method is the http method of the request
+	Method string `json:"method"`
+	// Path is the path of the request
+	Path string `json:"path"`
+	// StatusCode is the http status code of the response
+	StatusCode int `json:"status_code"`
+	// Report is the report file name of the request
+	Report string `json:"report"`
+}
+
+// Coverage is the coverage of the operation and status code pairs of the OpenAPI document.
+type Coverage struct {
+	// Total is the number of operation and status code pairs
+	Total int `json:"total"`
+	// CoveredCount is the number of covered pairs
+	CoveredCount int `json:"covered_count"`
+	// Percentage is the percentage of covered pairs
+	Percentage float64 `json:"percentage"`
+	// Covered is the list of pairs exercised by at least one spec
+	Covered []CoverageItem `json:"covered"`
+	// Uncovered is the list of pairs that no spec exercises
+	Uncovered []CoverageItem `json:"uncovered"`
+	// Undocumented is the list of requests that match no operation or status code
+	Undocumented []UndocumentedItem `json:"undocumented"`
+}
+
+// expandSteps replaces the meta data of the scenario reports with the meta data of their steps.
+func expandSteps(metas []spectest.Meta) []spectest.Meta {
+	expanded := make([]spectest.Meta, 0, len(metas))
+	for _, meta := range metas {
+		if len(meta.Steps) == 0 {
+			expanded = append(expanded, meta)
+			continue
+		}
+		for _, step := range meta.Steps {
+			step.ReportFileName = meta.ReportFileName
+			expanded = append(expanded, step)
+		}
+	}
+	return expanded
+}
+
+// requestPath returns the path of the recorded request url without the scheme, the host,
+// the query and the fragment. The url is absolute when the spec is run with networking.
+func requestPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+			return rawURL[:i]
+		}
+		return rawURL
+	}
+	return u.Path
+}
+
+// Coverage compares the meta data of the reports with the document.
+// The meta data can be read from the report directory with spectest.ReadMetas.
+// Each step of a scenario report is compared on its own under the report file name of the scenario.
+func (d *Document) Coverage(metas []spectest.Meta) *Coverage {
+	reports := map[*Operation]map[string][]string{}
+	coverage := &Coverage{
+		Covered:      []CoverageItem{},
+		Uncovered:    []CoverageItem{},
+		Undocumented: []UndocumentedItem{},
+	}
+
+	for _, meta := range expandSteps(metas) {
+		path := requestPath(meta.Path)
+		undocumented := UndocumentedItem{
+			Method:     strings.ToUpper(meta.Method),
+			Path:       path,
+			StatusCode: meta.StatusCode,
+			Report:     meta.ReportFileName,
+		}
+
+		op, _, err := d.FindOperation(meta.Method, path)
+		if err != nil {
+			coverage.Undocumented = append(coverage.Undocumented, undocumented)
+			continue
+		}
+		responses, _ := op.operation["responses"].(map[string]interface{})
+		key, ok := responseKey(responses, meta.StatusCode)
+		if !ok {
+			coverage.Undocumented = append(coverage.Undocumented, undocumented)
+			continue
+		}
+		if reports[op] == nil {
+			reports[op] = map[string][]string{}
+		}
+		reports[op][key] = append(reports[op][key], meta.ReportFileName)
+	}
+
+	for _, op := range d.Operations() {
+		for _, code := range op.StatusCodes {
+			item := CoverageItem{
+				OperationID: op.ID,
+				Method:      op.Method,
+				Path:        op.Path,
+				StatusCode:  code,
+				Reports:     reports[op][code],
+			}
+			coverage.Total++
+			if len(item.Reports) == 0 {
+				coverage.Uncovered = append(coverage.Uncovered, item)
+				continue
+			}
+			coverage.CoveredCount++
+			coverage.Covered = append(coverage.Covered, item)
+		}
+	}
+	if coverage.Total > 0 {
+		coverage.Percentage = float64(coverage.CoveredCount) * 100 / float64(coverage.Total)
+	}
+
+	sort.SliceStable(coverage.Undocumented, func(i, j int) bool {
+		a, b := coverage.Undocumented[i], coverage.Undocumented[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.StatusCode < b.StatusCode
+	})
+	return coverage
+}
+
+// JSON returns the coverage in JSON format.
+func (c *Coverage) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// Markdown returns the coverage in Markdown format.
+func (c *Coverage) Markdown() (string, error) {
+	var buf bytes.Buffer
+	markdown := md.NewMarkdown(&buf).
+		H1("OpenAPI coverage").LF().
+		PlainTextf("%d of %d operation and status code pairs are covered (%.1f%%).", c.CoveredCount, c.Total, c.Percentage).LF()
+
+	markdown = markdown.H2("Uncovered").LF()
+	if len(c.Uncovered) == 0 {
+		markdown = markdown.PlainText("All operation and status code pairs are covered.").LF()
+	} else {
+		markdown = markdown.CustomTable(coverageTable(c.Uncovered, false), tableOptions).LF()
+	}
+
+	markdown = markdown.H2("Covered").LF()
+	if len(c.Covered) == 0 {
+		markdown = markdown.PlainText("No operation and status code pair is covered.").LF()
+	} else {
+		markdown = markdown.CustomTable(coverageTable(c.Covered, true), tableOptions).LF()
+	}
+
+	if len(c.Undocumented) > 0 {
+		rows := make([][]string, 0, len(c.Undocumented))
+		for _, u := range c.Undocumented {
+			rows = append(rows, []string{u.Method, u.Path, strconv.Itoa(u.StatusCode), u.Report})
+		}
+		markdown = markdown.H2("Undocumented").LF().
+			CustomTable(md.TableSet{Header: []string{"Method", "Path", "Status", "Report"}, Rows: rows}, tableOptions).LF()
+	}
+
+	if err := markdown.Build(); err != nil {
+		return "", fmt.Errorf("failed to generate coverage markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// tableOptions keeps the report file names on one line.
+var tableOptions = md.TableOptions{AutoWrapText: false, AutoFormatHeaders: false}
+
+// coverageTable returns the table of the coverage items.
+func coverageTable(items []CoverageItem, withReports bool) md.TableSet {
+	table := md.TableSet{Header: []string{"Operation", "Method", "Path", "Status"}}
+	if withReports {
+		table.Header = append(table.Header, "Reports")
+	}
+	for _, item := range items {
+		row := []string{item.OperationID, item.Method, item.Path, item.StatusCode}
+		if withReports {
+			row = append(row, strings.Join(item.Reports, ", "))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
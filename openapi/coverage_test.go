@@ -0,0 +1,102 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/openapi"
+)
+
+func TestDocumentCoverage(t *testing.T) {
+	doc := loadPetstore(t)
+
+	coverage := doc.Coverage([]spectest.Meta{
+		{Method: http.MethodGet, Path: "/v1/pets?limit=10", StatusCode: http.StatusOK, ReportFileName: "list_pets"},
+		{Method: http.MethodGet, Path: "/v1/pets", StatusCode: http.StatusOK, ReportFileName: "list_pets_without_limit"},
+		{Method: http.MethodGet, Path: "/v1/pets/1", StatusCode: http.StatusNotFound, ReportFileName: "pet_not_found"},
+		{Method: http.MethodPost, Path: "/v1/pets", StatusCode: http.StatusBadRequest, ReportFileName: "create_pet_bad_request"},
+		{Method: http.MethodGet, Path: "/v1/pets/1", StatusCode: http.StatusInternalServerError, ReportFileName: "pet_error"},
+		{Method: http.MethodDelete, Path: "/v1/pets/1", StatusCode: http.StatusNoContent, ReportFileName: "delete_pet"},
+	})
+
+	spectest.DefaultVerifier{}.Equal(t, 6, coverage.Total)
+	spectest.DefaultVerifier{}.Equal(t, 3, coverage.CoveredCount)
+	spectest.DefaultVerifier{}.Equal(t, 50.0, coverage.Percentage)
+	spectest.DefaultVerifier{}.Equal(t, []openapi.CoverageItem{
+		{OperationID: "listPets", Method: http.MethodGet, Path: "/pets", StatusCode: "200", Reports: []string{"list_pets", "list_pets_without_limit"}},
+		{OperationID: "createPet", Method: http.MethodPost, Path: "/pets", StatusCode: "default", Reports: []string{"create_pet_bad_request"}},
+		{OperationID: "showPetById", Method: http.MethodGet, Path: "/pets/{petId}", StatusCode: "4XX", Reports: []string{"pet_not_found"}},
+	}, coverage.Covered)
+	spectest.DefaultVerifier{}.Equal(t, []openapi.CoverageItem{
+		{OperationID: "createPet", Method: http.MethodPost, Path: "/pets", StatusCode: "201"},
+		{OperationID: "GET /pets/mine", Method: http.MethodGet, Path: "/pets/mine", StatusCode: "200"},
+		{OperationID: "showPetById", Method: http.MethodGet, Path: "/pets/{petId}", StatusCode: "200"},
+	}, coverage.Uncovered)
+	spectest.DefaultVerifier{}.Equal(t, []openapi.UndocumentedItem{
+		{Method: http.MethodDelete, Path: "/v1/pets/1", StatusCode: http.StatusNoContent, Report: "delete_pet"},
+		{Method: http.MethodGet, Path: "/v1/pets/1", StatusCode: http.StatusInternalServerError, Report: "pet_error"},
+	}, coverage.Undocumented)
+
+	t.Run("markdown", func(t *testing.T) {
+		markdown, err := coverage.Markdown()
+		if err != nil {
+			t.Fatal(err)
+		}
+		markdown = strings.Join(strings.Fields(markdown), " ")
+		for _, want := range []string{
+			"3 of 6 operation and status code pairs are covered (50.0%).",
+			"| createPet | POST | /pets | 201 |",
+			"| listPets | GET | /pets | 200 | list_pets, list_pets_without_limit |",
+			"| DELETE | /v1/pets/1 | 204 | delete_pet |",
+		} {
+			if !strings.Contains(markdown, want) {
+				t.Errorf("markdown does not contain %q:\n%s", want, markdown)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		data, err := coverage.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &openapi.Coverage{}
+		if err := json.Unmarshal(data, got); err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, coverage, got)
+	})
+}
+
+func TestDocumentCoverageAbsoluteURL(t *testing.T) {
+	doc := loadPetstore(t)
+
+	// The path of the meta data is an absolute url when the spec is run with networking.
+	coverage := doc.Coverage([]spectest.Meta{
+		{Method: http.MethodGet, Path: "http://localhost:8080/v1/pets?limit=10", StatusCode: http.StatusOK, ReportFileName: "list_pets"},
+		{Method: http.MethodGet, Path: "https://example.com/v1/pets/1#top", StatusCode: http.StatusNotFound, ReportFileName: "pet_not_found"},
+	})
+
+	spectest.DefaultVerifier{}.Equal(t, 2, coverage.CoveredCount)
+	spectest.DefaultVerifier{}.Equal(t, []openapi.UndocumentedItem{}, coverage.Undocumented)
+}
+
+func TestDocumentCoverageScenarioSteps(t *testing.T) {
+	doc := loadPetstore(t)
+
+	coverage := doc.Coverage([]spectest.Meta{
+		{Name: "pet flow", ReportFileName: "pet_flow", Steps: []spectest.Meta{
+			{Method: http.MethodGet, Path: "/v1/pets", StatusCode: http.StatusOK},
+			{Method: http.MethodGet, Path: "/v1/pets/1", StatusCode: http.StatusNotFound},
+		}},
+	})
+
+	spectest.DefaultVerifier{}.Equal(t, []openapi.CoverageItem{
+		{OperationID: "listPets", Method: http.MethodGet, Path: "/pets", StatusCode: "200", Reports: []string{"pet_flow"}},
+		{OperationID: "showPetById", Method: http.MethodGet, Path: "/pets/{petId}", StatusCode: "4XX", Reports: []string{"pet_flow"}},
+	}, coverage.Covered)
+	spectest.DefaultVerifier{}.Equal(t, []openapi.UndocumentedItem{}, coverage.Undocumented)
+}
@@ -0,0 +1,702 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nao1215/spectest"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatterConfig is the configuration of the Formatter.
+type FormatterConfig struct {
+	// Path is the OpenAPI document to update. If the file does not exist, a new document is created.
+	// The document is written in YAML if the extension is .yaml or .yml, otherwise in JSON.
+	Path string
+	// Title is the title of a new document. The default is "API".
+	Title string
+	// Version is the version of a new document. The default is "1.0.0".
+	Version string
+	// PathTemplates is the list of path templates that are tried before the path template is inferred.
+	// e.g. /users/{name}
+	PathTemplates []string
+}
+
+// Formatter is a spectest.ReportFormatter that merges the recorded http traffic into an OpenAPI document.
+type Formatter struct {
+	// config is the configuration of the formatter
+	config FormatterConfig
+}
+
+// formatterMu serializes the updates of the document file by specs running in parallel.
+var formatterMu sync.Mutex
+
+const (
+	// lockRetryInterval is the interval of the retries to create the lock file.
+	lockRetryInterval = 10 * time.Millisecond
+	// lockStaleAfter is the age of the lock file that is regarded as left by a crashed process.
+	lockStaleAfter = 10 * time.Second
+)
+
+// NewFormatter creates a new Formatter. Every spec that uses the formatter adds its request and response
+// to the document. Hand-written descriptions, schemas and examples in the document are never overwritten.
+// The comments in the document are not preserved, because the document is rewritten from the parsed data.
+// The document is locked with the file "<Path>.lock" while it is updated, so the test binaries of
+// the packages run by 'go test ./...' can share the document.
+func NewFormatter(config FormatterConfig) spectest.ReportFormatter {
+	if config.Path == "" {
+		config.Path = "openapi.yaml"
+	}
+	if config.Title == "" {
+		config.Title = "API"
+	}
+	if config.Version == "" {
+		config.Version = "1.0.0"
+	}
+	return &Formatter{config: config}
+}
+
+// Format merges the events received by the recorder into the document.
+// It panics if the document can not be updated.
+func (f *Formatter) Format(recorder *spectest.Recorder) {
+	if err := f.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError merges the events received by the recorder into the document.
+// It returns the error if the document can not be updated.
+func (f *Formatter) FormatWithError(recorder *spectest.Recorder) error {
+	formatterMu.Lock()
+	defer formatterMu.Unlock()
+
+	unlock, err := lockFile(f.config.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc := NewDocument(f.config.Title, f.config.Version)
+	if _, err := os.Stat(f.config.Path); err == nil {
+		if doc, err = Load(f.config.Path); err != nil {
+			return err
+		}
+	}
+	if err := doc.Merge(recorder, f.config.PathTemplates...); err != nil {
+		return err
+	}
+	if err := doc.WriteFile(f.config.Path); err != nil {
+		return err
+	}
+	s, _ := filepath.Abs(f.config.Path)
+	fmt.Printf("Updated OpenAPI document: %s\n", filepath.FromSlash(s))
+	return nil
+}
+
+// lockFile creates the lock file of the path to exclude the other processes that update the same file.
+// It waits until the lock file is removed by the other process, or is older than lockStaleAfter.
+// The returned function removes the lock file.
+func lockFile(path string) (func(), error) {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0o750); err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.OpenFile(filepath.Clean(lock), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			if err := f.Close(); err != nil {
+				return nil, err
+			}
+			return func() { _ = os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			_ = os.Remove(lock)
+			continue
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// NewDocument creates an empty OpenAPI 3 document.
+func NewDocument(title, version string) *Document {
+	return &Document{
+		root: map[string]interface{}{
+			"openapi": "3.0.3",
+			"info": map[string]interface{}{
+				"title":   title,
+				"version": version,
+			},
+			"paths": map[string]interface{}{},
+		},
+		basePaths: []string{},
+	}
+}
+
+// Merge adds the requests from the consumer to the system under test and their responses to the document.
+// The path template is found from the document or the given path templates. If there is no path template
+// that matches, it is inferred from the path. e.g. /users/1 is /users/{userId}
+// Operations, parameters, status codes, schemas and examples that are not documented yet are added.
+func (d *Document) Merge(recorder *spectest.Recorder, pathTemplates ...string) error {
+	name := exampleName(recorder)
+	var req *http.Request
+	for _, event := range recorder.Events {
+		switch v := event.(type) {
+		case spectest.HTTPRequest:
+			if v.Source == spectest.ConsumerDefaultName {
+				req = v.Value
+			}
+		case spectest.HTTPResponse:
+			if v.Target == spectest.ConsumerDefaultName && req != nil {
+				if err := d.mergeExchange(name, recorder.SubTitle, req, v.Value, pathTemplates); err != nil {
+					return err
+				}
+				req = nil
+			}
+		}
+	}
+	return d.buildOperations()
+}
+
+// mergeExchange adds the request and the response to the document.
+func (d *Document) mergeExchange(name, summary string, req *http.Request, res *http.Response, pathTemplates []string) error {
+	template, params, err := d.pathTemplate(req.URL.Path, pathTemplates)
+	if err != nil {
+		return err
+	}
+
+	pathItem := d.child(d.child(d.root, "paths"), template)
+	method := strings.ToLower(req.Method)
+	if _, ok := pathItem[method]; !ok && summary != "" {
+		d.child(pathItem, method)["summary"] = summary
+	}
+	operation := d.child(pathItem, method)
+
+	for _, p := range params {
+		d.addParameter(pathItem, operation, "path", p.name, []string{p.value})
+	}
+	query := req.URL.Query()
+	queryNames := make([]string, 0, len(query))
+	for k := range query {
+		queryNames = append(queryNames, k)
+	}
+	sort.Strings(queryNames)
+	for _, k := range queryNames {
+		d.addParameter(pathItem, operation, "query", k, query[k])
+	}
+
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return err
+	}
+	if len(reqBody) > 0 {
+		requestBody := d.child(operation, "requestBody")
+		mergeContent(d.child(requestBody, "content"), req.Header.Get("Content-Type"), reqBody, name)
+	}
+
+	if res == nil {
+		return nil
+	}
+	responses := d.child(operation, "responses")
+	key, ok := responseKey(responses, res.StatusCode)
+	if !ok {
+		key = strconv.Itoa(res.StatusCode)
+		responses[key] = map[string]interface{}{"description": http.StatusText(res.StatusCode)}
+	}
+	response := d.child(responses, key)
+
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return err
+	}
+	if len(resBody) > 0 {
+		mergeContent(d.child(response, "content"), res.Header.Get("Content-Type"), resBody, name)
+	}
+	return nil
+}
+
+// pathParam is a path parameter and its value.
+type pathParam struct {
+	name  string
+	value string
+}
+
+// pathTemplate returns the path template of the request path and the path parameters in the order of the template.
+func (d *Document) pathTemplate(path string, pathTemplates []string) (string, []pathParam, error) {
+	path = d.candidatePaths(path)[0]
+
+	paths, _ := d.root["paths"].(map[string]interface{})
+	documented := make([]string, 0, len(paths))
+	for template := range paths {
+		documented = append(documented, template)
+	}
+	sort.Slice(documented, func(i, j int) bool {
+		ci, cj := strings.Count(documented[i], "{"), strings.Count(documented[j], "{")
+		if ci != cj {
+			return ci < cj
+		}
+		return documented[i] < documented[j]
+	})
+	candidates := append(append([]string{}, pathTemplates...), documented...)
+
+	for _, template := range candidates {
+		matcher, names, err := compilePathTemplate(template)
+		if err != nil {
+			return "", nil, err
+		}
+		op := &Operation{matcher: matcher, paramNames: names}
+		if values, ok := op.match(path); ok {
+			params := make([]pathParam, 0, len(names))
+			for _, name := range names {
+				params = append(params, pathParam{name: name, value: values[name]})
+			}
+			return template, params, nil
+		}
+	}
+
+	template, params := inferPathTemplate(path)
+	return template, params, nil
+}
+
+// idRegexp matches the path segments that look like an identifier. e.g. 123, a UUID, a long hex string
+var idRegexp = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// inferPathTemplate replaces the path segments that look like an identifier with a path parameter.
+// The parameter is named after the previous segment. e.g. /users/1/posts/2 is /users/{userId}/posts/{postId}
+func inferPathTemplate(path string) (string, []pathParam) {
+	segments := strings.Split(path, "/")
+	params := []pathParam{}
+	used := map[string]bool{}
+	for i, segment := range segments {
+		if !idRegexp.MatchString(segment) {
+			continue
+		}
+		name := "id"
+		if i > 0 && segments[i-1] != "" && !strings.HasPrefix(segments[i-1], "{") {
+			name = singular(lowerCamel(segments[i-1])) + "Id"
+		}
+		for n := 2; used[name]; n++ {
+			name = strings.TrimRight(name, "0123456789") + strconv.Itoa(n)
+		}
+		used[name] = true
+		params = append(params, pathParam{name: name, value: segment})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// lowerCamel converts the path segment to lower camel case. e.g. order-items is orderItems
+func lowerCamel(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w[:1]) + w[1:]
+			continue
+		}
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, "")
+}
+
+// singular returns the singular form of the plural noun in the simple cases. e.g. users is user, categories is category
+func singular(s string) string {
+	switch {
+	case strings.HasSuffix(s, "ies") && len(s) > 3:
+		return strings.TrimSuffix(s, "ies") + "y"
+	case strings.HasSuffix(s, "ss"):
+		return s
+	case strings.HasSuffix(s, "s") && len(s) > 1:
+		return strings.TrimSuffix(s, "s")
+	default:
+		return s
+	}
+}
+
+// addParameter adds the parameter to the operation if neither the path item nor the operation has it.
+func (d *Document) addParameter(pathItem, operation map[string]interface{}, in, name string, values []string) {
+	for _, owner := range []map[string]interface{}{pathItem, operation} {
+		list, _ := owner["parameters"].([]interface{})
+		for _, p := range list {
+			param, ok := d.resolve(p).(map[string]interface{})
+			if ok && param["in"] == in && param["name"] == name {
+				return
+			}
+		}
+	}
+
+	param := map[string]interface{}{
+		"name":   name,
+		"in":     in,
+		"schema": inferParameterSchema(values),
+	}
+	if in == "path" {
+		param["required"] = true
+	}
+	list, _ := operation["parameters"].([]interface{})
+	operation["parameters"] = append(list, param)
+}
+
+// mergeContent adds the media type, the schema and the example of the body to the content.
+// The schema is added only if the media type has no schema. The example is added to the examples
+// with the given name only if the media type has no single example.
+func mergeContent(content map[string]interface{}, contentType string, body []byte, name string) {
+	if contentType == "" {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for k := range content {
+		if strings.EqualFold(k, mediaType) {
+			mediaType = k
+		}
+	}
+	media, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		media = map[string]interface{}{}
+		content[mediaType] = media
+	}
+
+	var value interface{}
+	switch {
+	case isJSON(mediaType):
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return
+		}
+	case strings.HasPrefix(mediaType, "text/"):
+		value = string(body)
+	default:
+		if _, ok := media["schema"]; !ok {
+			media["schema"] = map[string]interface{}{"type": "string", "format": "binary"}
+		}
+		return
+	}
+
+	if _, ok := media["schema"]; !ok {
+		media["schema"] = inferSchema(value)
+	}
+	if _, ok := media["example"]; ok {
+		return
+	}
+	examples, ok := media["examples"].(map[string]interface{})
+	if !ok {
+		examples = map[string]interface{}{}
+		media["examples"] = examples
+	}
+	examples[name] = map[string]interface{}{"value": value}
+}
+
+// inferSchema returns the schema of the decoded JSON value.
+func inferSchema(v interface{}) map[string]interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		schema := map[string]interface{}{"type": "object"}
+		if len(x) > 0 {
+			properties := make(map[string]interface{}, len(x))
+			for k, value := range x {
+				properties[k] = inferSchema(value)
+			}
+			schema["properties"] = properties
+		}
+		return schema
+	case []interface{}:
+		items := map[string]interface{}{}
+		if len(x) > 0 {
+			items = inferSchema(x[0])
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	case string:
+		if _, err := time.Parse(time.RFC3339, x); err == nil {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		return map[string]interface{}{"type": "string"}
+	default:
+		return map[string]interface{}{"nullable": true}
+	}
+}
+
+// inferParameterSchema returns the schema of the parameter values. Multiple values are an array.
+func inferParameterSchema(values []string) map[string]interface{} {
+	types := map[string]bool{}
+	for _, v := range values {
+		switch {
+		case isInteger(v):
+			types["integer"] = true
+		case isNumber(v):
+			types["number"] = true
+		case v == "true" || v == "false":
+			types["boolean"] = true
+		default:
+			types["string"] = true
+		}
+	}
+
+	schema := map[string]interface{}{"type": "string"}
+	switch {
+	case len(types) == 1 && types["integer"]:
+		schema["type"] = "integer"
+	case len(types) == 1 && types["boolean"]:
+		schema["type"] = "boolean"
+	case (len(types) == 1 || len(types) == 2 && types["integer"]) && types["number"]:
+		schema["type"] = "number"
+	}
+	if len(values) > 1 {
+		return map[string]interface{}{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// isInteger returns true if the string is an integer.
+func isInteger(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// isNumber returns true if the string is a number.
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// exampleName returns the name of the examples added by the recorder.
+// It is the report file name if it is set, otherwise the name of the spec or the title.
+func exampleName(recorder *spectest.Recorder) string {
+	name := recorder.Title
+	if recorder.Meta != nil && recorder.Meta.ReportFileName != "" {
+		name = recorder.Meta.ReportFileName
+	} else if recorder.SubTitle != "" {
+		name = recorder.SubTitle
+	}
+	return name
+}
+
+// child returns the object of the key. If the parent has no object of the key, an empty object is added.
+// A reference to an object in the document is followed.
+func (d *Document) child(parent map[string]interface{}, key string) map[string]interface{} {
+	if m, ok := d.resolve(parent[key]).(map[string]interface{}); ok {
+		return m
+	}
+	m := map[string]interface{}{}
+	parent[key] = m
+	return m
+}
+
+// JSON returns the document in JSON format.
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(ordered(d.root), "", "  ")
+}
+
+// YAML returns the document in YAML format.
+func (d *Document) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(ordered(d.root)); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes the document to the file. It is written in YAML if the extension is .yaml or .yml,
+// otherwise in JSON. Keys are written in the conventional OpenAPI order, and the other keys are sorted.
+// The comments of the loaded document are not written. The document is written to a temporary file
+// that replaces the file, so the readers never see a partially written document.
+func (d *Document) WriteFile(path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = d.YAML()
+	default:
+		data, err = d.JSON()
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) //nolint
+	if _, err := f.Write(data); err != nil {
+		f.Close() //nolint
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// keyOrder is the order of the well-known keys in the written document.
+var keyOrder = []string{
+	"openapi", "info", "title", "version", "servers", "tags",
+	"summary", "description", "operationId", "name", "in", "required", "deprecated",
+	"type", "format", "nullable", "paths", "components", "parameters", "requestBody",
+	"responses", "headers", "content", "schema", "properties", "items", "example", "examples", "value",
+}
+
+// keyRank is the rank of the well-known keys. Other keys are ranked after them.
+var keyRank = func() map[string]int {
+	rank := make(map[string]int, len(keyOrder))
+	for i, k := range keyOrder {
+		rank[k] = i
+	}
+	return rank
+}()
+
+// orderedObject is an object whose keys are written in order.
+type orderedObject []orderedField
+
+// orderedField is a field of the orderedObject.
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+// mapKeys are the keys whose value is a map of user-defined names. e.g. property names, status codes
+var mapKeys = map[string]bool{
+	"paths": true, "properties": true, "responses": true, "examples": true, "content": true, "headers": true,
+	"schemas": true, "parameters": true, "requestBodies": true, "securitySchemes": true, "links": true,
+	"callbacks": true, "variables": true,
+}
+
+// dataKeys are the keys whose value is user data. e.g. examples, default values
+var dataKeys = map[string]bool{"example": true, "value": true, "default": true, "enum": true}
+
+// ordered converts the objects of the value to orderedObject. Whole numbers are converted to integers.
+// The well-known keys of the OpenAPI objects are ranked by keyOrder. The keys of user-defined maps and
+// user data are sorted alphabetically.
+func ordered(v interface{}) interface{} {
+	return orderedValue(v, "", false)
+}
+
+// orderedValue converts the value of the parent key to orderedObject.
+func orderedValue(v interface{}, parentKey string, data bool) interface{} {
+	data = data || dataKeys[parentKey]
+	switch x := v.(type) {
+	case map[string]interface{}:
+		alphabetical := data || mapKeys[parentKey]
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			ri, iok := keyRank[keys[i]]
+			rj, jok := keyRank[keys[j]]
+			switch {
+			case alphabetical || !iok && !jok:
+				return keys[i] < keys[j]
+			case iok && jok:
+				return ri < rj
+			default:
+				return iok
+			}
+		})
+		object := make(orderedObject, 0, len(keys))
+		for _, k := range keys {
+			childKey := k
+			if alphabetical {
+				childKey = ""
+			}
+			object = append(object, orderedField{key: k, value: orderedValue(x[k], childKey, data)})
+		}
+		return object
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, value := range x {
+			s[i] = orderedValue(value, "", data)
+		}
+		return s
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case float64:
+		if x == float64(int64(x)) {
+			return int64(x)
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+// MarshalJSON writes the fields in order.
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML writes the fields in order.
+func (o orderedObject) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, f := range o {
+		key := &yaml.Node{}
+		if err := key.Encode(f.key); err != nil {
+			return nil, err
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(f.value); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	return node, nil
+}
@@ -0,0 +1,233 @@
+package openapi_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+// usersHandler returns the handler of the users api.
+func usersHandler() http.Handler {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 2, "name": "Jerry"}`))
+	})
+	handler.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/users/1" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Tom", "tags": ["cat"], "created_at": "2024-01-01T00:00:00Z"}`))
+	})
+	handler.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return handler
+}
+
+// readYAML reads the YAML file as a map.
+func readYAML(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// lookup returns the value of the keys in the nested maps.
+func lookup(t *testing.T, v interface{}, keys ...string) interface{} {
+	t.Helper()
+	for _, k := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			t.Fatalf("%v is not an object", v)
+		}
+		if v, ok = m[k]; !ok {
+			t.Fatalf("key %s is not found in %v", k, m)
+		}
+	}
+	return v
+}
+
+func TestFormatter(t *testing.T) {
+	t.Run("generate a new document", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "openapi.yaml")
+		formatter := openapi.NewFormatter(openapi.FormatterConfig{Path: path, Title: "Users"})
+
+		spectest.New("get user").
+			Report(formatter).
+			Handler(usersHandler()).
+			Get("/users/1").
+			Query("include", "posts").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+		spectest.New("create user").
+			Report(formatter).
+			Handler(usersHandler()).
+			CustomReportName("create_user").
+			Post("/users").
+			JSON(`{"name": "Jerry"}`).
+			Expect(t).
+			Status(http.StatusCreated).
+			End()
+		spectest.New().
+			Report(formatter).
+			Handler(usersHandler()).
+			Delete("/orders/550e8400-e29b-41d4-a716-446655440000/items/3").
+			Expect(t).
+			Status(http.StatusNoContent).
+			End()
+
+		doc := readYAML(t, path)
+		spectest.DefaultVerifier{}.Equal(t, "Users", lookup(t, doc, "info", "title"))
+
+		getUser := lookup(t, doc, "paths", "/users/{userId}", "get")
+		spectest.DefaultVerifier{}.Equal(t, "get user", lookup(t, getUser, "summary"))
+		spectest.DefaultVerifier{}.Equal(t, []interface{}{
+			map[string]interface{}{"name": "userId", "in": "path", "required": true, "schema": map[string]interface{}{"type": "integer"}},
+			map[string]interface{}{"name": "include", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+		}, lookup(t, getUser, "parameters"))
+
+		userJSON := lookup(t, getUser, "responses", "200", "content", "application/json")
+		spectest.DefaultVerifier{}.Equal(t, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":         map[string]interface{}{"type": "integer"},
+				"name":       map[string]interface{}{"type": "string"},
+				"tags":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"created_at": map[string]interface{}{"type": "string", "format": "date-time"},
+			},
+		}, lookup(t, userJSON, "schema"))
+		spectest.DefaultVerifier{}.Equal(t, "Tom", lookup(t, userJSON, "examples", "get user", "value", "name"))
+
+		createUser := lookup(t, doc, "paths", "/users", "post")
+		spectest.DefaultVerifier{}.Equal(t, "Jerry", lookup(t, createUser, "requestBody", "content", "application/json", "examples", "create_user", "value", "name"))
+		spectest.DefaultVerifier{}.Equal(t, "Created", lookup(t, createUser, "responses", "201", "description"))
+
+		lookup(t, doc, "paths", "/orders/{orderId}/items/{itemId}", "delete", "responses", "204")
+
+		// The generated document validates the same traffic.
+		generated, err := openapi.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.New().
+			Handler(usersHandler()).
+			Get("/users/1").
+			Expect(t).
+			Status(http.StatusOK).
+			Assert(openapi.Validate(generated)).
+			End()
+	})
+
+	t.Run("update an existing document", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "openapi.yaml")
+		existing := `openapi: 3.0.3
+info:
+  title: Users
+  version: 2.0.0
+paths:
+  /users/{id}:
+    get:
+      description: Hand-written description.
+      parameters:
+        - name: id
+          in: path
+          required: true
+          description: The user id.
+          schema:
+            type: string
+      responses:
+        '200':
+          description: The user.
+          content:
+            application/json:
+              example:
+                id: 100
+`
+		if err := os.WriteFile(path, []byte(existing), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		formatter := openapi.NewFormatter(openapi.FormatterConfig{Path: path})
+
+		spectest.New("get user").
+			Report(formatter).
+			Handler(usersHandler()).
+			Get("/users/1").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+		spectest.New("user not found").
+			Report(formatter).
+			Handler(usersHandler()).
+			Get("/users/2").
+			Expect(t).
+			Status(http.StatusNotFound).
+			End()
+
+		doc := readYAML(t, path)
+		spectest.DefaultVerifier{}.Equal(t, "2.0.0", lookup(t, doc, "info", "version"))
+		paths := lookup(t, doc, "paths").(map[string]interface{})
+		spectest.DefaultVerifier{}.Equal(t, 1, len(paths))
+
+		getUser := lookup(t, paths, "/users/{id}", "get")
+		spectest.DefaultVerifier{}.Equal(t, "Hand-written description.", lookup(t, getUser, "description"))
+		spectest.DefaultVerifier{}.Equal(t, 1, len(lookup(t, getUser, "parameters").([]interface{})))
+		spectest.DefaultVerifier{}.Equal(t, "The user.", lookup(t, getUser, "responses", "200", "description"))
+		spectest.DefaultVerifier{}.Equal(t, map[string]interface{}{
+			"example": map[string]interface{}{"id": 100},
+			"schema":  lookup(t, getUser, "responses", "200", "content", "application/json", "schema"),
+		}, lookup(t, getUser, "responses", "200", "content", "application/json"))
+		spectest.DefaultVerifier{}.Equal(t, "not found", lookup(t, getUser, "responses", "404", "content", "application/json", "examples", "user not found", "value", "message"))
+	})
+
+	t.Run("wait for the lock of another process", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "openapi.yaml")
+		// The lock file is held by another test binary of 'go test ./...'.
+		if err := os.WriteFile(path+".lock", nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			spectest.New("get user").
+				Report(openapi.NewFormatter(openapi.FormatterConfig{Path: path})).
+				Handler(usersHandler()).
+				Get("/users/1").
+				Expect(t).
+				Status(http.StatusOK).
+				End()
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatal("the document must not be written while another process holds the lock")
+		}
+		if err := os.Remove(path + ".lock"); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+
+		lookup(t, readYAML(t, path), "paths", "/users/{userId}", "get")
+		if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+			t.Error("the lock file must be removed after the update")
+		}
+	})
+}
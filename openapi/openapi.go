@@ -0,0 +1,310 @@
+// Package openapi validates the inbound request and the final response of a spec against an OpenAPI 3 document.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// httpMethods is the list of http methods that can have an operation in a path item.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	// root is the decoded document.
+	root map[string]interface{}
+	// basePaths is the list of path prefixes defined by the servers.
+	basePaths []string
+	// operations is the list of operations sorted by matching priority.
+	operations []*Operation
+}
+
+// Operation is an operation of the document. e.g. GET /users/{id}
+type Operation struct {
+	// ID is the operationId. If the operation has no operationId, it is "METHOD path".
+	ID string
+	// Method is the upper case http method. e.g. GET
+	Method string
+	// Path is the path template. e.g. /users/{id}
+	Path string
+	// StatusCodes is the list of documented response status codes. e.g. 200, 4XX, default
+	StatusCodes []string
+
+	// pathItem is the path item object that holds the operation.
+	pathItem map[string]interface{}
+	// operation is the operation object.
+	operation map[string]interface{}
+	// matcher matches the request path against the path template.
+	matcher *regexp.Regexp
+	// paramNames is the list of path parameter names in the order of the path template.
+	paramNames []string
+}
+
+// Load reads an OpenAPI 3 document in JSON or YAML format from the file.
+func Load(path string) (*Document, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses an OpenAPI 3 document in JSON or YAML format.
+func Parse(data []byte) (*Document, error) {
+	var raw interface{}
+	if json.Valid(data) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	root, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("OpenAPI document must be an object")
+	}
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version '%s'. only OpenAPI 3 is supported", version)
+	}
+
+	doc := &Document{root: root}
+	doc.basePaths = doc.serverBasePaths()
+	if err := doc.buildOperations(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Operations returns every operation of the document sorted by path and method.
+func (d *Document) Operations() []*Operation {
+	ops := append([]*Operation{}, d.operations...)
+	sort.SliceStable(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// FindOperation finds the operation that matches the http method and the request path.
+// The path may start with the base path of a server. It returns the values of the path parameters.
+func (d *Document) FindOperation(method, path string) (*Operation, map[string]string, error) {
+	method = strings.ToUpper(method)
+	for _, candidate := range d.candidatePaths(path) {
+		for _, op := range d.operations {
+			if op.Method != method {
+				continue
+			}
+			params, ok := op.match(candidate)
+			if ok {
+				return op, params, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("openapi: no operation matches %s %s", method, path)
+}
+
+// candidatePaths returns the request path without the base path of each server.
+func (d *Document) candidatePaths(path string) []string {
+	candidates := []string{}
+	for _, base := range d.basePaths {
+		if base != "" && strings.HasPrefix(path, base) {
+			candidates = append(candidates, "/"+strings.TrimPrefix(strings.TrimPrefix(path, base), "/"))
+		}
+	}
+	return append(candidates, path)
+}
+
+// serverBasePaths returns the path prefixes of the servers. Server variables are replaced with their default value.
+func (d *Document) serverBasePaths() []string {
+	servers, _ := d.root["servers"].([]interface{})
+	basePaths := []string{}
+	for _, s := range servers {
+		server, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rawURL, _ := server["url"].(string)
+		variables, _ := server["variables"].(map[string]interface{})
+		for name, v := range variables {
+			variable, _ := v.(map[string]interface{})
+			rawURL = strings.ReplaceAll(rawURL, "{"+name+"}", fmt.Sprint(variable["default"]))
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		if base := strings.TrimSuffix(u.Path, "/"); base != "" {
+			basePaths = append(basePaths, base)
+		}
+	}
+	return basePaths
+}
+
+// pathParamRegexp matches the path parameter in a path template. e.g. {id}
+var pathParamRegexp = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// buildOperations builds the operations from the paths object.
+// Operations with less path parameters are matched first, so /users/me is preferred over /users/{id}.
+func (d *Document) buildOperations() error {
+	d.operations = nil
+	paths, _ := d.root["paths"].(map[string]interface{})
+	for path, rawItem := range paths {
+		pathItem, ok := d.resolve(rawItem).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		matcher, paramNames, err := compilePathTemplate(path)
+		if err != nil {
+			return err
+		}
+		for _, method := range httpMethods {
+			operation, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := &Operation{
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				pathItem:    pathItem,
+				operation:   operation,
+				matcher:     matcher,
+				paramNames:  paramNames,
+				StatusCodes: []string{},
+			}
+			op.ID, _ = operation["operationId"].(string)
+			if op.ID == "" {
+				op.ID = fmt.Sprintf("%s %s", op.Method, op.Path)
+			}
+			responses, _ := operation["responses"].(map[string]interface{})
+			for code := range responses {
+				op.StatusCodes = append(op.StatusCodes, code)
+			}
+			sort.Strings(op.StatusCodes)
+			d.operations = append(d.operations, op)
+		}
+	}
+
+	sort.SliceStable(d.operations, func(i, j int) bool {
+		a, b := d.operations[i], d.operations[j]
+		if len(a.paramNames) != len(b.paramNames) {
+			return len(a.paramNames) < len(b.paramNames)
+		}
+		return a.Path < b.Path
+	})
+	return nil
+}
+
+// compilePathTemplate compiles the path template to a regexp that matches the request path.
+func compilePathTemplate(path string) (*regexp.Regexp, []string, error) {
+	var pattern strings.Builder
+	paramNames := []string{}
+	last := 0
+	for _, loc := range pathParamRegexp.FindAllStringSubmatchIndex(path, -1) {
+		pattern.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		pattern.WriteString("([^/]+)")
+		paramNames = append(paramNames, path[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(path[last:]))
+
+	matcher, err := regexp.Compile("^" + pattern.String() + "/?$")
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid path template '%s': %w", path, err)
+	}
+	return matcher, paramNames, nil
+}
+
+// match matches the request path against the path template. It returns the values of the path parameters.
+func (o *Operation) match(path string) (map[string]string, bool) {
+	found := o.matcher.FindStringSubmatch(path)
+	if found == nil {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, name := range o.paramNames {
+		value, err := url.PathUnescape(found[i+1])
+		if err != nil {
+			value = found[i+1]
+		}
+		params[name] = value
+	}
+	return params, true
+}
+
+// resolve follows the local reference ("$ref": "#/components/...") of the node.
+// Nodes that are not a reference are returned as they are.
+func (d *Document) resolve(node interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return node
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return node
+		}
+		resolved, err := d.pointer(ref)
+		if err != nil {
+			return node
+		}
+		node = resolved
+	}
+	return node
+}
+
+// pointer returns the node at the local reference. e.g. #/components/schemas/User
+func (d *Document) pointer(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only local references are supported: %s", ref)
+	}
+	var node interface{} = d.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid reference: %s", ref)
+		}
+		if node, ok = m[token]; !ok {
+			return nil, fmt.Errorf("reference not found: %s", ref)
+		}
+	}
+	return node, nil
+}
+
+// normalize converts the decoded YAML to the same types as the decoded JSON.
+// YAML mappings with non-string keys (e.g. response status codes) are converted to map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, value := range x {
+			x[k] = normalize(value)
+		}
+		return x
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, value := range x {
+			m[fmt.Sprint(k)] = normalize(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range x {
+			x[i] = normalize(value)
+		}
+		return x
+	default:
+		return v
+	}
+}
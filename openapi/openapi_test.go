@@ -0,0 +1,232 @@
+package openapi_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/mocks"
+	"github.com/nao1215/spectest/openapi"
+)
+
+func loadPetstore(t *testing.T) *openapi.Document {
+	t.Helper()
+	doc, err := openapi.Load("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// petstoreHandler returns the handler of the petstore api. The response can be broken by the "broken" query parameter.
+func petstoreHandler() http.Handler {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/v1/pets", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Query().Get("broken") != "" {
+				_, _ = w.Write([]byte(`[{"id": "1", "name": "Tom"}]`))
+				return
+			}
+			w.Header().Set("X-Total-Count", "1")
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "Tom", "tag": null}]`))
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 2, "name": "Jerry"}`))
+		}
+	})
+	handler.HandleFunc("/v1/pets/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "not found"}`))
+	})
+	return handler
+}
+
+// violationCaptor returns a MockVerifier that captures the error of the assertion.
+func violationCaptor(captured *error) *mocks.MockVerifier {
+	return &mocks.MockVerifier{
+		NoErrorFn: func(t spectest.TestingT, err error, msgAndArgs ...interface{}) bool {
+			*captured = err
+			return err == nil
+		},
+		EqualFn: func(t spectest.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+			return true
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc := loadPetstore(t)
+
+	t.Run("valid request and response", func(t *testing.T) {
+		spectest.New().
+			Handler(petstoreHandler()).
+			Get("/v1/pets").
+			Query("limit", "10").
+			Expect(t).
+			Status(http.StatusOK).
+			Assert(openapi.Validate(doc)).
+			End()
+	})
+
+	t.Run("valid request body", func(t *testing.T) {
+		spectest.New().
+			Handler(petstoreHandler()).
+			Post("/v1/pets").
+			Header("X-Request-ID", "abc").
+			JSON(`{"name": "Jerry"}`).
+			Expect(t).
+			Status(http.StatusCreated).
+			Assert(openapi.Validate(doc)).
+			End()
+	})
+
+	t.Run("response matched by status code range", func(t *testing.T) {
+		spectest.New().
+			Handler(petstoreHandler()).
+			Get("/v1/pets/1").
+			Expect(t).
+			Status(http.StatusNotFound).
+			Assert(openapi.Validate(doc)).
+			End()
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		var err error
+		spectest.New().
+			Handler(petstoreHandler()).
+			Verifier(violationCaptor(&err)).
+			Post("/v1/pets").
+			JSON(`{"name": "", "tag": 1}`).
+			Expect(t).
+			Assert(openapi.ValidateRequest(doc)).
+			End()
+
+		var validationErr *openapi.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, "createPet", validationErr.OperationID)
+		spectest.DefaultVerifier{}.Equal(t, []string{
+			`header parameter "X-Request-ID": is required`,
+			"request body /name: String length must be greater than or equal to 1",
+			"request body /tag: Invalid type. Expected: [string,null], given: integer",
+		}, violationStrings(validationErr))
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		var err error
+		spectest.New().
+			Handler(petstoreHandler()).
+			Verifier(violationCaptor(&err)).
+			Get("/v1/pets").
+			Query("broken", "true").
+			Query("limit", "1000").
+			Expect(t).
+			Assert(openapi.ValidateResponse(doc)).
+			End()
+
+		var validationErr *openapi.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, "listPets", validationErr.OperationID)
+		spectest.DefaultVerifier{}.Equal(t, []string{
+			`response header "X-Total-Count": is required`,
+			"response body /0/id: Invalid type. Expected: integer, given: string",
+		}, violationStrings(validationErr))
+		if !strings.Contains(err.Error(), "does not conform to operation 'listPets'") {
+			t.Errorf("unexpected message: %s", err.Error())
+		}
+	})
+
+	t.Run("undocumented status code and parameter", func(t *testing.T) {
+		var err error
+		spectest.New().
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}).
+			Verifier(violationCaptor(&err)).
+			Get("/v1/pets/abc").
+			Expect(t).
+			Assert(openapi.Validate(doc)).
+			End()
+
+		var validationErr *openapi.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, "showPetById", validationErr.OperationID)
+		spectest.DefaultVerifier{}.Equal(t, []string{
+			`path parameter "petId": Invalid type. Expected: integer, given: string`,
+			"response status code: 500 is not documented. documented: 200, 4XX",
+		}, violationStrings(validationErr))
+	})
+}
+
+func violationStrings(err *openapi.ValidationError) []string {
+	s := make([]string, 0, len(err.Violations))
+	for _, v := range err.Violations {
+		s = append(s, v.String())
+	}
+	return s
+}
+
+func TestDocumentFindOperation(t *testing.T) {
+	doc := loadPetstore(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   string
+		params map[string]string
+	}{
+		{name: "static path", method: http.MethodGet, path: "/pets", want: "listPets", params: map[string]string{}},
+		{name: "base path of server", method: http.MethodPost, path: "/v1/pets", want: "createPet", params: map[string]string{}},
+		{name: "static path is preferred", method: http.MethodGet, path: "/v1/pets/mine", want: "GET /pets/mine", params: map[string]string{}},
+		{name: "path parameter", method: http.MethodGet, path: "/v1/pets/42", want: "showPetById", params: map[string]string{"petId": "42"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			op, params, err := doc.FindOperation(tt.method, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spectest.DefaultVerifier{}.Equal(t, tt.want, op.ID)
+			spectest.DefaultVerifier{}.Equal(t, tt.params, params)
+		})
+	}
+
+	t.Run("no operation", func(t *testing.T) {
+		if _, _, err := doc.FindOperation(http.MethodDelete, "/v1/pets"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("json document", func(t *testing.T) {
+		doc, err := openapi.Parse([]byte(`{"openapi": "3.1.0", "paths": {"/users": {"get": {"responses": {"200": {"description": "ok"}}}}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops := doc.Operations()
+		spectest.DefaultVerifier{}.Equal(t, 1, len(ops))
+		spectest.DefaultVerifier{}.Equal(t, []string{"200"}, ops[0].StatusCodes)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := openapi.Parse([]byte(`swagger: "2.0"`))
+		if err == nil || !strings.Contains(err.Error(), "unsupported OpenAPI version") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
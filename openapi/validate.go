@@ -0,0 +1,484 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nao1215/spectest"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Violation is a part of the request or response that does not conform to the OpenAPI document.
+type Violation struct {
+	// In is the part of the request or response. e.g. request body, query parameter "limit"
+	In string
+	// Pointer is the JSON pointer of the invalid value. It is empty if the whole value is invalid.
+	Pointer string
+	// Message is the description of the violation
+	Message string
+}
+
+// String returns the violation in human readable format.
+func (v Violation) String() string {
+	if v.Pointer == "" {
+		return fmt.Sprintf("%s: %s", v.In, v.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", v.In, v.Pointer, v.Message)
+}
+
+// ValidationError is returned when the request or response does not conform to the operation.
+type ValidationError struct {
+	// OperationID is the operationId of the operation. If the operation has no operationId, it is "METHOD path".
+	OperationID string
+	// Method is the http method of the request
+	Method string
+	// Path is the path of the request
+	Path string
+	// Violations is the list of violations
+	Violations []Violation
+}
+
+// Error returns the operation and every violation.
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "openapi: %s %s does not conform to operation '%s'", e.Method, e.Path, e.OperationID)
+	for _, v := range e.Violations {
+		fmt.Fprintf(&b, "\n  - %s", v)
+	}
+	return b.String()
+}
+
+// Validate validates the inbound request and the final response against the operation of the document
+// that matches the method and path of the request.
+func Validate(doc *Document) spectest.Assert {
+	return func(res *http.Response, req *http.Request) error {
+		return doc.Validate(res, req)
+	}
+}
+
+// ValidateRequest validates the inbound request against the operation of the document.
+func ValidateRequest(doc *Document) spectest.Assert {
+	return func(_ *http.Response, req *http.Request) error {
+		return doc.ValidateRequest(req)
+	}
+}
+
+// ValidateResponse validates the final response against the operation of the document
+// that matches the method and path of the request.
+func ValidateResponse(doc *Document) spectest.Assert {
+	return func(res *http.Response, req *http.Request) error {
+		return doc.ValidateResponse(res, req)
+	}
+}
+
+// Validate validates the request and the response against the operation that matches the request.
+func (d *Document) Validate(res *http.Response, req *http.Request) error {
+	op, params, err := d.FindOperation(req.Method, req.URL.Path)
+	if err != nil {
+		return err
+	}
+	violations, err := d.requestViolations(op, params, req)
+	if err != nil {
+		return err
+	}
+	resViolations, err := d.responseViolations(op, res)
+	if err != nil {
+		return err
+	}
+	return newValidationError(op, req, append(violations, resViolations...))
+}
+
+// ValidateRequest validates the request against the operation that matches the request.
+func (d *Document) ValidateRequest(req *http.Request) error {
+	op, params, err := d.FindOperation(req.Method, req.URL.Path)
+	if err != nil {
+		return err
+	}
+	violations, err := d.requestViolations(op, params, req)
+	if err != nil {
+		return err
+	}
+	return newValidationError(op, req, violations)
+}
+
+// ValidateResponse validates the response against the operation that matches the request.
+func (d *Document) ValidateResponse(res *http.Response, req *http.Request) error {
+	op, _, err := d.FindOperation(req.Method, req.URL.Path)
+	if err != nil {
+		return err
+	}
+	violations, err := d.responseViolations(op, res)
+	if err != nil {
+		return err
+	}
+	return newValidationError(op, req, violations)
+}
+
+// newValidationError returns nil if there is no violation.
+func newValidationError(op *Operation, req *http.Request, violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{
+		OperationID: op.ID,
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Violations:  violations,
+	}
+}
+
+// parameters returns the parameters of the operation. Parameters of the operation override
+// the parameters of the path item that have the same name and location.
+func (d *Document) parameters(op *Operation) []map[string]interface{} {
+	params := []map[string]interface{}{}
+	index := map[string]int{}
+	for _, owner := range []map[string]interface{}{op.pathItem, op.operation} {
+		list, _ := owner["parameters"].([]interface{})
+		for _, p := range list {
+			param, ok := d.resolve(p).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%v:%v", param["in"], param["name"])
+			if i, ok := index[key]; ok {
+				params[i] = param
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// requestViolations validates the parameters and the body of the request.
+func (d *Document) requestViolations(op *Operation, pathParams map[string]string, req *http.Request) ([]Violation, error) {
+	violations := []Violation{}
+	query := req.URL.Query()
+
+	for _, param := range d.parameters(op) {
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+		schema, _ := d.resolve(param["schema"]).(map[string]interface{})
+		location := fmt.Sprintf("%s parameter \"%s\"", in, name)
+
+		var values []string
+		switch in {
+		case "path":
+			if v, ok := pathParams[name]; ok {
+				values = []string{v}
+			}
+			required = true
+		case "query":
+			values = query[name]
+		case "header":
+			values = req.Header.Values(name)
+		case "cookie":
+			if c, err := req.Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+
+		if len(values) == 0 {
+			if required {
+				violations = append(violations, Violation{In: location, Message: "is required"})
+			}
+			continue
+		}
+		if schema == nil {
+			continue
+		}
+		found, err := d.validateSchema(location, schema, parseParameter(schema, in, values))
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+
+	requestBody, ok := d.resolve(op.operation["requestBody"]).(map[string]interface{})
+	if !ok {
+		return violations, nil
+	}
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		if required, _ := requestBody["required"].(bool); required {
+			violations = append(violations, Violation{In: "request body", Message: "is required"})
+		}
+		return violations, nil
+	}
+	found, err := d.validateContent("request body", requestBody, req.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, found...), nil
+}
+
+// responseViolations validates the status code, the headers and the body of the response.
+func (d *Document) responseViolations(op *Operation, res *http.Response) ([]Violation, error) {
+	responses, _ := op.operation["responses"].(map[string]interface{})
+	response, ok := d.resolve(findResponse(responses, res.StatusCode)).(map[string]interface{})
+	if !ok {
+		return []Violation{{
+			In:      "response status code",
+			Message: fmt.Sprintf("%d is not documented. documented: %s", res.StatusCode, strings.Join(op.StatusCodes, ", ")),
+		}}, nil
+	}
+
+	violations := []Violation{}
+	headers, _ := response["headers"].(map[string]interface{})
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		header, _ := d.resolve(headers[name]).(map[string]interface{})
+		location := fmt.Sprintf("response header \"%s\"", name)
+		values := res.Header.Values(name)
+		if len(values) == 0 {
+			if required, _ := header["required"].(bool); required {
+				violations = append(violations, Violation{In: location, Message: "is required"})
+			}
+			continue
+		}
+		schema, ok := d.resolve(header["schema"]).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		found, err := d.validateSchema(location, schema, parseParameter(schema, "header", values))
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, found...)
+	}
+
+	body, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return violations, nil
+	}
+	found, err := d.validateContent("response body", response, res.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	return append(violations, found...), nil
+}
+
+// findResponse returns the response object of the status code.
+func findResponse(responses map[string]interface{}, statusCode int) interface{} {
+	key, ok := responseKey(responses, statusCode)
+	if !ok {
+		return nil
+	}
+	return responses[key]
+}
+
+// responseKey returns the key of the response object that documents the status code. The exact status code
+// is preferred over the range (e.g. 2XX), and the range is preferred over the default response.
+func responseKey(responses map[string]interface{}, statusCode int) (string, bool) {
+	code := strconv.Itoa(statusCode)
+	if _, ok := responses[code]; ok {
+		return code, true
+	}
+	for k := range responses {
+		if strings.EqualFold(k, code[:1]+"XX") {
+			return k, true
+		}
+	}
+	if _, ok := responses["default"]; ok {
+		return "default", true
+	}
+	return "", false
+}
+
+// validateContent validates the body against the schema of the media type in the content of the owner
+// (a request body or a response object). Only JSON bodies are validated against the schema.
+func (d *Document) validateContent(location string, owner map[string]interface{}, contentType string, body []byte) ([]Violation, error) {
+	content, ok := owner["content"].(map[string]interface{})
+	if !ok || len(content) == 0 {
+		return nil, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	media, ok := findMediaType(content, mediaType)
+	if !ok {
+		return []Violation{{In: location, Message: fmt.Sprintf("content type '%s' is not documented", contentType)}}, nil
+	}
+	if !isJSON(mediaType) {
+		return nil, nil
+	}
+	schema, ok := d.resolve(media["schema"]).(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []Violation{{In: location, Message: fmt.Sprintf("invalid json: %s", err)}}, nil
+	}
+	return d.validateSchema(location, schema, value)
+}
+
+// findMediaType returns the media type object that matches the media type. e.g. application/*, */*
+func findMediaType(content map[string]interface{}, mediaType string) (map[string]interface{}, bool) {
+	candidates := []string{mediaType, "*/*"}
+	if i := strings.Index(mediaType, "/"); i > 0 {
+		candidates = []string{mediaType, mediaType[:i] + "/*", "*/*"}
+	}
+	for _, candidate := range candidates {
+		for k, v := range content {
+			if !strings.EqualFold(k, candidate) {
+				continue
+			}
+			media, _ := v.(map[string]interface{})
+			if media == nil {
+				media = map[string]interface{}{}
+			}
+			return media, true
+		}
+	}
+	return nil, false
+}
+
+// isJSON returns true if the media type is JSON. e.g. application/json, application/problem+json
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateSchema validates the value against the schema. References to the components of the document
+// are resolved, and OpenAPI 3.0 "nullable" is converted to the JSON schema "null" type.
+func (d *Document) validateSchema(location string, schema map[string]interface{}, value interface{}) ([]Violation, error) {
+	root := map[string]interface{}{
+		"allOf":      []interface{}{toJSONSchema(schema)},
+		"components": toJSONSchema(d.root["components"]),
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(root), gojsonschema.NewGoLoader(value))
+	if err != nil {
+		return nil, fmt.Errorf("openapi: failed to validate %s: %w", location, err)
+	}
+
+	violations := []Violation{}
+	for _, e := range result.Errors() {
+		if e.Type() == "number_all_of" {
+			continue
+		}
+		violations = append(violations, Violation{
+			In:      location,
+			Pointer: toJSONPointer(e.Context()),
+			Message: e.Description(),
+		})
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Pointer < violations[j].Pointer
+	})
+	return violations, nil
+}
+
+// toJSONPointer converts the gojsonschema context (e.g. (root).items.0.name) to the JSON pointer (e.g. /items/0/name).
+func toJSONPointer(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+	return strings.TrimPrefix(context.String("/"), gojsonschema.STRING_CONTEXT_ROOT)
+}
+
+// toJSONSchema returns a copy of the OpenAPI schema that can be validated as a JSON schema.
+func toJSONSchema(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, value := range x {
+			m[k] = toJSONSchema(value)
+		}
+		if nullable, _ := m["nullable"].(bool); nullable {
+			if t, ok := m["type"].(string); ok {
+				m["type"] = []interface{}{t, "null"}
+			}
+			if enum, ok := m["enum"].([]interface{}); ok {
+				m["enum"] = append(enum, nil)
+			}
+		}
+		delete(m, "nullable")
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, value := range x {
+			s[i] = toJSONSchema(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// parseParameter converts the string values of the parameter to the type of the schema.
+// Values that can not be converted are returned as strings, so they are reported by the schema validation.
+func parseParameter(schema map[string]interface{}, in string, values []string) interface{} {
+	if schemaType(schema) == "array" {
+		items, _ := schema["items"].(map[string]interface{})
+		if in != "query" && len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		array := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			array = append(array, parseValue(items, strings.TrimSpace(v)))
+		}
+		return array
+	}
+	return parseValue(schema, values[0])
+}
+
+// parseValue converts the string value to the type of the schema.
+func parseValue(schema map[string]interface{}, value string) interface{} {
+	switch schemaType(schema) {
+	case "integer":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// schemaType returns the type of the schema.
+func schemaType(schema map[string]interface{}) string {
+	t, _ := schema["type"].(string)
+	return t
+}
+
+// readBody reads the body and replaces it with a new reader, so it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
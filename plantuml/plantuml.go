@@ -54,29 +54,37 @@ func (r *DSL) addRow(operation, source, target, description, body string) {
 		note))
 }
 
+func (r *DSL) addNoteAcross(text string) {
+	r.data.WriteString(fmt.Sprintf("note across\n%s\nend note\n", escape(text)))
+}
+
 func (r *DSL) ToString() string {
 	return fmt.Sprintf("\n@startuml\nskinparam noteFontSize 11\nskinparam monochrome true\n%s\n@enduml", r.data.String())
 }
 
 func (r *Formatter) Format(recorder *spectest.Recorder) {
+	if err := r.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+func (r *Formatter) FormatWithError(recorder *spectest.Recorder) error {
 	var sb strings.Builder
 
 	meta, err := json.Marshal(recorder.Meta)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	markup, err := buildMarkup(recorder)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	sb.Write(meta)
 	sb.Write([]byte(markup))
 
 	_, err = r.writer.Write([]byte(sb.String()))
-	if err != nil {
-		panic(err)
-	}
+	return err
 }
 
 func NewFormatter(writer io.Writer) spectest.ReportFormatter {
@@ -111,8 +119,15 @@ func buildMarkup(r *spectest.Recorder) (string, error) {
 		case spectest.MessageResponse:
 			dsl.AddResponseRow(v.Source, v.Target, v.Header, v.Body)
 		default:
-			panic("received unknown event type")
+			return "", fmt.Errorf("received unknown event type: %T", event)
+		}
+	}
+	if len(r.AssertionResults) > 0 {
+		assertions := make([]string, 0, len(r.AssertionResults))
+		for _, a := range r.AssertionResults {
+			assertions = append(assertions, formatAssertion(a))
 		}
+		dsl.addNoteAcross(strings.Join(assertions, "\n"))
 	}
 
 	return dsl.ToString(), nil
@@ -125,3 +140,13 @@ func escape(in string) string {
 func formatNote(entry spectest.LogEntry) string {
 	return fmt.Sprintf("%s%s", entry.Header, entry.Body)
 }
+
+func formatAssertion(a spectest.AssertionResult) string {
+	if a.Passed {
+		return fmt.Sprintf("PASS %s", a.Name)
+	}
+	if a.Message == "" {
+		return fmt.Sprintf("FAIL %s", a.Name)
+	}
+	return fmt.Sprintf("FAIL %s: %s", a.Name, a.Message)
+}
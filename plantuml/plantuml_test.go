@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nao1215/spectest"
 )
@@ -45,6 +46,34 @@ func TestNewFormatter(t *testing.T) {
 	}
 }
 
+func TestFormatAssertionResults(t *testing.T) {
+	recorder := aRecorder().
+		AddAssertionResult(spectest.AssertionResult{Name: "status code", Passed: true}).
+		AddAssertionResult(spectest.AssertionResult{Name: "body", Passed: false, Message: "body is not equal to the expected body"})
+	capture := &writer{}
+
+	NewFormatter(capture).Format(recorder)
+
+	want := "note across\nPASS status code\nFAIL body: body is not equal to the expected body\nend note\n\n@enduml"
+	if !strings.HasSuffix(capture.captured, want) {
+		t.Errorf("Expected the suffix '%s'\nReceived '%s'\n", want, capture.captured)
+	}
+}
+
+func TestFormatWithErrorReturnsUnknownEventType(t *testing.T) {
+	recorder := aRecorder()
+	recorder.Events = append(recorder.Events, unknownEvent{})
+
+	err := NewFormatter(&writer{}).(spectest.ReportFormatterWithError).FormatWithError(recorder)
+	if err == nil || !strings.Contains(err.Error(), "received unknown event type") {
+		t.Errorf("expected the unknown event type error, got %v", err)
+	}
+}
+
+type unknownEvent struct{}
+
+func (unknownEvent) GetTime() time.Time { return time.Time{} }
+
 type writer struct {
 	captured string
 }
@@ -0,0 +1,572 @@
+package spectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Redaction is a policy that hides secrets, e.g. tokens and passwords, in the reports, the debug output,
+// the cURL command of the failure message and the cassettes. The values are replaced with "[REDACTED]".
+// The request sent to the system under test and the response checked by the assertions are not changed.
+//
+// A nil *Redaction redacts nothing. Set the policy of a test with SpecTest.Redact, or the policy of every
+// test with SetDefaultRedaction.
+type Redaction struct {
+	// headers is the set of the canonical header names whose values are redacted
+	headers map[string]bool
+	// cookies is the set of the cookie names whose values are redacted in the Cookie and Set-Cookie headers
+	cookies map[string]bool
+	// queryParams is the set of the query parameters and the form fields whose values are redacted
+	queryParams map[string]bool
+	// jsonPaths are the JSONPath expressions of the values redacted in the JSON bodies
+	jsonPaths [][]jsonPathSegment
+	// patterns are the regular expressions of the text redacted in the bodies
+	patterns []*regexp.Regexp
+}
+
+// defaultRedaction is the redaction policy of the tests that do not set their own policy.
+var defaultRedaction atomic.Pointer[Redaction]
+
+// SetDefaultRedaction sets the redaction policy of every SpecTest and Scenario that do not call Redact.
+// It is usually called in TestMain. Set nil to redact nothing, which is the default.
+func SetDefaultRedaction(r *Redaction) {
+	defaultRedaction.Store(r)
+}
+
+// activeRedaction returns the redaction policy, or the default redaction policy if it is nil.
+func activeRedaction(r *Redaction) *Redaction {
+	if r != nil {
+		return r
+	}
+	return defaultRedaction.Load()
+}
+
+// NewRedaction creates an empty redaction policy.
+func NewRedaction() *Redaction {
+	return &Redaction{
+		headers:     map[string]bool{},
+		cookies:     map[string]bool{},
+		queryParams: map[string]bool{},
+	}
+}
+
+// StandardRedaction creates a redaction policy for the common secret headers:
+// Authorization (including the basic auth credentials), Proxy-Authorization, Cookie and Set-Cookie.
+func StandardRedaction() *Redaction {
+	return NewRedaction().Headers("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie")
+}
+
+// Headers adds the headers whose values are redacted in the requests and the responses.
+// The names are case-insensitive.
+func (r *Redaction) Headers(names ...string) *Redaction {
+	for _, name := range names {
+		r.headers[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+	return r
+}
+
+// Cookies adds the cookies whose values are redacted in the Cookie and Set-Cookie headers.
+// The other cookies and the attributes of the cookies, e.g. Path, are kept.
+func (r *Redaction) Cookies(names ...string) *Redaction {
+	for _, name := range names {
+		r.cookies[name] = true
+	}
+	return r
+}
+
+// QueryParams adds the query parameters whose values are redacted in the request url.
+// The fields of the application/x-www-form-urlencoded bodies with the same names are redacted too.
+func (r *Redaction) QueryParams(names ...string) *Redaction {
+	for _, name := range names {
+		r.queryParams[name] = true
+	}
+	return r
+}
+
+// JSONPaths adds the JSONPath expressions of the values redacted in the JSON bodies.
+// The expressions support the child (e.g. $.user.password or $['user']['password']), the index (e.g. $.items[0]),
+// the wildcard (e.g. $.items[*].token) and the recursive descent (e.g. $..token).
+// An object or an array that matches the expression is replaced as a whole.
+// It panics if the expression is invalid.
+func (r *Redaction) JSONPaths(paths ...string) *Redaction {
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			panic(fmt.Sprintf("spectest: invalid JSONPath %q: %v", path, err))
+		}
+		r.jsonPaths = append(r.jsonPaths, segments)
+	}
+	return r
+}
+
+// BodyPatterns adds the regular expressions of the text redacted in the bodies.
+// If the expression has capturing groups, only the groups are redacted. e.g. `"token":\s*"([^"]*)"`
+// Otherwise, the whole match is redacted.
+func (r *Redaction) BodyPatterns(patterns ...*regexp.Regexp) *Redaction {
+	r.patterns = append(r.patterns, patterns...)
+	return r
+}
+
+// isEmpty returns true if the policy redacts nothing.
+func (r *Redaction) isEmpty() bool {
+	return r == nil ||
+		(len(r.headers) == 0 && len(r.cookies) == 0 && len(r.queryParams) == 0 && len(r.jsonPaths) == 0 && len(r.patterns) == 0)
+}
+
+// with returns a new policy that redacts everything redacted by r or other.
+func (r *Redaction) with(other *Redaction) *Redaction {
+	merged := NewRedaction()
+	for _, policy := range []*Redaction{r, other} {
+		if policy == nil {
+			continue
+		}
+		for name := range policy.headers {
+			merged.headers[name] = true
+		}
+		for name := range policy.cookies {
+			merged.cookies[name] = true
+		}
+		for name := range policy.queryParams {
+			merged.queryParams[name] = true
+		}
+		merged.jsonPaths = append(merged.jsonPaths, policy.jsonPaths...)
+		merged.patterns = append(merged.patterns, policy.patterns...)
+	}
+	return merged
+}
+
+// request returns a redacted copy of the request. It returns the request itself if nothing is redacted.
+func (r *Redaction) request(req *http.Request) *http.Request {
+	if r.isEmpty() || req == nil {
+		return req
+	}
+	redacted := copyHTTPRequest(req)
+	redacted.Header = r.header(req.Header)
+	if redacted.URL != nil {
+		redacted.URL = r.url(redacted.URL)
+	}
+	if redacted.Body != nil {
+		body, err := io.ReadAll(redacted.Body)
+		if err != nil {
+			body = nil
+		}
+		redactedBody := r.body(req.Header.Get("Content-Type"), body)
+		redacted.Body = io.NopCloser(bytes.NewReader(redactedBody))
+		if !bytes.Equal(body, redactedBody) {
+			redacted.ContentLength = int64(len(redactedBody))
+		}
+	}
+	return redacted
+}
+
+// response returns a redacted copy of the response. It returns the response itself if nothing is redacted.
+func (r *Redaction) response(res *http.Response) *http.Response {
+	if r.isEmpty() || res == nil {
+		return res
+	}
+	redacted := copyHTTPResponse(res)
+	redacted.Header = r.header(res.Header)
+	body, err := io.ReadAll(redacted.Body)
+	if err != nil {
+		body = nil
+	}
+	redactedBody := r.body(res.Header.Get("Content-Type"), body)
+	redacted.Body = io.NopCloser(bytes.NewReader(redactedBody))
+	if !bytes.Equal(body, redactedBody) {
+		redacted.ContentLength = int64(len(redactedBody))
+	}
+	return redacted
+}
+
+// header returns a redacted copy of the headers.
+func (r *Redaction) header(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		copied := make([]string, len(values))
+		for i, value := range values {
+			switch {
+			case r.headers[key]:
+				copied[i] = redactedValue
+			case key == "Cookie" && len(r.cookies) > 0:
+				copied[i] = r.cookieHeader(value)
+			case key == "Set-Cookie" && len(r.cookies) > 0:
+				copied[i] = r.setCookieHeader(value)
+			default:
+				copied[i] = value
+			}
+		}
+		redacted[name] = copied
+	}
+	return redacted
+}
+
+// cookieHeader redacts the values of the cookies in the Cookie header. e.g. "a=1; b=2"
+func (r *Redaction) cookieHeader(value string) string {
+	pairs := strings.Split(value, ";")
+	for i, pair := range pairs {
+		name, _, ok := strings.Cut(pair, "=")
+		if ok && r.cookies[strings.TrimSpace(name)] {
+			pairs[i] = name + "=" + redactedValue
+		}
+	}
+	return strings.Join(pairs, ";")
+}
+
+// setCookieHeader redacts the value of the cookie in the Set-Cookie header. e.g. "a=1; Path=/; HttpOnly"
+func (r *Redaction) setCookieHeader(value string) string {
+	pair, attributes, hasAttributes := strings.Cut(value, ";")
+	name, _, ok := strings.Cut(pair, "=")
+	if !ok || !r.cookies[strings.TrimSpace(name)] {
+		return value
+	}
+	if hasAttributes {
+		return name + "=" + redactedValue + ";" + attributes
+	}
+	return name + "=" + redactedValue
+}
+
+// url returns a copy of the url whose query parameters are redacted.
+// The password of the user info is redacted with the Authorization header.
+func (r *Redaction) url(u *url.URL) *url.URL {
+	redacted := *u
+	redacted.RawQuery = r.query(u.RawQuery)
+	if _, ok := u.User.Password(); ok && r.headers["Authorization"] {
+		redacted.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	return &redacted
+}
+
+// query redacts the values of the parameters in the url encoded query. The other parameters are kept as they are.
+func (r *Redaction) query(query string) string {
+	if len(r.queryParams) == 0 || query == "" {
+		return query
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if r.queryParams[name] {
+			params[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// body redacts the body. The JSONPath expressions are applied to the JSON body, the query parameters
+// are applied to the url encoded form body, and then the regular expressions are applied to any body.
+func (r *Redaction) body(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if len(r.jsonPaths) > 0 && json.Valid(body) {
+		body = redactJSON(body, r.jsonPaths)
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/x-www-form-urlencoded" {
+		body = []byte(r.query(string(body)))
+	}
+	for _, pattern := range r.patterns {
+		body = redactPattern(pattern, body)
+	}
+	return body
+}
+
+// assertion returns a copy of the assertion result whose values are redacted.
+// The values of the header assertions are redacted if the header is redacted, and then
+// the body is applied to the expected value, the actual value and the message.
+func (r *Redaction) assertion(a AssertionResult) AssertionResult {
+	if r.isEmpty() {
+		return a
+	}
+	if name, ok := strings.CutPrefix(a.Name, "header "); ok && r.headers[textproto.CanonicalMIMEHeaderKey(name)] {
+		for _, value := range []*string{&a.Expected, &a.Actual} {
+			if *value == "" || *value == presence(true) || *value == presence(false) {
+				continue
+			}
+			a.Message = strings.ReplaceAll(a.Message, *value, redactedValue)
+			*value = redactedValue
+		}
+	}
+	a.Expected = r.assertionValue(a.Expected)
+	a.Actual = r.assertionValue(a.Actual)
+	a.Message = string(r.body("", []byte(a.Message)))
+	return a
+}
+
+// assertionValue redacts the expected or actual value of the assertion.
+// The JSON value is indented again, so the diff is still shown line by line.
+func (r *Redaction) assertionValue(value string) string {
+	redacted := string(r.body("", []byte(value)))
+	if redacted != value && json.Valid([]byte(value)) {
+		return indentJSON(redacted)
+	}
+	return redacted
+}
+
+// redactPattern redacts the text that matches the pattern. Only the capturing groups are redacted if the pattern has them.
+func redactPattern(pattern *regexp.Regexp, body []byte) []byte {
+	matches := pattern.FindAllSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		spans := [][2]int{{m[0], m[1]}}
+		if len(m) > 2 {
+			spans = spans[:0]
+			for g := 2; g+1 < len(m); g += 2 {
+				if m[g] >= last && m[g] >= 0 {
+					spans = append(spans, [2]int{m[g], m[g+1]})
+				}
+			}
+		}
+		for _, span := range spans {
+			out.Write(body[last:span[0]])
+			out.WriteString(redactedValue)
+			last = span[1]
+		}
+	}
+	out.Write(body[last:])
+	return out.Bytes()
+}
+
+// jsonPathSegment is a step of the JSONPath expression.
+type jsonPathSegment struct {
+	// recursive is true if the segment matches at any depth. e.g. $..token
+	recursive bool
+	// wildcard is true if the segment matches any member or element. e.g. $.items[*]
+	wildcard bool
+	// name is the name of the member. It is used if index is -1.
+	name string
+	// index is the index of the element. It is -1 for a member.
+	index int
+}
+
+// matches returns true if the segment matches the element of the JSON path.
+func (s jsonPathSegment) matches(e jsonPathElement) bool {
+	switch {
+	case s.wildcard:
+		return true
+	case s.index >= 0:
+		return e.index == s.index
+	default:
+		return e.index < 0 && e.name == s.name
+	}
+}
+
+// jsonPathElement is a member name or an array index of the position in the JSON document.
+type jsonPathElement struct {
+	// name is the name of the member. It is used if index is -1.
+	name string
+	// index is the index of the element. It is -1 for a member.
+	index int
+}
+
+// parseJSONPath parses the JSONPath expression.
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("must start with $")
+	}
+	segments := []jsonPathSegment{}
+	rest := path[1:]
+	for rest != "" {
+		segment := jsonPathSegment{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segment.recursive = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ]")
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				segment.wildcard = true
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				segment.name = selector[1 : len(selector)-1]
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid selector [%s]", selector)
+				}
+				segment.index = index
+			}
+			segments = append(segments, segment)
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = rest[end:]
+		switch name {
+		case "":
+			return nil, fmt.Errorf("missing member name")
+		case "*":
+			segment.wildcard = true
+		default:
+			segment.name = name
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// matchJSONPath returns true if the position in the JSON document matches the JSONPath expression.
+func matchJSONPath(segments []jsonPathSegment, path []jsonPathElement) bool {
+	if len(segments) == 0 {
+		return len(path) == 0
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if segments[0].recursive {
+		for i := range path {
+			if segments[0].matches(path[i]) && matchJSONPath(segments[1:], path[i+1:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return segments[0].matches(path[0]) && matchJSONPath(segments[1:], path[1:])
+}
+
+// redactJSON redacts the values that match the JSONPath expressions. The order of the members is kept.
+// It returns the body as it is if nothing matches.
+func redactJSON(body []byte, paths [][]jsonPathSegment) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	w := &jsonRedactor{dec: dec, paths: paths}
+	if err := w.value(nil); err != nil || !w.redacted {
+		return body
+	}
+	return w.out.Bytes()
+}
+
+// jsonRedactor copies the JSON document token by token, and replaces the values that match the JSONPath expressions.
+type jsonRedactor struct {
+	// dec reads the JSON document
+	dec *json.Decoder
+	// out is the redacted JSON document
+	out bytes.Buffer
+	// paths are the JSONPath expressions of the redacted values
+	paths [][]jsonPathSegment
+	// redacted is true if any value is redacted
+	redacted bool
+}
+
+// value copies the value at the path.
+func (w *jsonRedactor) value(path []jsonPathElement) error {
+	for _, segments := range w.paths {
+		if matchJSONPath(segments, path) {
+			w.redacted = true
+			w.out.WriteString(strconv.Quote(redactedValue))
+			return w.skip()
+		}
+	}
+
+	tok, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return w.scalar(tok)
+	}
+
+	isObject := delim == '{'
+	w.out.WriteRune(rune(delim))
+	for i := 0; w.dec.More(); i++ {
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		element := jsonPathElement{index: i}
+		if isObject {
+			key, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			element = jsonPathElement{name: fmt.Sprint(key), index: -1}
+			if err := w.scalar(element.name); err != nil {
+				return err
+			}
+			w.out.WriteByte(':')
+		}
+		if err := w.value(append(path[:len(path):len(path)], element)); err != nil {
+			return err
+		}
+	}
+	end, err := w.dec.Token()
+	if err != nil {
+		return err
+	}
+	w.out.WriteRune(rune(end.(json.Delim)))
+	return nil
+}
+
+// scalar writes the string, number, boolean or null.
+func (w *jsonRedactor) scalar(tok json.Token) error {
+	if n, ok := tok.(json.Number); ok {
+		w.out.WriteString(n.String())
+		return nil
+	}
+	enc := json.NewEncoder(&w.out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(tok); err != nil {
+		return err
+	}
+	// Encode appends a newline.
+	w.out.Truncate(w.out.Len() - 1)
+	return nil
+}
+
+// skip skips the next value.
+func (w *jsonRedactor) skip() error {
+	depth := 0
+	for {
+		tok, err := w.dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
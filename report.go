@@ -2,8 +2,11 @@ package spectest
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
+
+	difflib "github.com/nao1215/diff"
 )
 
 type (
@@ -13,6 +16,14 @@ type (
 		Format(*Recorder)
 	}
 
+	// ReportFormatterWithError represents the report formatter that returns the error instead of panicking.
+	// The formatters of this package implement both ReportFormatter and ReportFormatterWithError.
+	ReportFormatterWithError interface {
+		// FormatWithError formats the events received from the recorder.
+		// It returns the error if the report can not be generated.
+		FormatWithError(*Recorder) error
+	}
+
 	// Event represents a reporting event
 	// e.g. HTTPRequest, HTTPResponse, MessageRequest, MessageResponse
 	Event interface {
@@ -29,6 +40,9 @@ type (
 		Meta *Meta
 		// Events is the list of events that occurred during the test
 		Events []Event
+		// AssertionResults is the list of the results of the assertions in the order they were made.
+		// They are not events, so the formatters that only know the events are not affected.
+		AssertionResults []AssertionResult
 	}
 
 	// MessageRequest represents a request interaction
@@ -64,6 +78,23 @@ type (
 		Value     *http.Response
 		Timestamp time.Time
 	}
+
+	// AssertionResult represents the result of an assertion of the test, e.g. the status code or a header.
+	// The results are recorded in Recorder.AssertionResults after the final response.
+	AssertionResult struct {
+		// Name is what the assertion checks. e.g. "status code", "header Content-Type"
+		Name string
+		// Passed is true if the assertion passed
+		Passed bool
+		// Expected is the expected value
+		Expected string
+		// Actual is the actual value
+		Actual string
+		// Message is the message of the failure
+		Message string
+		// Timestamp is the time of the assertion
+		Timestamp time.Time
+	}
 )
 
 // GetTime gets the time of the HTTPRequest interaction
@@ -78,6 +109,76 @@ func (r MessageRequest) GetTime() time.Time { return r.Timestamp }
 // GetTime gets the time of the MessageResponse interaction
 func (r MessageResponse) GetTime() time.Time { return r.Timestamp }
 
+// Diff returns the unified diff of the expected and the actual values.
+// It returns an empty string if the assertion passed or the values are the same.
+func (a AssertionResult) Diff() string {
+	if a.Passed || a.Expected == a.Actual {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a.Expected),
+		B:        difflib.SplitLines(a.Actual),
+		FromFile: "Expected",
+		ToFile:   "Actual",
+		Context:  2,
+	})
+	return diff
+}
+
+// AdaptReportFormatter adapts the ReportFormatter to ReportFormatterWithError.
+// The formatter is returned as it is if it implements ReportFormatterWithError.
+// Otherwise, the panic raised by Format is returned as the error.
+func AdaptReportFormatter(formatter ReportFormatter) ReportFormatterWithError {
+	if f, ok := formatter.(ReportFormatterWithError); ok {
+		return f
+	}
+	return &recoverFormatter{formatter: formatter}
+}
+
+// NewReportFormatter adapts the ReportFormatterWithError to ReportFormatter, so it can be passed to SpecTest.Report
+// and Scenario.Report. The error is sent to the report error handler of the test, and Format panics with the error
+// only if the formatter is used outside of the tests.
+func NewReportFormatter(formatter ReportFormatterWithError) ReportFormatter {
+	return &errorFormatter{formatter: formatter}
+}
+
+// recoverFormatter is the ReportFormatterWithError that recovers from the panic raised by the ReportFormatter.
+type recoverFormatter struct {
+	formatter ReportFormatter
+}
+
+// FormatWithError formats the events received from the recorder. The panic is returned as the error.
+func (r *recoverFormatter) FormatWithError(recorder *Recorder) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if e, ok := v.(error); ok {
+				err = fmt.Errorf("report formatter panicked: %w", e)
+				return
+			}
+			err = fmt.Errorf("report formatter panicked: %v", v)
+		}
+	}()
+	r.formatter.Format(recorder)
+	return nil
+}
+
+// errorFormatter is the ReportFormatter of the ReportFormatterWithError.
+type errorFormatter struct {
+	formatter ReportFormatterWithError
+}
+
+// Format formats the events received from the recorder. It panics if the report can not be generated.
+func (e *errorFormatter) Format(recorder *Recorder) {
+	if err := e.formatter.FormatWithError(recorder); err != nil {
+		panic(err)
+	}
+}
+
+// FormatWithError formats the events received from the recorder.
+func (e *errorFormatter) FormatWithError(recorder *Recorder) error {
+	return e.formatter.FormatWithError(recorder)
+}
+
 // NewTestRecorder creates a new TestRecorder
 func NewTestRecorder() *Recorder {
 	return &Recorder{}
@@ -107,6 +208,12 @@ func (r *Recorder) AddMessageResponse(m MessageResponse) *Recorder {
 	return r
 }
 
+// AddAssertionResult add an AssertionResult to the recorder
+func (r *Recorder) AddAssertionResult(a AssertionResult) *Recorder {
+	r.AssertionResults = append(r.AssertionResults, a)
+	return r
+}
+
 // AddTitle add a Title to the recorder
 func (r *Recorder) AddTitle(title string) *Recorder {
 	r.Title = title
@@ -146,5 +253,6 @@ func (r *Recorder) Reset() {
 	r.Title = ""
 	r.SubTitle = ""
 	r.Events = nil
+	r.AssertionResults = nil
 	r.Meta = nil
 }
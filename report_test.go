@@ -1,6 +1,7 @@
 package spectest
 
 import (
+	"errors"
 	"net/http"
 	"testing"
 )
@@ -42,6 +43,52 @@ func TestRecorderResponseStatusHandlesEventTypes(t *testing.T) {
 	assert.Equal(t, 2, len(rec.Events))
 }
 
+func TestRecorderAddAssertionResult(t *testing.T) {
+	rec := NewTestRecorder().
+		AddHTTPRequest(HTTPRequest{}).
+		AddHTTPResponse(HTTPResponse{Value: &http.Response{StatusCode: http.StatusCreated}}).
+		AddAssertionResult(AssertionResult{Name: "status code", Passed: true}).
+		AddAssertionResult(AssertionResult{Name: "body", Passed: false})
+
+	status, err := rec.ResponseStatus()
+	assert.Equal(t, true, err == nil)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, []AssertionResult{
+		{Name: "status code", Passed: true},
+		{Name: "body", Passed: false},
+	}, rec.AssertionResults)
+	assert.Equal(t, 2, len(rec.Events))
+}
+
+func TestAssertionResultDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		result AssertionResult
+		want   string
+	}{
+		{
+			name:   "passed",
+			result: AssertionResult{Passed: true, Expected: "200", Actual: "200"},
+			want:   "",
+		},
+		{
+			name:   "same values",
+			result: AssertionResult{Passed: false, Expected: "present", Actual: "present"},
+			want:   "",
+		},
+		{
+			name:   "failed",
+			result: AssertionResult{Passed: false, Expected: "{\n  \"a\": 1,\n  \"b\": 2\n}", Actual: "{\n  \"a\": 1,\n  \"b\": 3\n}"},
+			want:   "--- Expected\n+++ Actual\n@@ -1,4 +1,4 @@\n {\n   \"a\": 1,\n-  \"b\": 2\n+  \"b\": 3\n }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.result.Diff())
+		})
+	}
+}
+
 func TestRecorderAddsTitle(t *testing.T) {
 	rec := NewTestRecorder().
 		AddTitle("title")
@@ -66,3 +113,58 @@ func TestRecorderReset(t *testing.T) {
 	rec.Reset()
 	assert.Equal(t, &Recorder{}, rec)
 }
+
+// panicFormatter is a ReportFormatter that panics with the value
+type panicFormatter struct {
+	value interface{}
+}
+
+// Format panics with the value
+func (p panicFormatter) Format(*Recorder) {
+	panic(p.value)
+}
+
+// errFormatter is a ReportFormatterWithError that returns the error
+type errFormatter struct {
+	err error
+}
+
+// FormatWithError returns the error
+func (e errFormatter) FormatWithError(*Recorder) error {
+	return e.err
+}
+
+func TestAdaptReportFormatter(t *testing.T) {
+	t.Run("formatter with error is returned as it is", func(t *testing.T) {
+		formatter := &MarkdownFormatter{}
+		assert.Equal(t, true, AdaptReportFormatter(formatter) == ReportFormatterWithError(formatter))
+	})
+
+	t.Run("panic with error", func(t *testing.T) {
+		cause := errors.New("disk full")
+		err := AdaptReportFormatter(panicFormatter{value: cause}).FormatWithError(NewTestRecorder())
+		assert.Equal(t, "report formatter panicked: disk full", err.Error())
+		assert.Equal(t, true, errors.Is(err, cause))
+	})
+
+	t.Run("panic with string", func(t *testing.T) {
+		err := AdaptReportFormatter(panicFormatter{value: "received unknown event type"}).FormatWithError(NewTestRecorder())
+		assert.Equal(t, "report formatter panicked: received unknown event type", err.Error())
+	})
+
+	t.Run("no panic", func(t *testing.T) {
+		err := AdaptReportFormatter(&RecorderCaptor{}).FormatWithError(NewTestRecorder())
+		assert.Equal(t, true, err == nil)
+	})
+}
+
+func TestNewReportFormatter(t *testing.T) {
+	cause := errors.New("disk full")
+	formatter := NewReportFormatter(errFormatter{err: cause})
+	assert.Equal(t, cause, AdaptReportFormatter(formatter).FormatWithError(NewTestRecorder()))
+
+	defer func() {
+		assert.Equal(t, cause, recover())
+	}()
+	formatter.Format(NewTestRecorder())
+}
@@ -9,6 +9,7 @@ import (
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/nao1215/gorky/file"
 )
@@ -26,6 +27,9 @@ type Response struct {
 	cookiesNotPresent []string
 	assert            []Assert
 	goldenFile        *goldenFile
+	captures          []valueCapture
+	eventually        *eventually
+	attempts          int
 }
 
 func newResponse(s *SpecTest) *Response {
@@ -155,6 +159,22 @@ func (r *Response) Assert(fn func(*http.Response, *http.Request) error) *Respons
 	return r.specTest.response
 }
 
+// Eventually re-sends the request and re-runs all assertions (status, body, headers, cookies
+// and Assert functions) until they pass or the timeout expires. The interval is the time to wait
+// between attempts. On timeout, the failures of the last attempt are reported.
+// This is useful for endpoints that return the result of a background job.
+func (r *Response) Eventually(timeout, interval time.Duration) *Response {
+	r.eventually = newEventually(timeout, interval)
+	return r
+}
+
+// Capture extracts a value from the response after the assertions have been run and stores it under the given name.
+// In a Scenario, the value can be used by later steps with the "${name}" placeholder.
+func (r *Response) Capture(name string, extractor Extractor) *Response {
+	r.captures = append(r.captures, valueCapture{name: name, extractor: extractor})
+	return r
+}
+
 // End runs the test returning the result to the caller
 func (r *Response) End() Result {
 	defer func() {
@@ -183,21 +203,26 @@ func (r *Response) runTest() *http.Response {
 	specTest.interval.Start()
 	defer specTest.interval.End()
 
-	if specTest.mocks.len() > 0 {
-		specTest.transport = newTransport(
-			specTest.mocks,
-			specTest.httpClient,
-			specTest.debug,
-			specTest.mockResponseDelayEnabled,
-			specTest.mocksObservers,
-			r.specTest,
-		)
-		defer specTest.transport.Reset()
-		specTest.transport.Hijack()
+	mocks := specTest.mocks
+	useMocks := specTest.mocks.len() > 0 || specTest.cassette != nil
+	if specTest.cassette != nil {
+		replayed, err := specTest.cassette.begin()
+		if err != nil {
+			specTest.t.Fatal(err)
+		}
+		mocks = append(append(Mocks{}, specTest.mocks...), replayed...)
+		defer func() {
+			if err := specTest.cassette.save(); err != nil {
+				specTest.t.Fatal(err)
+			}
+		}()
 	}
-	res, req := specTest.doRequest()
-
+	var res *http.Response
+	var req *http.Request
 	defer func() {
+		if res == nil || req == nil {
+			return
+		}
 		if len(specTest.observers) > 0 {
 			for _, observe := range specTest.observers {
 				observe(res, req, specTest)
@@ -205,11 +230,74 @@ func (r *Response) runTest() *http.Response {
 		}
 	}()
 
+	// attempt sends the request with fresh mocks, so every attempt of Eventually can use them.
+	attempt := func() (*http.Response, *http.Request) {
+		if specTest.capture != nil {
+			specTest.capture.resetMockInteractions()
+		}
+		if useMocks {
+			defer r.startMocks(mocks)()
+		}
+		res, req := specTest.doRequest()
+		r.assertResult(res, req)
+		return res, req
+	}
+	if r.eventually != nil {
+		res, req, r.attempts = r.eventually.run(specTest, attempt)
+	} else {
+		res, req = attempt()
+	}
+	r.captureValues(res)
+	return copyHTTPResponse(res)
+}
+
+// startMocks marks the mocks as not invoked, and hijacks the transport with them.
+// It returns the function that restores the original transport.
+func (r *Response) startMocks(mocks Mocks) func() {
+	specTest := r.specTest
+	for _, mock := range mocks {
+		mock.m.Lock()
+		mock.state.Stop()
+		mock.m.Unlock()
+	}
+	specTest.transport = newTransport(
+		mocks,
+		specTest.httpClient,
+		specTest.debug,
+		specTest.mockResponseDelayEnabled,
+		specTest.mocksObservers,
+		specTest,
+	)
+	specTest.transport.isolated = specTest.mocksIsolated
+	specTest.transport.cassette = specTest.cassette
+	specTest.transport.Hijack()
+	return specTest.transport.Reset
+}
+
+// assertResult updates the golden file if needed and runs all the assertions.
+func (r *Response) assertResult(res *http.Response, req *http.Request) {
 	if err := r.updateGoldenFileIfNeeded(res); err != nil {
 		r.specTest.t.Fatal(err)
 	}
-	specTest.assertAll(res, req)
-	return copyHTTPResponse(res)
+	r.specTest.assertAll(res, req)
+}
+
+// captureValues runs the extractors registered by Capture and stores the values.
+func (r *Response) captureValues(res *http.Response) {
+	if len(r.captures) == 0 {
+		return
+	}
+	if r.specTest.vars == nil {
+		r.specTest.vars = map[string]string{}
+	}
+	for _, c := range r.captures {
+		value, err := c.extractor(copyHTTPResponse(res))
+		if err != nil {
+			r.specTest.t.Fatalf("failed to capture '%s': %s", c.name, err.Error())
+			return
+		}
+		r.specTest.vars[c.name] = value
+	}
 }
 
 // updateGoldenFileIfNeeded updates the golden file if needed.
@@ -230,6 +318,7 @@ func (s *SpecTest) assertAll(res *http.Response, req *http.Request) {
 	if s.verifier == nil {
 		s.verifier = DefaultVerifier{}
 	}
+	s.assertions = nil
 	s.assertMocks()
 	s.assertResponse(res)
 	s.assertHeaders(res)
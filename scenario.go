@@ -0,0 +1,393 @@
+package spectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nao1215/spectest/jsonpath/jsonpath"
+)
+
+// Scenario runs an ordered list of steps against the same http handler or network target.
+// Values captured from the response of a step can be used in the request of later steps
+// with the "${name}" placeholder. The whole flow is recorded in one Recorder, so the
+// report contains a single sequence diagram that shows every step.
+type Scenario struct {
+	// name is the name of the scenario. It will appear in the test report as title.
+	name string
+	// handler is the http handler that is invoked by every step
+	handler http.Handler
+	// network is used to enable/disable networking for every step
+	network *network
+	// debug is used to log the http wire representation of all http interactions
+	debug *debug
+	// redaction is the redaction policy of every step.
+	redaction *Redaction
+	// reporter is the report formatter.
+	reporter ReportFormatter
+	// reportErrorHandler handles the error of the report formatter. Default is t.Errorf of the last step.
+	reportErrorHandler func(err error)
+	// recorder is the scenario result recorder. It is shared by every step.
+	recorder *Recorder
+	// verifier is the assertion implementation. Default is DefaultVerifier.
+	verifier Verifier
+	// meta is the meta data for the test report.
+	meta *Meta
+	// interval is the time interval of the whole scenario.
+	interval *Interval
+	// steps is the ordered list of steps.
+	steps []Step
+	// vars holds the values captured by the steps.
+	vars map[string]string
+}
+
+// Step defines a single request and its expected response in a scenario.
+// The given SpecTest is already configured with the handler or network of the scenario.
+// The function must call Expect() and return the expected response.
+type Step func(*SpecTest) *Response
+
+// NewScenario creates a new scenario. The name is optional and will appear in test reports.
+// The name is only used name[0]. name[1]... are ignored.
+func NewScenario(name ...string) *Scenario {
+	scenario := &Scenario{
+		network:  newNetwork(),
+		debug:    newDebug(),
+		meta:     newMeta(),
+		interval: NewInterval(),
+		vars:     map[string]string{},
+	}
+	if len(name) > 0 {
+		scenario.name = name[0]
+	}
+	return scenario
+}
+
+// Handler defines the http handler that is invoked by every step
+func (sc *Scenario) Handler(handler http.Handler) *Scenario {
+	sc.handler = handler
+	return sc
+}
+
+// HandlerFunc defines the http handler that is invoked by every step
+func (sc *Scenario) HandlerFunc(handlerFunc http.HandlerFunc) *Scenario {
+	sc.handler = handlerFunc
+	return sc
+}
+
+// EnableNetworking will enable networking for every step.
+// If no clients are provided, the default http client will be used.
+// If multiple clients are provided, the first client will be used.
+func (sc *Scenario) EnableNetworking(clients ...*http.Client) *Scenario {
+	sc.network.enabled = true
+	if len(clients) == 1 {
+		sc.network.Client = clients[0]
+	}
+	return sc
+}
+
+// Debug logs to the console the http wire representation of all http interactions of every step.
+func (sc *Scenario) Debug() *Scenario {
+	sc.debug.enable()
+	return sc
+}
+
+// Redact sets the redaction policy of every step. See SpecTest.Redact.
+func (sc *Scenario) Redact(r *Redaction) *Scenario {
+	sc.redaction = r
+	sc.debug.redaction = r
+	return sc
+}
+
+// Report provides a hook to add custom formatting to the output of the scenario
+func (sc *Scenario) Report(reporter ReportFormatter) *Scenario {
+	sc.reporter = reporter
+	return sc
+}
+
+// OnReportError sets the handler of the error raised while the report is generated. See SpecTest.OnReportError.
+// By default, the error is reported by t.Errorf of the last step.
+func (sc *Scenario) OnReportError(handler func(err error)) *Scenario {
+	sc.reportErrorHandler = handler
+	return sc
+}
+
+// Recorder provides a hook to add a recorder to the scenario
+func (sc *Scenario) Recorder(recorder *Recorder) *Scenario {
+	sc.recorder = recorder
+	return sc
+}
+
+// Verifier allows consumers to override the verification implementation of every step.
+func (sc *Scenario) Verifier(v Verifier) *Scenario {
+	sc.verifier = v
+	return sc
+}
+
+// CustomHost set hostname.
+// This method is not change the host in the request. It is only for the report.
+func (sc *Scenario) CustomHost(host string) *Scenario {
+	sc.meta.Host = host
+	return sc
+}
+
+// CustomReportName allows the consumer to override the default report file name.
+func (sc *Scenario) CustomReportName(name string) *Scenario {
+	sc.meta.ReportFileName = name
+	return sc
+}
+
+// Var sets a variable that can be used by the "${name}" placeholder in every step.
+func (sc *Scenario) Var(name, value string) *Scenario {
+	sc.vars[name] = value
+	return sc
+}
+
+// Vars returns a copy of the variables that were set or captured so far.
+func (sc *Scenario) Vars() map[string]string {
+	vars := make(map[string]string, len(sc.vars))
+	for k, v := range sc.vars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// Step appends a step to the scenario. Steps are run in the order they were added.
+func (sc *Scenario) Step(steps ...Step) *Scenario {
+	sc.steps = append(sc.steps, steps...)
+	return sc
+}
+
+// End runs every step in order and returns the result of each step.
+// If the reporter is set, one report that contains all steps is generated.
+func (sc *Scenario) End() []Result {
+	defer func() {
+		sc.debug.duration(sc.interval)
+	}()
+
+	if sc.reporter == nil {
+		return sc.run(nil)
+	}
+
+	if sc.recorder == nil {
+		sc.recorder = NewTestRecorder()
+	}
+	defer sc.recorder.Reset()
+
+	specTests := []*SpecTest{}
+	results := sc.run(func(s *SpecTest) {
+		specTests = append(specTests, s)
+	})
+	sc.recordResult(specTests)
+	if err := AdaptReportFormatter(sc.reporter).FormatWithError(sc.recorder); err != nil {
+		sc.handleReportError(err, specTests)
+	}
+	return results
+}
+
+// handleReportError sends the error of the report to the report error handler or t.Errorf of the last step.
+// It panics with the error if the scenario has no step to report the error to.
+func (sc *Scenario) handleReportError(err error, specTests []*SpecTest) {
+	if sc.reportErrorHandler != nil {
+		sc.reportErrorHandler(err)
+		return
+	}
+	for i := len(specTests) - 1; i >= 0; i-- {
+		if specTests[i].t != nil {
+			specTests[i].handleReportError(err)
+			return
+		}
+	}
+	panic(err)
+}
+
+// run runs every step. The hook is called with the SpecTest of each step after it has been run.
+func (sc *Scenario) run(hook func(*SpecTest)) []Result {
+	sc.interval.Start()
+	defer sc.interval.End()
+
+	results := make([]Result, 0, len(sc.steps))
+	for _, step := range sc.steps {
+		specTest := sc.newSpecTest()
+		step(specTest)
+		specTest.assertValidHandlerOrNetwork()
+
+		var capture *capture
+		if sc.reporter != nil {
+			capture = newCapture()
+			specTest.capture = capture
+			specTest.recorder = sc.recorder
+			specTest.observers = capture.appendObserver(specTest.observers)
+			specTest.mocksObservers = capture.appendMockObservers(specTest.mocksObservers)
+		}
+
+		res := specTest.response.runTest()
+		if capture != nil {
+			if capture.err != nil {
+				specTest.handleReportError(capture.err)
+			}
+			capture.redact(specTest.redactionPolicy())
+			specTest.recordResult(capture)
+			specTest.meta = specTest.newMeta(capture)
+		}
+		if hook != nil {
+			hook(specTest)
+		}
+		results = append(results, Result{
+			Response:       res,
+			unmatchedMocks: specTest.mocks.findUnmatchedMocks(),
+		})
+	}
+	return results
+}
+
+// newSpecTest creates a SpecTest that shares the configuration of the scenario.
+func (sc *Scenario) newSpecTest() *SpecTest {
+	specTest := New(sc.name)
+	specTest.handler = sc.handler
+	specTest.network = sc.network
+	specTest.debug = sc.debug
+	specTest.redaction = sc.redaction
+	specTest.reportErrorHandler = sc.reportErrorHandler
+	specTest.verifier = sc.verifier
+	specTest.vars = sc.vars
+	return specTest
+}
+
+// recordResult sets the title, sub title and meta data of the whole scenario to the recorder.
+func (sc *Scenario) recordResult(specTests []*SpecTest) {
+	titles := make([]string, 0, len(specTests))
+	for _, s := range specTests {
+		titles = append(titles, fmt.Sprintf("%s %s", s.meta.Method, s.meta.Path))
+	}
+
+	title := sc.name
+	if title == "" {
+		title = strings.Join(titles, " -> ")
+	}
+	sc.recorder.AddTitle(title).AddSubTitle(strings.Join(titles, " -> "))
+
+	meta := newMeta()
+	passed := true
+	for _, s := range specTests {
+		if s.meta.Passed != nil && !*s.meta.Passed {
+			passed = false
+		}
+	}
+	meta.Passed = &passed
+	for _, s := range specTests {
+		step := *s.meta
+		step.ReportFileName = ""
+		meta.Steps = append(meta.Steps, step)
+	}
+	meta.Duration = sc.interval.Duration().Nanoseconds()
+	meta.Name = sc.name
+	meta.ReportFileName = sc.meta.ReportFileName
+	if meta.ReportFileName == "" && len(specTests) > 0 {
+		// The scenario has no method and path of its own, so the file name is the hash of the first step.
+		meta.ReportFileName = meta.Steps[0].reportFileName()
+	}
+	if sc.meta.Host != "" {
+		meta.Host = sc.meta.Host
+	}
+	sc.recorder.AddMeta(meta)
+}
+
+// Extractor extracts a value from the http response.
+// It is used by Response.Capture to carry values from one step into the next.
+type Extractor func(*http.Response) (string, error)
+
+// FromJSONPath returns an Extractor that evaluates the jsonpath expression against the response body.
+func FromJSONPath(expression string) Extractor {
+	return func(res *http.Response) (string, error) {
+		if res.Body == nil {
+			return "", fmt.Errorf("no body to evaluate '%s'", expression)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		res.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		value, err := jsonpath.JSONPath(bytes.NewReader(body), expression)
+		if err != nil {
+			return "", err
+		}
+		if value == nil {
+			return "", fmt.Errorf("no value found for '%s'", expression)
+		}
+		return formatJSONValue(value)
+	}
+}
+
+// formatJSONValue returns the decoded JSON value as the string used in the next steps.
+// Numbers are not written in the exponent form, and objects and arrays are written as JSON.
+func formatJSONValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// FromHeader returns an Extractor that reads the first value of the response header.
+func FromHeader(name string) Extractor {
+	return func(res *http.Response) (string, error) {
+		values := res.Header.Values(name)
+		if len(values) == 0 {
+			return "", fmt.Errorf("header '%s' not present in response", name)
+		}
+		return values[0], nil
+	}
+}
+
+// FromCookie returns an Extractor that reads the value of the response cookie.
+func FromCookie(name string) Extractor {
+	return func(res *http.Response) (string, error) {
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == name {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie '%s' not present in response", name)
+	}
+}
+
+// valueCapture is a named Extractor registered by Response.Capture.
+type valueCapture struct {
+	// name is the variable name the value is stored under
+	name string
+	// extractor extracts the value from the response
+	extractor Extractor
+}
+
+// placeholderRegexp matches the "${name}" placeholder.
+var placeholderRegexp = regexp.MustCompile(`\$\{([A-Za-z0-9_.\-]+)\}`)
+
+// expandVars replaces the "${name}" placeholders with the value of the variable.
+// Placeholders of unknown variables are left as they are.
+func expandVars(in string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(in, "${") {
+		return in
+	}
+	return placeholderRegexp.ReplaceAllStringFunc(in, func(placeholder string) string {
+		name := placeholderRegexp.FindStringSubmatch(placeholder)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,306 @@
+package spectest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+)
+
+// orderAPI is a small stateful handler used by the scenario tests.
+func orderAPI(t *testing.T) http.Handler {
+	t.Helper()
+
+	orders := map[string]string{}
+	handler := http.NewServeMux()
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s-123"})
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token": "abc"}`))
+	})
+	handler.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Item string `json:"item"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id := fmt.Sprintf("%d", len(orders)+1)
+		orders[id] = body.Item
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"id": %s}`, id)))
+	})
+	handler.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s-123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/orders/")
+		item, ok := orders[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"id": %s, "item": %q, "request_id": %q}`, id, item, r.URL.Query().Get("rid"))))
+	})
+	return handler
+}
+
+func TestScenario(t *testing.T) {
+	t.Run("carry values from one response into the next request", func(t *testing.T) {
+		scenario := spectest.NewScenario("order flow").Handler(orderAPI(t))
+		results := scenario.
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/login").
+					Expect(t).
+					Status(http.StatusOK).
+					Capture("token", spectest.FromJSONPath("$.token")).
+					Capture("session", spectest.FromCookie("session")).
+					Capture("requestID", spectest.FromHeader("X-Request-Id"))
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/orders").
+					Header("Authorization", "Bearer ${token}").
+					JSON(`{"item": "coffee"}`).
+					Expect(t).
+					Status(http.StatusCreated).
+					Capture("orderID", spectest.FromJSONPath("$.id"))
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Get("/orders/${orderID}").
+					Query("rid", "${requestID}").
+					Cookie("session", "${session}").
+					Expect(t).
+					Status(http.StatusOK).
+					Body(`{"id": 1, "item": "coffee", "request_id": "req-1"}`)
+			}).
+			End()
+
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(results))
+		}
+		vars := scenario.Vars()
+		spectest.DefaultVerifier{}.Equal(t, "abc", vars["token"])
+		spectest.DefaultVerifier{}.Equal(t, "s-123", vars["session"])
+		spectest.DefaultVerifier{}.Equal(t, "req-1", vars["requestID"])
+		spectest.DefaultVerifier{}.Equal(t, "1", vars["orderID"])
+	})
+
+	t.Run("one recorder for every step", func(t *testing.T) {
+		reporter := &RecorderCaptor{}
+
+		spectest.NewScenario("order flow").
+			Handler(orderAPI(t)).
+			CustomHost("abc.com").
+			Report(reporter).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/login").
+					Expect(t).
+					Status(http.StatusOK).
+					Capture("token", spectest.FromJSONPath("$.token"))
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/orders").
+					Header("Authorization", "Bearer ${token}").
+					JSON(`{"item": "tea"}`).
+					Expect(t).
+					Status(http.StatusCreated)
+			}).
+			End()
+
+		r := reporter.capturedRecorder
+		spectest.DefaultVerifier{}.Equal(t, "order flow", r.Title)
+		spectest.DefaultVerifier{}.Equal(t, "POST /login -> POST /orders", r.SubTitle)
+		spectest.DefaultVerifier{}.Equal(t, 4, len(r.Events))
+		spectest.DefaultVerifier{}.Equal(t, 2, len(r.AssertionResults))
+		spectest.DefaultVerifier{}.Equal(t, true, *r.Meta.Passed)
+		// The scenario has no single request, so the method, the path and the status code are in the steps.
+		spectest.DefaultVerifier{}.Equal(t, "", r.Meta.Method)
+		spectest.DefaultVerifier{}.Equal(t, 0, r.Meta.StatusCode)
+		spectest.DefaultVerifier{}.Equal(t, 2, len(r.Meta.Steps))
+		spectest.DefaultVerifier{}.Equal(t, http.MethodPost, r.Meta.Steps[0].Method)
+		spectest.DefaultVerifier{}.Equal(t, "/login", r.Meta.Steps[0].Path)
+		spectest.DefaultVerifier{}.Equal(t, http.StatusOK, r.Meta.Steps[0].StatusCode)
+		spectest.DefaultVerifier{}.Equal(t, "/orders", r.Meta.Steps[1].Path)
+		spectest.DefaultVerifier{}.Equal(t, http.StatusCreated, r.Meta.Steps[1].StatusCode)
+		spectest.DefaultVerifier{}.Equal(t, "abc.com", r.Meta.Host)
+		spectest.DefaultVerifier{}.Equal(t, "order flow", r.Meta.Name)
+
+		status, err := r.ResponseStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, http.StatusCreated, status)
+	})
+
+	t.Run("redact every step", func(t *testing.T) {
+		reporter := &RecorderCaptor{}
+
+		scenario := spectest.NewScenario("order flow").
+			Handler(orderAPI(t)).
+			Redact(spectest.StandardRedaction().JSONPaths("$.token")).
+			Report(reporter).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/login").
+					Expect(t).
+					Status(http.StatusOK).
+					Capture("token", spectest.FromJSONPath("$.token"))
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/orders").
+					Header("Authorization", "Bearer ${token}").
+					JSON(`{"item": "tea"}`).
+					Expect(t).
+					Status(http.StatusCreated)
+			})
+		scenario.End()
+
+		// The steps use the real values, and the recorder has the redacted values.
+		spectest.DefaultVerifier{}.Equal(t, "abc", scenario.Vars()["token"])
+		for _, event := range reporter.capturedRecorder.Events {
+			var dump string
+			switch e := event.(type) {
+			case spectest.HTTPRequest:
+				entry, err := spectest.NewHTTPRequestLogEntry(e.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dump = entry.Header + entry.Body
+			case spectest.HTTPResponse:
+				entry, err := spectest.NewHTTPResponseLogEntry(e.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dump = entry.Header + entry.Body
+			}
+			if strings.Contains(dump, "abc") || strings.Contains(dump, "s-123") {
+				t.Errorf("the recorder has the redacted values:\n%s", dump)
+			}
+		}
+	})
+
+	t.Run("failed if a step fails", func(t *testing.T) {
+		reporter := &RecorderCaptor{}
+		captor := &failureCaptorT{}
+
+		spectest.NewScenario("order flow").
+			Handler(orderAPI(t)).
+			Report(reporter).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Post("/login").
+					Expect(captor).
+					Status(http.StatusAccepted)
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Get("/health").
+					Expect(captor).
+					Status(http.StatusNotFound)
+			}).
+			End()
+
+		r := reporter.capturedRecorder
+		spectest.DefaultVerifier{}.Equal(t, 1, len(captor.messages))
+		spectest.DefaultVerifier{}.Equal(t, false, *r.Meta.Passed)
+		results := r.AssertionResults
+		spectest.DefaultVerifier{}.Equal(t, 2, len(results))
+		spectest.DefaultVerifier{}.Equal(t, false, results[0].Passed)
+		spectest.DefaultVerifier{}.Equal(t, true, results[1].Passed)
+	})
+
+	t.Run("report error", func(t *testing.T) {
+		var reportErr error
+		captor := &failureCaptorT{}
+		step := func(s *spectest.SpecTest) *spectest.Response {
+			return s.Post("/login").
+				Expect(captor).
+				Status(http.StatusOK)
+		}
+
+		spectest.NewScenario("order flow").
+			Handler(orderAPI(t)).
+			Report(spectest.NewReportFormatter(errReportFormatter{})).
+			OnReportError(func(err error) {
+				reportErr = err
+			}).
+			Step(step).
+			End()
+		spectest.DefaultVerifier{}.Equal(t, "failed to upload the report", reportErr.Error())
+		spectest.DefaultVerifier{}.Equal(t, 0, len(captor.messages))
+
+		// The error is reported by t.Errorf of the last step by default.
+		spectest.NewScenario("order flow").
+			Handler(orderAPI(t)).
+			Report(spectest.NewReportFormatter(errReportFormatter{})).
+			Step(step).
+			End()
+		spectest.DefaultVerifier{}.Equal(t, []string{"failed to generate the report: failed to upload the report"}, captor.messages)
+	})
+
+	t.Run("unknown placeholder is left as it is", func(t *testing.T) {
+		spectest.NewScenario().
+			Var("known", "value").
+			HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Header.Get("X-Value")))
+			}).
+			Step(func(s *spectest.SpecTest) *spectest.Response {
+				return s.Get("/").
+					Header("X-Value", "${known}-${unknown}").
+					Expect(t).
+					Body("value-${unknown}")
+			}).
+			End()
+	})
+}
+
+func TestExtractor(t *testing.T) {
+	res := &http.Response{Header: http.Header{}}
+
+	t.Run("header is not present", func(t *testing.T) {
+		if _, err := spectest.FromHeader("X-Missing")(res); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("cookie is not present", func(t *testing.T) {
+		if _, err := spectest.FromCookie("missing")(res); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("no body", func(t *testing.T) {
+		if _, err := spectest.FromJSONPath("$.id")(res); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("json values", func(t *testing.T) {
+		body := `{"id": 1234567, "price": 12.5, "active": true, "name": "tea", "tags": ["a", "b"], "owner": {"id": 1}}`
+		for expression, want := range map[string]string{
+			"$.id":     "1234567",
+			"$.price":  "12.5",
+			"$.active": "true",
+			"$.name":   "tea",
+			"$.tags":   `["a","b"]`,
+			"$.owner":  `{"id":1}`,
+		} {
+			res := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+			got, err := spectest.FromJSONPath(expression)(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spectest.DefaultVerifier{}.Equal(t, want, got)
+		}
+	})
+}
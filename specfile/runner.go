@@ -0,0 +1,284 @@
+package specfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/jsonpath"
+	"github.com/nao1215/spectest/jsonschema"
+)
+
+// Runner runs the specs of the spec files with spectest.
+// The specs are sent to the http.Handler, or to the base url in networking mode.
+type Runner struct {
+	// handler is the system under test that runs in-process
+	handler http.Handler
+	// baseURL is the url of the system under test in networking mode
+	baseURL string
+	// client is the http client used in networking mode
+	client *http.Client
+	// reporter is the report formatter
+	reporter spectest.ReportFormatter
+}
+
+// NewRunner creates a new Runner.
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Handler sets the http.Handler that receives the requests of the specs.
+func (r *Runner) Handler(handler http.Handler) *Runner {
+	r.handler = handler
+	return r
+}
+
+// BaseURL enables networking mode. The url of each spec is joined to the base url. e.g. http://localhost:8080
+func (r *Runner) BaseURL(baseURL string) *Runner {
+	r.baseURL = strings.TrimSuffix(baseURL, "/")
+	return r
+}
+
+// HTTPClient sets the http client used in networking mode. By default, http.DefaultClient is used.
+func (r *Runner) HTTPClient(client *http.Client) *Runner {
+	r.client = client
+	return r
+}
+
+// Report sets the report formatter of the specs.
+func (r *Runner) Report(reporter spectest.ReportFormatter) *Runner {
+	r.reporter = reporter
+	return r
+}
+
+// Test loads the spec files in the path, and runs each spec as a subtest of t.
+func (r *Runner) Test(t *testing.T, path string) {
+	t.Helper()
+
+	files, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		f := f
+		t.Run(f.Name, func(t *testing.T) {
+			for _, spec := range f.Specs {
+				spec := spec
+				t.Run(spec.Name, func(t *testing.T) {
+					r.Run(t, spec)
+				})
+			}
+		})
+	}
+}
+
+// Run runs the spec. The failures are reported to t.
+func (r *Runner) Run(t spectest.TestingT, spec Spec) spectest.Result {
+	st := spectest.New(spec.Name)
+	if r.reporter != nil {
+		st.Report(r.reporter)
+	}
+
+	if r.handler == nil && r.baseURL == "" {
+		t.Fatal("specfile: the handler or the base url is required")
+	}
+
+	url := spec.Request.URL
+	if r.baseURL != "" {
+		if r.client != nil {
+			st.EnableNetworking(r.client)
+		} else {
+			st.EnableNetworking()
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = r.baseURL + "/" + strings.TrimPrefix(url, "/")
+		}
+	} else {
+		st.Handler(r.handler)
+	}
+
+	req := st.Method(strings.ToUpper(spec.Request.Method)).URL(url)
+	r.buildRequest(t, req, spec.Request)
+
+	res := req.Expect(t)
+	r.buildExpect(t, res, spec)
+	return res.End()
+}
+
+// buildRequest sets the request of the spec to the builder.
+func (r *Runner) buildRequest(t spectest.TestingT, req *spectest.Request, spec Request) {
+	if len(spec.Headers) > 0 {
+		req.Headers(spec.Headers)
+	}
+	if len(spec.Query) > 0 {
+		req.QueryParams(spec.Query)
+	}
+	for name, value := range spec.Cookies {
+		req.Cookie(name, value)
+	}
+	if spec.BasicAuth != "" {
+		username, password, _ := strings.Cut(spec.BasicAuth, ":")
+		req.BasicAuth(username, password)
+	}
+	switch {
+	case spec.Body != "":
+		req.Body(spec.Body)
+	case spec.JSON != nil:
+		body, err := json.Marshal(spec.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.JSON(string(body))
+	case len(spec.Form) > 0:
+		for name, value := range spec.Form {
+			req.FormData(name, value)
+		}
+	}
+}
+
+// buildExpect sets the expectation of the spec to the builder.
+func (r *Runner) buildExpect(t spectest.TestingT, res *spectest.Response, spec Spec) {
+	expect := spec.Expect
+	if expect.Status != 0 {
+		res.Status(expect.Status)
+	}
+	switch {
+	case expect.Body != "":
+		res.Body(expect.Body)
+	case expect.JSON != nil:
+		body, err := json.Marshal(expect.JSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body(string(body))
+	}
+	if len(expect.Headers) > 0 {
+		res.Headers(expect.Headers)
+	}
+	for _, name := range expect.HeadersPresent {
+		res.HeaderPresent(name)
+	}
+	for _, name := range expect.HeadersNotPresent {
+		res.HeaderNotPresent(name)
+	}
+	for name, value := range expect.Cookies {
+		res.Cookie(name, value)
+	}
+	for _, name := range expect.CookiesPresent {
+		res.CookiePresent(name)
+	}
+	for _, name := range expect.CookiesNotPresent {
+		res.CookieNotPresent(name)
+	}
+
+	for _, jp := range expect.JSONPath {
+		asserts, err := jsonPathAsserts(jp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, assert := range asserts {
+			res.Assert(assert)
+		}
+	}
+
+	if expect.JSONSchema != nil {
+		schema, err := jsonSchema(spec.dir, expect.JSONSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Assert(jsonschema.Validate(schema))
+	}
+}
+
+// jsonPathAsserts converts the JSONPath assertion to the jsonpath asserts.
+func jsonPathAsserts(jp JSONPath) ([]spectest.Assert, error) {
+	asserts := []spectest.Assert{}
+	if jp.Equal != nil {
+		expected, err := jsonValue(jp.Equal)
+		if err != nil {
+			return nil, err
+		}
+		asserts = append(asserts, jsonpath.Equal(jp.Path, expected))
+	}
+	if jp.NotEqual != nil {
+		expected, err := jsonValue(jp.NotEqual)
+		if err != nil {
+			return nil, err
+		}
+		asserts = append(asserts, jsonpath.NotEqual(jp.Path, expected))
+	}
+	if jp.Contains != nil {
+		expected, err := jsonValue(jp.Contains)
+		if err != nil {
+			return nil, err
+		}
+		asserts = append(asserts, jsonpath.Contains(jp.Path, expected))
+	}
+	if jp.Len != nil {
+		asserts = append(asserts, jsonpath.Len(jp.Path, *jp.Len))
+	}
+	if jp.GreaterThan != nil {
+		asserts = append(asserts, jsonpath.GreaterThan(jp.Path, *jp.GreaterThan))
+	}
+	if jp.LessThan != nil {
+		asserts = append(asserts, jsonpath.LessThan(jp.Path, *jp.LessThan))
+	}
+	if jp.Present != nil {
+		if *jp.Present {
+			asserts = append(asserts, jsonpath.Present(jp.Path))
+		} else {
+			asserts = append(asserts, jsonpath.NotPresent(jp.Path))
+		}
+	}
+	if jp.Matches != "" {
+		asserts = append(asserts, jsonpath.Matches(jp.Path, jp.Matches))
+	}
+	if len(asserts) == 0 {
+		return nil, fmt.Errorf("jsonpath '%s' has no assertion", jp.Path)
+	}
+	return asserts, nil
+}
+
+// jsonValue converts the value decoded from YAML to the value decoded from JSON,
+// so it can be compared with the value selected by the JSONPath expression. e.g. int to float64
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var converted interface{}
+	if err := json.Unmarshal(data, &converted); err != nil {
+		return nil, err
+	}
+	return converted, nil
+}
+
+// jsonSchema returns the JSON Schema. A string is the path of the schema file relative to dir.
+func jsonSchema(dir string, schema interface{}) (string, error) {
+	if path, ok := schema.(string); ok {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Run loads the spec files in the path, and runs each spec against the handler as a subtest of t.
+func Run(t *testing.T, path string, handler http.Handler) {
+	t.Helper()
+	NewRunner().Handler(handler).Test(t, path)
+}
@@ -0,0 +1,222 @@
+// Package specfile runs declarative spec files written in YAML or JSON with spectest.
+// The format mirrors the builder API, so API regression cases can be added without writing Go.
+package specfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a spec file. It has a list of specs.
+type File struct {
+	// Name is the name of the spec file. The file name without the extension is used if it is empty.
+	Name string `yaml:"name" json:"name"`
+	// Specs is the list of specs in the file.
+	Specs []Spec `yaml:"specs" json:"specs"`
+	// Path is the path of the spec file.
+	Path string `yaml:"-" json:"-"`
+}
+
+// Spec is a single API test. It mirrors spectest.New().Method().Expect().End().
+type Spec struct {
+	// Name is the name of the spec. It appears in the result and the report.
+	Name string `yaml:"name" json:"name"`
+	// Request is the request sent to the system under test.
+	Request Request `yaml:"request" json:"request"`
+	// Expect is the expectation of the response.
+	Expect Expect `yaml:"expect" json:"expect"`
+
+	// dir is the directory of the spec file. Relative paths in the spec are resolved from it.
+	dir string
+}
+
+// Request is the request of the spec.
+type Request struct {
+	// Method is the http method. e.g. GET
+	Method string `yaml:"method" json:"method"`
+	// URL is the url of the request. It is joined to the base url when the spec runs in networking mode.
+	URL string `yaml:"url" json:"url"`
+	// Headers is the request headers.
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	// Query is the query parameters.
+	Query map[string]string `yaml:"query" json:"query"`
+	// Cookies is the request cookies.
+	Cookies map[string]string `yaml:"cookies" json:"cookies"`
+	// BasicAuth is the basic auth credentials in the "username:password" format.
+	BasicAuth string `yaml:"basic_auth" json:"basic_auth"`
+	// Body is the raw request body.
+	Body string `yaml:"body" json:"body"`
+	// JSON is the request body that is sent as JSON with the "application/json" content type.
+	JSON interface{} `yaml:"json" json:"json"`
+	// Form is the url encoded form body.
+	Form map[string]string `yaml:"form" json:"form"`
+}
+
+// Expect is the expectation of the response of the spec.
+type Expect struct {
+	// Status is the expected status code. It is not checked if it is zero.
+	Status int `yaml:"status" json:"status"`
+	// Body is the expected body. If it is a JSON, it is compared as a JSON.
+	Body string `yaml:"body" json:"body"`
+	// JSON is the expected body written as an object. It is compared as a JSON.
+	JSON interface{} `yaml:"json" json:"json"`
+	// Headers is the expected response headers.
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	// HeadersPresent is the list of headers that must be present.
+	HeadersPresent []string `yaml:"headers_present" json:"headers_present"`
+	// HeadersNotPresent is the list of headers that must not be present.
+	HeadersNotPresent []string `yaml:"headers_not_present" json:"headers_not_present"`
+	// Cookies is the expected response cookies.
+	Cookies map[string]string `yaml:"cookies" json:"cookies"`
+	// CookiesPresent is the list of cookies that must be present.
+	CookiesPresent []string `yaml:"cookies_present" json:"cookies_present"`
+	// CookiesNotPresent is the list of cookies that must not be present.
+	CookiesNotPresent []string `yaml:"cookies_not_present" json:"cookies_not_present"`
+	// JSONPath is the list of JSONPath assertions.
+	JSONPath []JSONPath `yaml:"jsonpath" json:"jsonpath"`
+	// JSONSchema is the JSON Schema that the response body must conform to.
+	// It is a path of the schema file relative to the spec file, or the schema written as an object.
+	JSONSchema interface{} `yaml:"json_schema" json:"json_schema"`
+}
+
+// JSONPath is an assertion of the value selected by the JSONPath expression.
+// Only the fields that are set are asserted.
+type JSONPath struct {
+	// Path is the JSONPath expression. e.g. $.name
+	Path string `yaml:"path" json:"path"`
+	// Equal asserts that the value is equal to it.
+	Equal interface{} `yaml:"equal" json:"equal"`
+	// NotEqual asserts that the value is not equal to it.
+	NotEqual interface{} `yaml:"not_equal" json:"not_equal"`
+	// Contains asserts that the array contains it.
+	Contains interface{} `yaml:"contains" json:"contains"`
+	// Len asserts the length of the value.
+	Len *int `yaml:"len" json:"len"`
+	// GreaterThan asserts that the length of the value is greater than it.
+	GreaterThan *int `yaml:"greater_than" json:"greater_than"`
+	// LessThan asserts that the length of the value is less than it.
+	LessThan *int `yaml:"less_than" json:"less_than"`
+	// Present asserts that the value is present (true) or not present (false).
+	Present *bool `yaml:"present" json:"present"`
+	// Matches asserts that the value matches the regular expression.
+	Matches string `yaml:"matches" json:"matches"`
+}
+
+// Load reads the spec file, or every spec file (*.yaml, *.yml and *.json) under the directory.
+// The files are sorted by the path.
+func Load(path string) ([]*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		f, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*File{f}, nil
+	}
+
+	paths := []string{}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isSpecFile(p) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	files := make([]*File, 0, len(paths))
+	for _, p := range paths {
+		f, err := LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// LoadFile reads the spec file. JSON is read as YAML, and unknown fields are reported as errors.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse spec file %s: %w", path, err)
+	}
+
+	f.Path = path
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i := range f.Specs {
+		spec := &f.Specs[i]
+		spec.dir = filepath.Dir(path)
+		if spec.Name == "" {
+			spec.Name = fmt.Sprintf("%s %s", strings.ToUpper(spec.Request.Method), spec.Request.URL)
+		}
+		if err := spec.validate(); err != nil {
+			return nil, fmt.Errorf("invalid spec '%s' in %s: %w", spec.Name, path, err)
+		}
+	}
+	return f, nil
+}
+
+// validate returns an error if the spec can not be run.
+func (s *Spec) validate() error {
+	if s.Request.Method == "" {
+		return errors.New("request.method is required")
+	}
+	if s.Request.URL == "" {
+		return errors.New("request.url is required")
+	}
+	bodies := 0
+	for _, set := range []bool{s.Request.Body != "", s.Request.JSON != nil, len(s.Request.Form) > 0} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return errors.New("only one of request.body, request.json and request.form can be set")
+	}
+	if s.Expect.Body != "" && s.Expect.JSON != nil {
+		return errors.New("only one of expect.body and expect.json can be set")
+	}
+	for _, jp := range s.Expect.JSONPath {
+		if jp.Path == "" {
+			return errors.New("expect.jsonpath[].path is required")
+		}
+	}
+	return nil
+}
+
+// isSpecFile returns true if the file is a spec file.
+func isSpecFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
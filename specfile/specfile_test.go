@@ -0,0 +1,146 @@
+package specfile_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+	"github.com/nao1215/spectest/specfile"
+)
+
+// usersHandler returns the handler of the users api used by the spec files in testdata.
+func usersHandler() http.Handler {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fields") != "name,tags" || r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "visited", Value: "true"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "1234")
+		_, _ = w.Write([]byte(`{"id": 1, "name": "Tom", "tags": ["cat"]}`))
+	})
+	handler.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 2, "name": "Jerry"}`))
+	})
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("username") != "tom" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "token", Value: "xyz"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return handler
+}
+
+// recordingT is a spectest.TestingT that records the failures.
+type recordingT struct {
+	failures []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatal(args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprint(args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRun(t *testing.T) {
+	t.Run("run the spec files against the handler", func(t *testing.T) {
+		specfile.Run(t, filepath.Join("testdata", "specs"), usersHandler())
+	})
+
+	t.Run("run the spec files in networking mode", func(t *testing.T) {
+		server := httptest.NewServer(usersHandler())
+		defer server.Close()
+
+		specfile.NewRunner().
+			BaseURL(server.URL+"/").
+			Test(t, filepath.Join("testdata", "specs", "users.yaml"))
+	})
+
+	t.Run("report the failure of the spec", func(t *testing.T) {
+		files, err := specfile.Load(filepath.Join("testdata", "specs", "users.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec := files[0].Specs[0]
+		spec.Expect.Status = http.StatusNotFound
+
+		recorder := &recordingT{}
+		specfile.NewRunner().Handler(usersHandler()).Run(recorder, spec)
+		if len(recorder.failures) != 1 || !strings.Contains(recorder.failures[0], "Status code 200 not equal to 404") {
+			t.Errorf("unexpected failures: %v", recorder.failures)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("load the spec files in the directory", func(t *testing.T) {
+		files, err := specfile.Load(filepath.Join("testdata", "specs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		spectest.DefaultVerifier{}.Equal(t, 2, len(files))
+		spectest.DefaultVerifier{}.Equal(t, "login", files[0].Name)
+		spectest.DefaultVerifier{}.Equal(t, "login with form", files[0].Specs[0].Name)
+		spectest.DefaultVerifier{}.Equal(t, "users", files[1].Name)
+		spectest.DefaultVerifier{}.Equal(t, 2, len(files[1].Specs))
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "unknown field",
+			content: "specs:\n  - request:\n      method: GET\n      url: /\n    expect:\n      stats: 200\n",
+			wantErr: "field stats not found",
+		},
+		{
+			name:    "url is required",
+			content: "specs:\n  - request:\n      method: GET\n",
+			wantErr: "request.url is required",
+		},
+		{
+			name:    "only one body",
+			content: "specs:\n  - request:\n      method: POST\n      url: /\n      body: a\n      json: {a: 1}\n",
+			wantErr: "only one of request.body, request.json and request.form can be set",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spec.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := specfile.Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	runtimeDebug "runtime/debug"
 	"sort"
 	"strings"
+	"time"
 )
 
 // SpecTest is the top level struct holding the test spec
@@ -21,10 +22,14 @@ type SpecTest struct {
 	debug *debug
 	// mockResponseDelayEnabled will turn on mock response delays (defaults to OFF)
 	mockResponseDelayEnabled bool
+	// mocksIsolated scopes the mocks to this test instead of replacing http.DefaultTransport (defaults to OFF)
+	mocksIsolated bool
 	// network is used to enable/disable networking for the test
 	network *network
 	// reporter is the report formatter.
 	reporter ReportFormatter
+	// reportErrorHandler handles the error of the report formatter. Default is t.Errorf.
+	reportErrorHandler func(err error)
 	// verifier is the assertion implementation. Default is DefaultVerifier.
 	verifier Verifier
 	// recorder is the test result recorder.
@@ -45,6 +50,8 @@ type SpecTest struct {
 	mocksObservers []Observe
 	// mocks is a list of mocks that will be used to intercept the request.
 	mocks Mocks
+	// cassette records or replays the outbound requests that do not match any mock.
+	cassette *Cassette
 	// t is the testing.T instance.
 	t TestingT
 	// httpClient is the http client used when networking is enabled
@@ -53,10 +60,23 @@ type SpecTest struct {
 	httpRequest *http.Request
 	// transport is the http transport used when networking is enabled
 	transport *Transport
+	// capture captures the interactions of the test for the report. It is nil if no report is generated.
+	capture *capture
+	// curl is the curl command that reproduces the last request. It is shown in the failure message.
+	curl string
+	// curlBaseURL is the base url of the curl command of the request served by the http handler.
+	curlBaseURL string
+	// redaction is the redaction policy of the reports, the debug output and the cassettes.
+	redaction *Redaction
+	// assertions are the results of the assertions of the last attempt. They are shown in the report.
+	assertions []AssertionResult
 	// meta is the meta data for the test report.
 	meta *Meta
 	// interval is the time interval for the test report.
 	interval *Interval
+	// vars holds the values that replace the "${name}" placeholders in the request.
+	// It is shared by every step of a Scenario.
+	vars map[string]string
 }
 
 // Observe will be called by with the request and response on completion
@@ -108,6 +128,17 @@ func (s *SpecTest) EnableMockResponseDelay() *SpecTest {
 	return s
 }
 
+// IsolateMocks scopes the mocks to this test, so tests with mocks can run with t.Parallel.
+// Instead of replacing http.DefaultTransport with the mocks, the mocks are bound to the context
+// of the inbound request. Outbound requests made by the handler reach the mocks only if they
+// carry that context, e.g. http.NewRequestWithContext(r.Context(), ...), or are sent by
+// the client returned from ClientFromContext(r.Context()).
+// Isolation only applies when the test is served by a http.Handler, not when networking is enabled.
+func (s *SpecTest) IsolateMocks() *SpecTest {
+	s.mocksIsolated = true
+	return s
+}
+
 // Debug logs to the console the http wire representation of all http interactions
 // that are intercepted by spectest. This includes the inbound request to the application
 // under test, the response returned by the application and any interactions that are
@@ -117,12 +148,51 @@ func (s *SpecTest) Debug() *SpecTest {
 	return s
 }
 
+// Redact sets the redaction policy that hides secrets in the reports, the debug output,
+// the cURL command of the failure message and the cassettes. It overrides the default
+// redaction policy set by SetDefaultRedaction.
+func (s *SpecTest) Redact(r *Redaction) *SpecTest {
+	s.redaction = r
+	s.debug.redaction = r
+	return s
+}
+
+// CurlBaseURL sets the base url of the cURL command of the failure message and the debug output,
+// e.g. "http://localhost:8080". The request served by a http.Handler has no host, so the cURL command
+// targets "http://server" by default. It has no effect when networking is enabled.
+func (s *SpecTest) CurlBaseURL(baseURL string) *SpecTest {
+	s.curlBaseURL = baseURL
+	s.debug.curlBaseURL = baseURL
+	return s
+}
+
+// redactionPolicy returns the redaction policy of the test.
+func (s *SpecTest) redactionPolicy() *Redaction {
+	return activeRedaction(s.redaction)
+}
+
 // Report provides a hook to add custom formatting to the output of the test
 func (s *SpecTest) Report(reporter ReportFormatter) *SpecTest {
 	s.reporter = reporter
 	return s
 }
 
+// OnReportError sets the handler of the error raised while the report is generated, e.g. the report
+// directory is not writable. By default, the error is reported by t.Errorf. The test result is kept in both cases.
+func (s *SpecTest) OnReportError(handler func(err error)) *SpecTest {
+	s.reportErrorHandler = handler
+	return s
+}
+
+// handleReportError sends the error of the report to the report error handler or t.Errorf.
+func (s *SpecTest) handleReportError(err error) {
+	if s.reportErrorHandler != nil {
+		s.reportErrorHandler(err)
+		return
+	}
+	s.t.Errorf("failed to generate the report: %v", err)
+}
+
 // Recorder provides a hook to add a recorder to the test
 func (s *SpecTest) Recorder(recorder *Recorder) *SpecTest {
 	s.recorder = recorder
@@ -171,6 +241,13 @@ func (s *SpecTest) Mocks(mocks ...*Mock) *SpecTest {
 	return s
 }
 
+// Cassette records the outbound requests that do not match any mock to the cassette file,
+// and replays them as mocks in later runs. See NewCassette for the modes and the options.
+func (s *SpecTest) Cassette(cassette *Cassette) *SpecTest {
+	s.cassette = cassette
+	return s
+}
+
 // HTTPClient allows the developer to provide a custom http client when using mocks
 func (s *SpecTest) HTTPClient(client *http.Client) *SpecTest {
 	s.httpClient = client
@@ -343,6 +420,7 @@ func (s *SpecTest) Tracef(format string, args ...interface{}) *Request {
 // report will run the test and return the report.
 func (s *SpecTest) report() *http.Response {
 	capture := newCapture()
+	s.capture = capture
 	s.observers = capture.appendObserver(s.observers)
 	s.mocksObservers = capture.appendMockObservers(s.mocksObservers)
 
@@ -352,9 +430,15 @@ func (s *SpecTest) report() *http.Response {
 	defer s.recorder.Reset()
 
 	res := s.response.runTest()
+	if capture.err != nil {
+		s.handleReportError(capture.err)
+	}
+	capture.redact(s.redactionPolicy())
 	s.recordResult(capture)
 	s.recorder.AddMeta(s.newMeta(capture))
-	s.reporter.Format(s.recorder)
+	if err := AdaptReportFormatter(s.reporter).FormatWithError(s.recorder); err != nil {
+		s.handleReportError(err)
+	}
 	return res
 }
 
@@ -368,6 +452,9 @@ func (s *SpecTest) newMeta(capture *capture) *Meta {
 	meta.Duration = s.interval.Duration().Nanoseconds()
 	meta.Name = s.name
 	meta.ReportFileName = s.meta.ReportFileName
+	meta.Attempts = s.response.attempts
+	passed := s.passed()
+	meta.Passed = &passed
 	if s.meta.Host != "" {
 		meta.Host = s.meta.Host
 	}
@@ -410,17 +497,46 @@ func (s SpecTest) recordResult(capture *capture) {
 		Timestamp: s.interval.Finished,
 	})
 
-	sort.Slice(s.recorder.Events, func(i, j int) bool {
+	for _, assertion := range s.assertions {
+		assertion.Timestamp = s.interval.Finished
+		s.recorder.AddAssertionResult(s.redactionPolicy().assertion(assertion))
+	}
+
+	sort.SliceStable(s.recorder.Events, func(i, j int) bool {
 		return s.recorder.Events[i].GetTime().Before(s.recorder.Events[j].GetTime())
 	})
 }
 
+// recordAssertion records the result of an assertion, so the report can show it.
+func (s *SpecTest) recordAssertion(name string, passed bool, expected, actual interface{}, message string) {
+	s.assertions = append(s.assertions, AssertionResult{
+		Name:      name,
+		Passed:    passed,
+		Expected:  fmt.Sprint(expected),
+		Actual:    fmt.Sprint(actual),
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// passed returns true if every recorded assertion passed.
+func (s *SpecTest) passed() bool {
+	for _, assertion := range s.assertions {
+		if !assertion.Passed {
+			return false
+		}
+	}
+	return true
+}
+
 // assertMocks will assert that all mocks were invoked the expected number of times.
 // If a mock was not invoked the expected number of times, the test will fail.
 func (s *SpecTest) assertMocks() {
 	for _, mock := range s.mocks {
 		if !mock.state.isRunning() && mock.execCount.isComplete() {
-			s.verifier.Fail(s.t, "mock was not invoked expected times", failureMessageArgs{Name: s.name})
+			message := "mock was not invoked expected times"
+			passed := s.verifier.Fail(s.t, message, s.failureMessageArgs())
+			s.recordAssertion(fmt.Sprintf("mock %s %s", mock.request.method, mock.request.url), passed, "invoked", "not invoked", message)
 		}
 	}
 }
@@ -429,11 +545,15 @@ func (s *SpecTest) assertMocks() {
 // If an assert function fails, the test will fail.
 func (s *SpecTest) assertFunc(res *http.Response, req *http.Request) {
 	if len(s.response.assert) > 0 {
-		for _, assertFn := range s.response.assert {
+		for i, assertFn := range s.response.assert {
+			name := fmt.Sprintf("assert function %d", i+1)
 			err := assertFn(copyHTTPResponse(res), copyHTTPRequest(req))
 			if err != nil {
-				s.verifier.NoError(s.t, err, failureMessageArgs{Name: s.name})
+				passed := s.verifier.NoError(s.t, err, s.failureMessageArgs())
+				s.recordAssertion(name, passed, "no error", err.Error(), err.Error())
+				continue
 			}
+			s.recordAssertion(name, true, "no error", "no error", "")
 		}
 	}
 }
@@ -446,13 +566,14 @@ func (s *SpecTest) doRequest() (*http.Response, *http.Request) {
 	if s.request.interceptor != nil {
 		s.request.interceptor(req)
 	}
+	s.curl = curlCommand(s.redactionPolicy().request(req), s.curlBaseURL)
 	resRecorder := httptest.NewRecorder()
 	s.debug.dumpRequest(req)
 
 	var res *http.Response
 	var err error
 	if !s.network.isEnable() {
-		s.serveHTTP(resRecorder, copyHTTPRequest(req))
+		s.serveHTTP(resRecorder, s.bindMocks(copyHTTPRequest(req)))
 		res = resRecorder.Result()
 	} else {
 		res, err = s.network.Do(copyHTTPRequest(req))
@@ -465,6 +586,19 @@ func (s *SpecTest) doRequest() (*http.Response, *http.Request) {
 	return res, req
 }
 
+// failureMessageArgs returns the additional info about the test that is passed to the verifier.
+func (s *SpecTest) failureMessageArgs() failureMessageArgs {
+	return failureMessageArgs{Name: s.name, Curl: s.curl}
+}
+
+// bindMocks binds the mock transport to the request context if the mocks are isolated.
+func (s *SpecTest) bindMocks(req *http.Request) *http.Request {
+	if !s.mocksIsolated || s.transport == nil {
+		return req
+	}
+	return req.WithContext(withMockTransport(req.Context(), s.transport))
+}
+
 // serveHTTP will serve the request using the http handler.
 func (s *SpecTest) serveHTTP(res *httptest.ResponseRecorder, req *http.Request) {
 	defer func() {
@@ -479,7 +613,9 @@ func (s *SpecTest) serveHTTP(res *httptest.ResponseRecorder, req *http.Request)
 // If the response does not match the expected response, the test will fail.
 func (s *SpecTest) assertResponse(res *http.Response) {
 	if s.response.status != 0 {
-		s.verifier.Equal(s.t, s.response.status, res.StatusCode, fmt.Sprintf("Status code %d not equal to %d", res.StatusCode, s.response.status), failureMessageArgs{Name: s.name})
+		message := fmt.Sprintf("Status code %d not equal to %d", res.StatusCode, s.response.status)
+		passed := s.verifier.Equal(s.t, s.response.status, res.StatusCode, message, s.failureMessageArgs())
+		s.recordAssertion("status code", passed, s.response.status, res.StatusCode, failureMessage(passed, message))
 	}
 
 	if s.response.body == "" {
@@ -492,10 +628,30 @@ func (s *SpecTest) assertResponse(res *http.Response) {
 		res.Body = io.NopCloser(bytes.NewBuffer(resBodyBytes))
 	}
 	if json.Valid([]byte(s.response.body)) {
-		s.verifier.JSONEq(s.t, s.response.body, string(resBodyBytes), failureMessageArgs{Name: s.name})
+		passed := s.verifier.JSONEq(s.t, s.response.body, string(resBodyBytes), s.failureMessageArgs())
+		s.recordAssertion("body", passed, indentJSON(s.response.body), indentJSON(string(resBodyBytes)), failureMessage(passed, "body is not equal to the expected JSON"))
 	} else {
-		s.verifier.Equal(s.t, s.response.body, string(resBodyBytes), failureMessageArgs{Name: s.name})
+		passed := s.verifier.Equal(s.t, s.response.body, string(resBodyBytes), s.failureMessageArgs())
+		s.recordAssertion("body", passed, s.response.body, string(resBodyBytes), failureMessage(passed, "body is not equal to the expected body"))
+	}
+}
+
+// failureMessage returns the message if the assertion failed. Otherwise, it returns an empty string.
+func failureMessage(passed bool, message string) string {
+	if passed {
+		return ""
 	}
+	return message
+}
+
+// indentJSON indents the JSON, so the diff of the expected and actual JSON is shown line by line.
+// It returns the value as it is if it is not valid JSON.
+func indentJSON(value string) string {
+	buf := new(bytes.Buffer)
+	if err := json.Indent(buf, []byte(value), "", "  "); err != nil {
+		return value
+	}
+	return buf.String()
 }
 
 // assertCookies will assert the cookies using the helper functions.
@@ -525,8 +681,18 @@ func (s *SpecTest) assertExpectedCookie(response *http.Response, expectedCookie
 			mismatchedFields = append(mismatchedFields, errors...)
 		}
 	}
-	s.verifier.Equal(s.t, true, foundCookie, "ExpectedCookie not found - "+*expectedCookie.name, failureMessageArgs{Name: s.name})
-	s.verifier.Equal(s.t, 0, len(mismatchedFields), strings.Join(mismatchedFields, ","), failureMessageArgs{Name: s.name})
+	found := s.verifier.Equal(s.t, true, foundCookie, "ExpectedCookie not found - "+*expectedCookie.name, s.failureMessageArgs())
+	matched := s.verifier.Equal(s.t, 0, len(mismatchedFields), strings.Join(mismatchedFields, ","), s.failureMessageArgs())
+
+	name := "cookie " + *expectedCookie.name
+	switch {
+	case !found:
+		s.recordAssertion(name, false, "present", "not present", "ExpectedCookie not found - "+*expectedCookie.name)
+	case !matched:
+		s.recordAssertion(name, false, "matched", "mismatched", strings.Join(mismatchedFields, ","))
+	default:
+		s.recordAssertion(name, true, "matched", "matched", "")
+	}
 }
 
 // assertPresentCookie checks if the given cookie name is present in the response's cookies.
@@ -538,7 +704,8 @@ func (s *SpecTest) assertPresentCookie(response *http.Response, cookieName strin
 			break
 		}
 	}
-	s.verifier.Equal(s.t, true, foundCookie, "ExpectedCookie not found - "+cookieName, failureMessageArgs{Name: s.name})
+	passed := s.verifier.Equal(s.t, true, foundCookie, "ExpectedCookie not found - "+cookieName, s.failureMessageArgs())
+	s.recordAssertion("cookie "+cookieName, passed, "present", presence(foundCookie), failureMessage(passed, "ExpectedCookie not found - "+cookieName))
 }
 
 // assertNotPresentCookie checks if the given cookie name is not present in the response's cookies.
@@ -550,14 +717,29 @@ func (s *SpecTest) assertNotPresentCookie(response *http.Response, cookieName st
 			break
 		}
 	}
-	s.verifier.Equal(s.t, false, foundCookie, "ExpectedCookie found - "+cookieName, failureMessageArgs{Name: s.name})
+	passed := s.verifier.Equal(s.t, false, foundCookie, "ExpectedCookie found - "+cookieName, s.failureMessageArgs())
+	s.recordAssertion("cookie "+cookieName, passed, "not present", presence(foundCookie), failureMessage(passed, "ExpectedCookie found - "+cookieName))
+}
+
+// presence returns the text that describes whether the cookie or the header is present.
+func presence(found bool) string {
+	if found {
+		return "present"
+	}
+	return "not present"
 }
 
 // assertHeaders will assert the headers.
 // If the headers do not match the expected headers, the test will fail.
 func (s *SpecTest) assertHeaders(res *http.Response) {
-	for expectedHeader, expectedValues := range s.response.headers {
-		s.assertExpectedHeaders(res, expectedHeader, expectedValues)
+	// The headers are asserted in order of the name, so the assertions are reported in a stable order.
+	names := make([]string, 0, len(s.response.headers))
+	for name := range s.response.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.assertExpectedHeaders(res, name, s.response.headers[name])
 	}
 	for _, expectedName := range s.response.headersPresent {
 		s.assertPresentHeaders(res, expectedName)
@@ -570,9 +752,11 @@ func (s *SpecTest) assertHeaders(res *http.Response) {
 // assertExpectedHeaders checks if the expected headers and their values are present in the response.
 func (s *SpecTest) assertExpectedHeaders(res *http.Response, expectedHeader string, expectedValues []string) {
 	resHeaderValues, foundHeader := res.Header[expectedHeader]
-	s.verifier.Equal(s.t, true, foundHeader, fmt.Sprintf("expected header '%s' not present in response", expectedHeader), failureMessageArgs{Name: s.name})
+	message := fmt.Sprintf("expected header '%s' not present in response", expectedHeader)
+	passed := s.verifier.Equal(s.t, true, foundHeader, message, s.failureMessageArgs())
 
 	if !foundHeader {
+		s.recordAssertion("header "+expectedHeader, passed, strings.Join(expectedValues, ","), presence(foundHeader), failureMessage(passed, message))
 		return
 	}
 
@@ -584,22 +768,32 @@ func (s *SpecTest) assertExpectedHeaders(res *http.Response, expectedHeader stri
 				break
 			}
 		}
-		s.verifier.Equal(s.t, true, foundValue, fmt.Sprintf("mismatched values for header '%s'. Expected %s but received %s", expectedHeader, expectedValue, strings.Join(resHeaderValues, ",")), failureMessageArgs{Name: s.name})
+		message := fmt.Sprintf("mismatched values for header '%s'. Expected %s but received %s", expectedHeader, expectedValue, strings.Join(resHeaderValues, ","))
+		passed := s.verifier.Equal(s.t, true, foundValue, message, s.failureMessageArgs())
+		s.recordAssertion("header "+expectedHeader, passed, expectedValue, strings.Join(resHeaderValues, ","), failureMessage(passed, message))
 	}
 }
 
 // assertPresentHeaders checks if the given headers are present in the response's headers.
 func (s *SpecTest) assertPresentHeaders(res *http.Response, expectedName string) {
 	if res.Header.Get(expectedName) == "" {
-		s.verifier.Fail(s.t, fmt.Sprintf("expected header '%s' not present in response", expectedName), failureMessageArgs{Name: s.name})
+		message := fmt.Sprintf("expected header '%s' not present in response", expectedName)
+		passed := s.verifier.Fail(s.t, message, s.failureMessageArgs())
+		s.recordAssertion("header "+expectedName, passed, "present", "not present", message)
+		return
 	}
+	s.recordAssertion("header "+expectedName, true, "present", "present", "")
 }
 
 // assertNotPresentHeaders checks if the given headers are not present in the response's headers.
 func (s *SpecTest) assertNotPresentHeaders(res *http.Response, name string) {
 	if res.Header.Get(name) != "" {
-		s.verifier.Fail(s.t, fmt.Sprintf("did not expect header '%s' in response", name), failureMessageArgs{Name: s.name})
+		message := fmt.Sprintf("did not expect header '%s' in response", name)
+		passed := s.verifier.Fail(s.t, message, s.failureMessageArgs())
+		s.recordAssertion("header "+name, passed, "not present", "present", message)
+		return
 	}
+	s.recordAssertion("header "+name, true, "not present", "not present", "")
 }
 
 // assertValidHandlerOrNetwork will assert that either a http.Handler is defined or networking is enabled.
@@ -623,12 +817,12 @@ func (s *SpecTest) buildRequest() *http.Request {
 		s.setMultipartHeaders()
 	}
 
-	req, _ := http.NewRequest(s.request.method, s.request.url, bytes.NewBufferString(s.request.body)) // TODO: handle error
+	req, _ := http.NewRequest(s.request.method, s.expand(s.request.url), bytes.NewBufferString(s.expand(s.request.body))) // TODO: handle error
 	if s.request.context != nil {
 		req = req.WithContext(s.request.context)
 	}
 
-	req.URL.RawQuery = formatQuery(s.request)
+	req.URL.RawQuery = formatQuery(s.request, s.vars)
 	req.Host = SystemUnderTestDefaultName
 	if s.network.isEnable() {
 		req.Host = req.URL.Host
@@ -636,28 +830,35 @@ func (s *SpecTest) buildRequest() *http.Request {
 
 	for k, v := range s.request.headers {
 		for _, headerValue := range v {
-			req.Header.Add(k, headerValue)
+			req.Header.Add(k, s.expand(headerValue))
 		}
 	}
 
 	for _, cookie := range s.request.cookies {
-		req.AddCookie(cookie.ToHTTPCookie())
+		httpCookie := cookie.ToHTTPCookie()
+		httpCookie.Value = s.expand(httpCookie.Value)
+		req.AddCookie(httpCookie)
 	}
 
 	if s.request.basicAuth != "" {
-		parts := strings.Split(s.request.basicAuth, ":")
+		parts := strings.Split(s.expand(s.request.basicAuth), ":")
 		req.SetBasicAuth(parts[0], parts[1])
 	}
 
 	return req
 }
 
+// expand replaces the "${name}" placeholders in the given string with the captured values.
+func (s *SpecTest) expand(in string) string {
+	return expandVars(in, s.vars)
+}
+
 // buildFormRequestBody builds the request body for form data.
 func (s *SpecTest) buildFormRequestBody() string {
 	form := url.Values{}
 	for k := range s.request.formData {
 		for _, value := range s.request.formData[k] {
-			form.Add(k, value)
+			form.Add(k, s.expand(value))
 		}
 	}
 	return form.Encode()
@@ -674,19 +875,20 @@ func (s *SpecTest) setMultipartHeaders() {
 }
 
 // formatQuery will format the query parameters.
-func formatQuery(request *Request) string {
+// The "${name}" placeholders in the values are replaced with the given vars.
+func formatQuery(request *Request, vars map[string]string) string {
 	var out url.Values = map[string][]string{}
 
 	if request.queryCollection != nil {
 		for _, param := range buildQueryCollection(request.queryCollection) {
-			out.Add(param.l, param.r)
+			out.Add(param.l, expandVars(param.r, vars))
 		}
 	}
 
 	if request.query != nil {
 		for k, v := range request.query {
 			for _, p := range v {
-				out.Add(k, p)
+				out.Add(k, expandVars(p, vars))
 			}
 		}
 	}
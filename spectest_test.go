@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -902,6 +903,7 @@ func TestApiTestReport(t *testing.T) {
 	spectest.DefaultVerifier{}.Equal(t, "some test", r.SubTitle)
 	spectest.DefaultVerifier{}.Equal(t, 4, len(r.Events))
 	spectest.DefaultVerifier{}.Equal(t, http.StatusOK, r.Meta.StatusCode)
+	spectest.DefaultVerifier{}.Equal(t, true, *r.Meta.Passed)
 	spectest.DefaultVerifier{}.Equal(t, "/hello", r.Meta.Path)
 	spectest.DefaultVerifier{}.Equal(t, http.MethodPost, r.Meta.Method)
 	spectest.DefaultVerifier{}.Equal(t, "some test", r.Meta.Name)
@@ -1373,6 +1375,183 @@ func TestApiTestMatchesTimes(t *testing.T) {
 	spectest.DefaultVerifier{}.Equal(t, 0, len(res.UnmatchedMocks()))
 }
 
+func TestReportAssertionResults(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"name": "jon", "age": 30}`))
+	})
+	run := func(reporter spectest.ReportFormatter) *failureCaptorT {
+		captor := &failureCaptorT{}
+		spectest.New("get user").
+			CustomReportName("get_user").
+			Report(reporter).
+			Handler(handler).
+			Get("/user").
+			Expect(captor).
+			Status(http.StatusOK).
+			Header("Content-Type", "application/json").
+			Body(`{"name": "tom", "age": 30}`).
+			Assert(func(*http.Response, *http.Request) error {
+				return errors.New("the user is not active")
+			}).
+			End()
+		return captor
+	}
+
+	t.Run("record the results", func(t *testing.T) {
+		reporter := &RecorderCaptor{}
+		captor := run(reporter)
+		spectest.DefaultVerifier{}.Equal(t, true, len(captor.messages) > 0)
+
+		r := reporter.capturedRecorder
+		spectest.DefaultVerifier{}.Equal(t, false, *r.Meta.Passed)
+		spectest.DefaultVerifier{}.Equal(t, http.StatusInternalServerError, r.Meta.StatusCode)
+
+		results := r.AssertionResults
+		spectest.DefaultVerifier{}.Equal(t, 4, len(results))
+		for i, want := range []struct {
+			name     string
+			passed   bool
+			expected string
+			actual   string
+			message  string
+		}{
+			{name: "status code", passed: false, expected: "200", actual: "500", message: "Status code 500 not equal to 200"},
+			{name: "body", passed: false, expected: "{\n  \"name\": \"tom\",\n  \"age\": 30\n}", actual: "{\n  \"name\": \"jon\",\n  \"age\": 30\n}", message: "body is not equal to the expected JSON"},
+			{name: "header Content-Type", passed: true, expected: "application/json", actual: "application/json"},
+			{name: "assert function 1", passed: false, expected: "no error", actual: "the user is not active", message: "the user is not active"},
+		} {
+			got := results[i]
+			if got.Name != want.name || got.Passed != want.passed || got.Expected != want.expected || got.Actual != want.actual || got.Message != want.message {
+				t.Errorf("unexpected assertion result %d: %+v", i, got)
+			}
+			if got.Timestamp.IsZero() {
+				t.Errorf("the assertion result %d has no timestamp", i)
+			}
+		}
+		spectest.DefaultVerifier{}.Equal(t, "--- Expected\n+++ Actual\n@@ -1,4 +1,4 @@\n {\n-  \"name\": \"tom\",\n+  \"name\": \"jon\",\n   \"age\": 30\n }\n", results[1].Diff())
+
+		// The assertion results follow the final response.
+		_, ok := r.Events[1].(spectest.HTTPResponse)
+		spectest.DefaultVerifier{}.Equal(t, true, ok)
+	})
+
+	t.Run("markdown report", func(t *testing.T) {
+		dir := t.TempDir()
+		run(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindMarkdown}))
+
+		data, err := os.ReadFile(filepath.Clean(filepath.Join(dir, "get_user.md")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"## Assertions",
+			"| FAIL   | status code         | Status code 500 not equal to 200       |",
+			"| PASS   | header Content-Type |                                        |",
+			"#### body\n```diff\n--- Expected\n+++ Actual\n",
+			"-  \"name\": \"tom\",\n+  \"name\": \"jon\",",
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("markdown report does not contain %q:\n%s", want, data)
+			}
+		}
+	})
+
+	t.Run("html report", func(t *testing.T) {
+		dir := t.TempDir()
+		run(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Assets: spectest.ReportAssetsEmbedded}))
+
+		data, err := os.ReadFile(filepath.Clean(filepath.Join(dir, "get_user.html")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			`<span class="badge badge-danger">failed</span>`,
+			`<p class="lead">Assertions</p>`,
+			`<span class="badge badge-danger">FAIL</span>`,
+			`<td>status code</td>`,
+			`<pre>Status code 500 not equal to 200</pre>`,
+			`<code id="assertion-diff-1">--- Expected`,
+			`"passed":false`,
+		} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("html report does not contain %q", want)
+			}
+		}
+	})
+}
+
+func TestReportError(t *testing.T) {
+	// The report directory can not be created under a file.
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, []byte("not a directory"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reporter := spectest.SequenceReport(spectest.ReportFormatterConfig{Path: filepath.Join(notDir, "reports")})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("report the error by t.Errorf", func(t *testing.T) {
+		captor := &failureCaptorT{}
+		result := spectest.New().
+			Report(reporter).
+			Handler(handler).
+			Get("/hello").
+			Expect(captor).
+			Status(http.StatusOK).
+			End()
+
+		spectest.DefaultVerifier{}.Equal(t, http.StatusOK, result.Response.StatusCode)
+		spectest.DefaultVerifier{}.Equal(t, 1, len(captor.messages))
+		if !strings.HasPrefix(captor.messages[0], "failed to generate the report: ") {
+			t.Errorf("unexpected message: %s", captor.messages[0])
+		}
+	})
+
+	t.Run("report the error to the handler", func(t *testing.T) {
+		var reportErr error
+		spectest.New().
+			Report(reporter).
+			OnReportError(func(err error) {
+				reportErr = err
+			}).
+			Handler(handler).
+			Get("/hello").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+
+		if reportErr == nil {
+			t.Error("expected the report error")
+		}
+	})
+
+	t.Run("formatter that returns the error", func(t *testing.T) {
+		var reportErr error
+		spectest.New().
+			Report(spectest.NewReportFormatter(errReportFormatter{})).
+			OnReportError(func(err error) {
+				reportErr = err
+			}).
+			Handler(handler).
+			Get("/hello").
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+
+		spectest.DefaultVerifier{}.Equal(t, "failed to upload the report", reportErr.Error())
+	})
+}
+
+// errReportFormatter is a ReportFormatterWithError that always fails.
+type errReportFormatter struct{}
+
+func (errReportFormatter) FormatWithError(*spectest.Recorder) error {
+	return errors.New("failed to upload the report")
+}
+
 type RecorderCaptor struct {
 	capturedRecorder spectest.Recorder
 }
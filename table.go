@@ -0,0 +1,179 @@
+package spectest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"testing"
+)
+
+// TableCase is a case of a table-driven test run by SpecTest.RunTable.
+// Zero values are not applied, so the request and expectations of the base SpecTest are used.
+type TableCase struct {
+	// Name is the name of the case. It is used as the subtest name, the report sub title and the report file name.
+	Name string
+	// Method overrides the http method of the request.
+	Method string
+	// Path overrides the url of the request.
+	Path string
+	// Headers are added to the request headers. A header of the base SpecTest with the same name is replaced.
+	Headers map[string]string
+	// Query is added to the request query parameters.
+	Query map[string]string
+	// Body overrides the request body.
+	Body string
+	// Mocks overrides the mocks of the base SpecTest.
+	Mocks []*Mock
+	// ExpectStatus overrides the expected response http status code.
+	ExpectStatus int
+	// ExpectBody overrides the expected response body.
+	ExpectBody string
+	// ExpectHeaders is added to the expected response headers.
+	ExpectHeaders map[string]string
+	// Assert is added to the custom assertions.
+	Assert []Assert
+}
+
+// RunTable runs every case as a t.Run subtest. Each case is a copy of this SpecTest
+// that is overridden by the fields of the case. The case name is used as the report sub title,
+// and the report file name is built from the subtest name, so every case gets its own report.
+// If CustomReportName is set on this SpecTest, it is used as the prefix of the report file name.
+func (s *SpecTest) RunTable(t *testing.T, cases []TableCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			specTest := s.clone()
+			specTest.name = c.Name
+			specTest.meta.ReportFileName = tableReportFileName(s.meta.ReportFileName, t.Name())
+			c.apply(specTest, t)
+			specTest.response.End()
+		})
+	}
+}
+
+// apply overrides the request and expectations of the SpecTest with the case.
+func (c TableCase) apply(s *SpecTest, t *testing.T) {
+	if c.Method != "" {
+		s.request.method = c.Method
+	}
+	if c.Path != "" {
+		s.request.url = c.Path
+	}
+	for k, v := range c.Headers {
+		s.request.headers[textproto.CanonicalMIMEHeaderKey(k)] = []string{v}
+	}
+	for k, v := range c.Query {
+		s.request.query[k] = append(s.request.query[k], v)
+	}
+	if c.Body != "" {
+		s.request.body = c.Body
+	}
+	if c.Mocks != nil {
+		s.Mocks(c.Mocks...)
+	}
+
+	res := s.request.Expect(t)
+	if c.ExpectStatus != 0 {
+		res.Status(c.ExpectStatus)
+	}
+	if c.ExpectBody != "" {
+		res.Body(c.ExpectBody)
+	}
+	for k, v := range c.ExpectHeaders {
+		res.headers[textproto.CanonicalMIMEHeaderKey(k)] = []string{v}
+	}
+	res.assert = append(res.assert, c.Assert...)
+}
+
+// reportFileNameRegexp matches the characters that are not allowed in the report file name.
+var reportFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_\-.]+`)
+
+// tableReportFileName returns the report file name of a table case.
+func tableReportFileName(prefix, testName string) string {
+	name := reportFileNameRegexp.ReplaceAllString(testName, "_")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// clone returns a copy of the SpecTest that can be run independently.
+// Every field is copied except the state of a single run: the recorder, the testing.T,
+// the transport, the report capture, the cURL command, the assertion results and the interval.
+// Each copy records its own events and gets its own copy of the multipart body.
+func (s *SpecTest) clone() *SpecTest {
+	c := *s
+	c.recorder = nil
+	c.t = nil
+	c.transport = nil
+	c.capture = nil
+	c.curl = ""
+	c.assertions = nil
+	c.interval = NewInterval()
+	c.observers = append([]Observe{}, s.observers...)
+	c.mocksObservers = append([]Observe{}, s.mocksObservers...)
+	c.Mocks(s.mocks...)
+
+	meta := *s.meta
+	c.meta = &meta
+
+	if s.vars != nil {
+		c.vars = map[string]string{}
+		for k, v := range s.vars {
+			c.vars[k] = v
+		}
+	}
+
+	request := *s.request
+	request.specTest = &c
+	request.query = copyValues(s.request.query)
+	request.queryCollection = copyValues(s.request.queryCollection)
+	request.headers = copyValues(s.request.headers)
+	request.formData = copyValues(s.request.formData)
+	request.multipartBody, request.multipart = copyMultipart(s.request.multipartBody, s.request.multipart)
+	request.cookies = append([]*Cookie{}, s.request.cookies...)
+	c.request = &request
+
+	response := *s.response
+	response.specTest = &c
+	response.headers = copyValues(s.response.headers)
+	response.headersPresent = append([]string{}, s.response.headersPresent...)
+	response.headersNotPresent = append([]string{}, s.response.headersNotPresent...)
+	response.cookies = append([]*Cookie{}, s.response.cookies...)
+	response.cookiesPresent = append([]string{}, s.response.cookiesPresent...)
+	response.cookiesNotPresent = append([]string{}, s.response.cookiesNotPresent...)
+	response.assert = append([]Assert{}, s.response.assert...)
+	response.captures = append([]valueCapture{}, s.response.captures...)
+	goldenFile := *s.response.goldenFile
+	response.goldenFile = &goldenFile
+	response.attempts = 0
+	c.response = &response
+	return &c
+}
+
+// copyMultipart returns a copy of the multipart body and a writer of the copy with the same boundary.
+// The writer of the copy is only closed, because the parts are not added after the copy is made.
+func copyMultipart(body *bytes.Buffer, writer *multipart.Writer) (*bytes.Buffer, *multipart.Writer) {
+	if writer == nil {
+		return nil, nil
+	}
+	c := bytes.NewBuffer(append([]byte{}, body.Bytes()...))
+	w := multipart.NewWriter(c)
+	if err := w.SetBoundary(writer.Boundary()); err != nil {
+		// The boundary of the original writer is always valid.
+		panic(err)
+	}
+	return c, w
+}
+
+// copyValues returns a deep copy of a multi-value map such as headers or query parameters.
+func copyValues(src map[string][]string) map[string][]string {
+	dst := make(map[string][]string, len(src))
+	for k, v := range src {
+		dst[k] = append([]string{}, v...)
+	}
+	return dst
+}
@@ -0,0 +1,115 @@
+package spectest
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSpecTestCloneCopiesEveryField fails when a field is added to SpecTest, Request or Response
+// without being set in this test. Set the new field below and make sure clone copies it,
+// or add it to the state of a single run that clone resets.
+func TestSpecTestCloneCopiesEveryField(t *testing.T) {
+	base := New("base").
+		Debug().
+		EnableMockResponseDelay().
+		IsolateMocks().
+		EnableNetworking(http.DefaultClient).
+		Redact(StandardRedaction()).
+		Report(SequenceReport(ReportFormatterConfig{Path: t.TempDir()})).
+		OnReportError(func(err error) {}).
+		Recorder(NewTestRecorder()).
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}).
+		Mocks(NewMock().Get("http://localhost:8080").RespondWith().Status(http.StatusOK).End()).
+		Cassette(NewCassette(t.TempDir())).
+		HTTPClient(http.DefaultClient).
+		Observe(func(*http.Response, *http.Request, *SpecTest) {}).
+		ObserveMocks(func(*http.Response, *http.Request, *SpecTest) {}).
+		Intercept(func(*http.Request) {}).
+		Verifier(DefaultVerifier{}).
+		CustomReportName("base")
+	base.t = t
+	base.httpRequest = &http.Request{}
+	base.transport = &Transport{}
+	base.capture = newCapture()
+	base.curl = "curl"
+	base.curlBaseURL = "http://localhost:8080"
+	base.assertions = []AssertionResult{{Name: "status code"}}
+	base.vars = map[string]string{"id": "1"}
+
+	base.request.method = http.MethodPost
+	base.request.url = "/users"
+	base.request.body = "{}"
+	base.request.query = map[string][]string{"q": {"1"}}
+	base.request.queryCollection = map[string][]string{"c": {"1"}}
+	base.request.headers = map[string][]string{"X-Id": {"1"}}
+	base.request.formData = map[string][]string{"f": {"1"}}
+	base.request.multipartBody = &bytes.Buffer{}
+	base.request.multipart = multipart.NewWriter(base.request.multipartBody)
+	base.request.cookies = []*Cookie{NewCookie("session")}
+	base.request.basicAuth = "user:password"
+	base.request.context = context.Background()
+
+	base.response.status = http.StatusCreated
+	base.response.body = "{}"
+	base.response.headers = map[string][]string{"X-Id": {"1"}}
+	base.response.headersPresent = []string{"X-Id"}
+	base.response.headersNotPresent = []string{"X-Secret"}
+	base.response.cookies = []*Cookie{NewCookie("session")}
+	base.response.cookiesPresent = []string{"session"}
+	base.response.cookiesNotPresent = []string{"token"}
+	base.response.assert = []Assert{IsSuccess}
+	base.response.goldenFile = newGoldenFile("golden.json", true, &defaultFileSystem{})
+	base.response.captures = []valueCapture{{name: "id"}}
+	base.response.eventually = &eventually{timeout: time.Second, interval: time.Millisecond}
+	base.response.attempts = 2
+
+	c := base.clone()
+
+	assertFieldsCopied(t, reflect.ValueOf(base).Elem(), reflect.ValueOf(c).Elem(),
+		"recorder", "t", "transport", "capture", "curl", "assertions")
+	assertFieldsCopied(t, reflect.ValueOf(base.request).Elem(), reflect.ValueOf(c.request).Elem())
+	assertFieldsCopied(t, reflect.ValueOf(base.response).Elem(), reflect.ValueOf(c.response).Elem(),
+		"attempts")
+
+	if c.request.specTest != c || c.response.specTest != c {
+		t.Error("the request and the response of the copy must refer to the copy")
+	}
+	if c.meta == base.meta || c.response.goldenFile == base.response.goldenFile {
+		t.Error("the meta data and the golden file must not be shared with the copy")
+	}
+	if c.request.multipartBody == base.request.multipartBody || c.request.multipart == base.request.multipart {
+		t.Error("the multipart body must not be shared with the copy")
+	}
+}
+
+// assertFieldsCopied checks that every field of the struct is set in src and copied to dst,
+// except the reset fields that must be the zero value in dst.
+func assertFieldsCopied(t *testing.T, src, dst reflect.Value, reset ...string) {
+	t.Helper()
+
+	resetFields := map[string]bool{}
+	for _, name := range reset {
+		resetFields[name] = true
+	}
+	for i := 0; i < src.NumField(); i++ {
+		name := src.Type().Field(i).Name
+		if src.Field(i).IsZero() {
+			t.Errorf("%s.%s is not set in the test", src.Type().Name(), name)
+			continue
+		}
+		if resetFields[name] {
+			if !dst.Field(i).IsZero() {
+				t.Errorf("%s.%s must be reset by clone", src.Type().Name(), name)
+			}
+			continue
+		}
+		if dst.Field(i).IsZero() {
+			t.Errorf("%s.%s is not copied by clone", src.Type().Name(), name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package spectest_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nao1215/spectest"
+)
+
+// recordersCaptor captures the meta data of every report.
+type recordersCaptor struct {
+	subTitles []string
+	metas     []spectest.Meta
+}
+
+func (r *recordersCaptor) Format(recorder *spectest.Recorder) {
+	r.subTitles = append(r.subTitles, recorder.SubTitle)
+	r.metas = append(r.metas, *recorder.Meta)
+}
+
+func TestSpecTestRunTable(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/users/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": 1, "lang": "` + r.URL.Query().Get("lang") + `"}`))
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	reporter := &recordersCaptor{}
+	base := spectest.New().
+		Handler(handler).
+		Report(reporter).
+		CustomReportName("users")
+	base.Get("/users/1").
+		Header("Authorization", "Bearer token").
+		Expect(t).
+		Status(http.StatusOK)
+
+	base.RunTable(t, []spectest.TableCase{
+		{
+			Name:          "get user",
+			Query:         map[string]string{"lang": "en"},
+			ExpectBody:    `{"id": 1, "lang": "en"}`,
+			ExpectHeaders: map[string]string{"Content-Type": "application/json"},
+		},
+		{
+			Name:         "delete user",
+			Method:       http.MethodDelete,
+			ExpectStatus: http.StatusNoContent,
+		},
+		{
+			Name:         "unauthorized",
+			Headers:      map[string]string{"authorization": "Bearer wrong"},
+			ExpectStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:         "method not allowed",
+			Method:       http.MethodPost,
+			Body:         `{"id": 1}`,
+			ExpectStatus: http.StatusMethodNotAllowed,
+			Assert:       []spectest.Assert{spectest.IsClientError},
+		},
+	})
+
+	if len(reporter.metas) != 4 {
+		t.Fatalf("expected 4 reports, got %d", len(reporter.metas))
+	}
+	tableMetas := reporter.metas
+	spectest.DefaultVerifier{}.Equal(t, []string{"get user", "delete user", "unauthorized", "method not allowed"}, reporter.subTitles)
+	spectest.DefaultVerifier{}.Equal(t, "users_TestSpecTestRunTable_get_user", tableMetas[0].ReportFileName)
+	spectest.DefaultVerifier{}.Equal(t, "users_TestSpecTestRunTable_method_not_allowed", tableMetas[3].ReportFileName)
+	spectest.DefaultVerifier{}.Equal(t, http.MethodDelete, tableMetas[1].Method)
+	spectest.DefaultVerifier{}.Equal(t, "/users/1?lang=en", tableMetas[0].Path)
+	spectest.DefaultVerifier{}.Equal(t, "/users/1", tableMetas[1].Path)
+}
+
+func TestSpecTestRunTableRedaction(t *testing.T) {
+	dir := t.TempDir()
+	base := spectest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).
+		Redact(spectest.StandardRedaction()).
+		Report(spectest.SequenceReport(spectest.ReportFormatterConfig{Path: dir, Kind: spectest.ReportKindJSON})).
+		CustomReportName("users")
+	base.Get("/users/1").
+		Header("Authorization", "Bearer secret").
+		Expect(t).
+		Status(http.StatusOK)
+
+	base.RunTable(t, []spectest.TableCase{
+		{Name: "get user"},
+		{Name: "other token", Headers: map[string]string{"Authorization": "Bearer other-secret"}},
+	})
+
+	for _, name := range []string{"get_user", "other_token"} {
+		data, err := os.ReadFile(filepath.Join(dir, "users_TestSpecTestRunTableRedaction_"+name+".report.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("the report of %q leaks the secret:\n%s", name, data)
+		}
+	}
+}
+
+func TestSpecTestRunTableReportError(t *testing.T) {
+	var reportErrs []error
+	base := spectest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}).
+		Report(spectest.NewReportFormatter(errReportFormatter{})).
+		OnReportError(func(err error) {
+			reportErrs = append(reportErrs, err)
+		})
+	base.Get("/hello").
+		Expect(t).
+		Status(http.StatusOK)
+
+	base.RunTable(t, []spectest.TableCase{
+		{Name: "first"},
+		{Name: "second"},
+	})
+
+	spectest.DefaultVerifier{}.Equal(t, 2, len(reportErrs))
+	for _, err := range reportErrs {
+		spectest.DefaultVerifier{}.Equal(t, "failed to upload the report", err.Error())
+	}
+}
+
+func TestSpecTestRunTableMultipart(t *testing.T) {
+	base := spectest.New().
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Name", r.FormValue("name"))
+			w.WriteHeader(http.StatusOK)
+		})
+	base.Post("/users").
+		MultipartFormData("name", "gopher").
+		Expect(t).
+		Status(http.StatusOK).
+		Header("X-Name", "gopher")
+
+	base.RunTable(t, []spectest.TableCase{
+		{Name: "create user"},
+		{Name: "create user again"},
+	})
+}
@@ -4,7 +4,27 @@ const reportTemplate = `<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="utf-8">
-    <link rel="stylesheet" href="https://stackpath.bootstrapcdn.com/bootstrap/4.1.2/css/bootstrap.min.css">
+    {{if .Embedded}}<style>
+        *, ::after, ::before { box-sizing: border-box; }
+        body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif; font-size: 1rem; line-height: 1.5; color: #212529; background-color: #fff; }
+        h2 { margin-top: 0; margin-bottom: .5rem; font-size: 2rem; font-weight: 500; line-height: 1.2; }
+        pre, code { font-family: SFMono-Regular, Menlo, Monaco, Consolas, "Liberation Mono", "Courier New", monospace; font-size: 87.5%; }
+        pre { display: block; margin-top: 0; margin-bottom: 1rem; overflow: auto; color: #212529; }
+        img, svg { max-width: 100%; height: auto; vertical-align: middle; }
+        .container-fluid { width: 100%; padding-right: 15px; padding-left: 15px; margin-right: auto; margin-left: auto; }
+        .lead { font-size: 1.25rem; font-weight: 300; }
+        .badge { display: inline-block; padding: .25em .4em; font-size: 75%; font-weight: 700; line-height: 1; text-align: center; white-space: nowrap; vertical-align: baseline; border-radius: .25rem; }
+        .badge-success { color: #fff; background-color: #28a745; }
+        .badge-warning { color: #212529; background-color: #ffc107; }
+        .badge-danger { color: #fff; background-color: #dc3545; }
+        .badge-info { color: #fff; background-color: #17a2b8; }
+        .card { position: relative; display: flex; flex-direction: column; min-width: 0; word-wrap: break-word; background-color: #fff; border: 1px solid rgba(0, 0, 0, .125); border-radius: .25rem; }
+        .card-body { flex: 1 1 auto; padding: 1.25rem; }
+        .text-center { text-align: center; }
+        .table { width: 100%; margin-bottom: 1rem; background-color: transparent; border-collapse: collapse; }
+        .table td, .table th { padding: .75rem; vertical-align: top; border-top: 1px solid #dee2e6; text-align: left; }
+        .table thead th { vertical-align: bottom; border-bottom: 2px solid #dee2e6; }
+    </style>{{else}}<link rel="stylesheet" href="https://stackpath.bootstrapcdn.com/bootstrap/4.1.2/css/bootstrap.min.css">
     <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/highlight.js/9.12.0/styles/github.min.css"/>
     <script src="https://cdnjs.cloudflare.com/ajax/libs/underscore.js/1.8.3/underscore-min.js"></script>
     <script src="https://cdnjs.cloudflare.com/ajax/libs/raphael/2.2.7/raphael.min.js"></script>
@@ -12,7 +32,7 @@ const reportTemplate = `<!DOCTYPE html>
     <script src="https://bramp.github.io/js-sequence-diagrams/js/sequence-diagram-min.js"></script>
     <script src="https://code.jquery.com/jquery-3.3.1.slim.min.js"></script>
     <script src="https://cdnjs.cloudflare.com/ajax/libs/popper.js/1.14.3/umd/popper.min.js"></script>
-    <script src="https://stackpath.bootstrapcdn.com/bootstrap/4.1.2/js/bootstrap.min.js"></script>
+    <script src="https://stackpath.bootstrapcdn.com/bootstrap/4.1.2/js/bootstrap.min.js"></script>{{end}}
     <style>
         body {
             padding-top: 2rem;
@@ -48,14 +68,45 @@ const reportTemplate = `<!DOCTYPE html>
 <!-- THIS CODE IS AUTOGENERATED. DO NOT EDIT -->
 <div class="container-fluid">
     <h2>{{printf "%.100s" .Title }}</h2>
-    <span class="{{ .BadgeClass }}">{{ .StatusCode }}</span>
+    <span class="{{ .BadgeClass }}">{{ .StatusCode }}</span>{{if .Attempts }} <span class="badge badge-info">{{ .Attempts }} attempts</span>{{end}}{{if .Assertions }} {{if .Passed }}<span class="badge badge-success">passed</span>{{else}}<span class="badge badge-danger">failed</span>{{end}}{{end}}
     <p class="lead">{{ .SubTitle }}</p>
     <div class="card text-center">
         <div class="card-body">
-            <div id="d" class="justify-content-center"></div>
+            <div id="d" class="justify-content-center">{{ .DiagramSVG }}</div>
         </div>
     </div>
     <br><br>
+    {{if .Curl }}
+    <p class="lead">cURL</p>
+    <pre style="margin-bottom: 0; border: 1px solid #eee;"><code id="curl-command">{{ .Curl }}</code></pre>
+    <button class="copy-to-clipboard-button" data-clipboard-target="#curl-command">copy to clipboard</button>
+    <br><br>
+    {{end}}
+    {{if .Assertions }}
+    <p class="lead">Assertions</p>
+    <table class="table">
+        <thead>
+        <tr>
+            <th scope="col">Result</th>
+            <th scope="col">Assertion</th>
+            <th scope="col">Detail</th>
+        </tr>
+        </thead>
+        <tbody>
+        {{ range $i, $a := .Assertions }}
+        <tr id="assertion-{{$i}}">
+            <td>{{if $a.Passed }}<span class="badge badge-success">PASS</span>{{else}}<span class="badge badge-danger">FAIL</span>{{end}}</td>
+            <td>{{ $a.Name }}</td>
+            <td>
+                {{if $a.Message }}<pre>{{ $a.Message }}</pre>{{end}}
+                {{with $a.Diff }}<pre style="max-height: 1000px; margin-bottom: 0; border: 1px solid #eee;"><code id="assertion-diff-{{$i}}">{{ . }}</code></pre>{{end}}
+            </td>
+        </tr>
+        {{ end }}
+        </tbody>
+    </table>
+    <br><br>
+    {{end}}
     <p class="lead">Event Log</p>
     <table class="table">
         <thead>
@@ -71,7 +122,9 @@ const reportTemplate = `<!DOCTYPE html>
             <td>
                 <pre>{{ $e.Header }}</pre>
                 {{if $e.Body }}
-                    {{if contains $e.Body ".jpeg" ".png" ".gif" ".svg" ".bmp" ".webp" ".tiff" ".ico" }}
+                    {{if index $.Images $i }}
+                        <img src="{{ index $.Images $i }}" alt="Image">
+                    {{else if contains $e.Body ".jpeg" ".png" ".gif" ".svg" ".bmp" ".webp" ".tiff" ".ico" }}
                         <img src="{{ $e.Body }}" alt="Image">
                     {{else}}
                         <pre style="max-height: 1000px; margin-bottom: 0; border: 1px solid #eee;"><code id="event-message-{{$i}}">{{ $e.Body }}</code></pre>
@@ -85,16 +138,32 @@ const reportTemplate = `<!DOCTYPE html>
     </table>
 </div>
 <button onclick="topFunction()" id="scroll-to-top-button" title="Go to top">Back to top</button>
-<script>
+{{if not .Embedded}}<script>
     Diagram.parse("{{ .WebSequenceDSL }}").drawSVG("d", {theme: 'simple', 'font-size': 14});
-</script>
+</script>{{end}}
 <style>
     
 </style>
 {{if $.MetaJSON }}<script type="application/json" id="metaJson">{{$.MetaJSON}}</script>{{end}}
-<script src="https://cdn.jsdelivr.net/gh/highlightjs/cdn-release@9.13.1/build/highlight.min.js"></script>
+{{if .Embedded}}<script>
+    var copyButtons = document.getElementsByClassName('copy-to-clipboard-button');
+    for (var i = 0; i < copyButtons.length; i++) {
+        copyButtons[i].addEventListener('click', function (e) {
+            var target = document.querySelector(e.target.getAttribute('data-clipboard-target'));
+            if (navigator.clipboard) {
+                navigator.clipboard.writeText(target.textContent);
+                return;
+            }
+            var range = document.createRange();
+            range.selectNodeContents(target);
+            window.getSelection().removeAllRanges();
+            window.getSelection().addRange(range);
+            document.execCommand('copy');
+        }, false);
+    }
+</script>{{else}}<script src="https://cdn.jsdelivr.net/gh/highlightjs/cdn-release@9.13.1/build/highlight.min.js"></script>
 <script>hljs.initHighlightingOnLoad();</script>
-<script>new ClipboardJS('.copy-to-clipboard-button');</script>
+<script>new ClipboardJS('.copy-to-clipboard-button');</script>{{end}}
 <script>
     var elements = document.getElementsByTagName('text')
     var regex = /\((\d{1,3})\)/ // match elements containing (0), (1), etc.